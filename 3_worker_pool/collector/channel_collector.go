@@ -2,32 +2,45 @@ package collector
 
 import (
 	"AwesomePresentation/3_worker_pool/model"
+	"AwesomePresentation/logging"
 	"context"
 	"fmt"
+	"go.opentelemetry.io/otel/trace"
 	"runtime/debug"
 	"time"
 )
 
 type channelCollector struct {
-	nodes []model.ProcessingNode
+	nodes   []model.ProcessingNode
+	logger  logging.Logger
+	metrics nodeMetrics
+	tracer  trace.Tracer
 }
 
-func NewChannelCollector(nodes []model.ProcessingNode) model.Collector {
+// By default nothing is logged, recorded or traced, see `Option`
+func NewChannelCollector(nodes []model.ProcessingNode, options ...Option) model.Collector {
+	cfg := newConfig(options)
 	return &channelCollector{
-		nodes: nodes,
+		nodes:   nodes,
+		logger:  cfg.logger,
+		metrics: newNodeMetrics(cfg.metrics, "channel"),
+		tracer:  newTracer(cfg.tracerProvider),
 	}
 }
 
 func (c *channelCollector) CollectResultsForValue(value float64) model.CollectionResult {
-	// This is creating new context
-	ctx := context.TODO()
+	logger := c.logger.With(logging.Value(value))
+
+	// This is creating new context, traced as the collection
+	ctx, span := startCollection(c.tracer, "channel", value)
+	defer span.End()
 
 	// Create context which will time out after configured amount of time
 	ctxWithTimeout, cancelFunc := context.WithTimeout(ctx, time.Duration(5)*time.Second)
 	defer cancelFunc()
 	defer func() {
 		if ctxErr := ctxWithTimeout.Err(); ctxErr != nil {
-			fmt.Println(fmt.Errorf("Collect: Unexpected context error (adjust timeout accordingly): %v", ctxErr))
+			logger.Warn("Unexpected context error (adjust timeout accordingly)", logging.Err(ctxErr))
 		}
 	}()
 
@@ -35,20 +48,24 @@ func (c *channelCollector) CollectResultsForValue(value float64) model.Collectio
 	resultsChannel := make(chan model.CalculationOutput)
 
 	// Iterate over all devices
-	for _, node := range c.nodes {
+	for index, node := range c.nodes {
 
 		// Process each node in goroutine
-		go func(processingNode model.ProcessingNode) {
+		go func(nodeIndex int, processingNode model.ProcessingNode) {
 			// Covers special case if runtime.Goexit() is called
 			var err error = fmt.Errorf("goroutine exited before collection could finish")
 
 			var output *float64
+			started := time.Now()
+			calculationCtx, calculationSpan := startCalculation(ctxWithTimeout, c.tracer, nodeIndex)
 			defer (func() {
 				// If it panics in goroutine, we need to return error to channel
 				if panic := recover(); panic != nil {
-					fmt.Println("panicked in goroutine: %v \n\n %v", panic, string(debug.Stack()))
+					logger.Error("Panicked in goroutine", logging.Any("panic", panic), logging.Any("stack", string(debug.Stack())))
 					err = fmt.Errorf("PANIC: %v", panic)
 				}
+				c.metrics.calculated(nodeIndex, started, err)
+				endCalculation(calculationSpan, err)
 
 				resultsChannel <- model.CalculationOutput{
 					Result: output,
@@ -56,8 +73,8 @@ func (c *channelCollector) CollectResultsForValue(value float64) model.Collectio
 				}
 			})()
 
-			output, err = processingNode.Calculate(ctxWithTimeout, model.CalculationInput{InputValue: value})
-		}(node)
+			output, err = processingNode.Calculate(calculationCtx, model.CalculationInput{InputValue: value})
+		}(index, node)
 	}
 
 	collectedResults := model.CollectionResult{}
@@ -68,13 +85,13 @@ func (c *channelCollector) CollectResultsForValue(value float64) model.Collectio
 		collectedResults = append(collectedResults, result)
 		if result.Error != nil {
 			numberOfFailed++
-			fmt.Println(fmt.Sprintf("Result not gathered, error: %v", result.Error))
+			logger.Warn("Result not gathered", logging.Err(result.Error))
 		} else {
 			numberOfSuccessful++
-			fmt.Println(fmt.Sprintf("Result: %v", *result.Result))
+			logger.Debug("Result gathered", logging.Any("result", *result.Result))
 		}
 	}
-	fmt.Println(fmt.Sprintf("Collected results: %v, Collected errors: %v", numberOfSuccessful, numberOfFailed))
+	logger.Info("Collected results", logging.Any("successful", numberOfSuccessful), logging.Any("failed", numberOfFailed))
 
 	return collectedResults
 }
@@ -2,33 +2,46 @@ package collector
 
 import (
 	"AwesomePresentation/3_worker_pool/model"
+	"AwesomePresentation/logging"
 	"context"
 	"fmt"
+	"go.opentelemetry.io/otel/trace"
 	"runtime/debug"
 	"sync"
 	"time"
 )
 
 type lockingCollector struct {
-	nodes []model.ProcessingNode
+	nodes   []model.ProcessingNode
+	logger  logging.Logger
+	metrics nodeMetrics
+	tracer  trace.Tracer
 }
 
-func NewLockingCollector(nodes []model.ProcessingNode) model.Collector {
+// By default nothing is logged, recorded or traced, see `Option`
+func NewLockingCollector(nodes []model.ProcessingNode, options ...Option) model.Collector {
+	cfg := newConfig(options)
 	return &lockingCollector{
-		nodes: nodes,
+		nodes:   nodes,
+		logger:  cfg.logger,
+		metrics: newNodeMetrics(cfg.metrics, "locking"),
+		tracer:  newTracer(cfg.tracerProvider),
 	}
 }
 
 func (c *lockingCollector) CollectResultsForValue(value float64) model.CollectionResult {
-	// This is creating new context
-	ctx := context.TODO()
+	logger := c.logger.With(logging.Value(value))
+
+	// This is creating new context, traced as the collection
+	ctx, span := startCollection(c.tracer, "locking", value)
+	defer span.End()
 
 	// Create context which will time out after configured amount of time
 	ctxWithTimeout, cancelFunc := context.WithTimeout(ctx, time.Duration(5)*time.Second)
 	defer cancelFunc()
 	defer func() {
 		if ctxErr := ctxWithTimeout.Err(); ctxErr != nil {
-			fmt.Println(fmt.Errorf("Collect: Unexpected context error (adjust timeout accordingly): %v", ctxErr))
+			logger.Warn("Unexpected context error (adjust timeout accordingly)", logging.Err(ctxErr))
 		}
 	}()
 
@@ -39,10 +52,10 @@ func (c *lockingCollector) CollectResultsForValue(value float64) model.Collectio
 	lock := &sync.Mutex{}
 
 	// Iterate over all devices
-	for _, node := range c.nodes {
+	for index, node := range c.nodes {
 
 		// Process each node in goroutine
-		go func(processingNode model.ProcessingNode,
+		go func(nodeIndex int, processingNode model.ProcessingNode,
 			sharedLock *sync.Mutex,
 			sharedNumberOfSuccessful *int,
 			sharedNumberOfFailed *int) {
@@ -50,12 +63,16 @@ func (c *lockingCollector) CollectResultsForValue(value float64) model.Collectio
 			var err error = fmt.Errorf("goroutine exited before collection could finish")
 
 			var output *float64
+			started := time.Now()
+			calculationCtx, calculationSpan := startCalculation(ctxWithTimeout, c.tracer, nodeIndex)
 			defer (func() {
 				// If it panics in goroutine, we need to return error to channel
 				if panic := recover(); panic != nil {
-					fmt.Println("panicked in goroutine: %v \n\n %v", panic, string(debug.Stack()))
+					logger.Error("Panicked in goroutine", logging.Any("panic", panic), logging.Any("stack", string(debug.Stack())))
 					err = fmt.Errorf("PANIC: %v", panic)
 				}
+				c.metrics.calculated(nodeIndex, started, err)
+				endCalculation(calculationSpan, err)
 
 				calculationOutputResult := model.CalculationOutput{
 					Result: output,
@@ -72,8 +89,8 @@ func (c *lockingCollector) CollectResultsForValue(value float64) model.Collectio
 				}
 			})()
 
-			output, err = processingNode.Calculate(ctxWithTimeout, model.CalculationInput{InputValue: value})
-		}(node, lock, &numberOfSuccessful, &numberOfFailed)
+			output, err = processingNode.Calculate(calculationCtx, model.CalculationInput{InputValue: value})
+		}(index, node, lock, &numberOfSuccessful, &numberOfFailed)
 	}
 
 	for {
@@ -83,7 +100,7 @@ func (c *lockingCollector) CollectResultsForValue(value float64) model.Collectio
 		lock.Unlock()
 
 		if numberOfResultsCollected < len(c.nodes) {
-			fmt.Println(fmt.Sprintf("Not all results collected yet: %v", numberOfResultsCollected))
+			logger.Debug("Not all results collected yet", logging.Any("collected", numberOfResultsCollected))
 		} else {
 			break
 		}
@@ -91,7 +108,7 @@ func (c *lockingCollector) CollectResultsForValue(value float64) model.Collectio
 		time.Sleep(1 * time.Second)
 	}
 
-	fmt.Println(fmt.Sprintf("Collected results: %v, Collected errors: %v", numberOfSuccessful, numberOfFailed))
+	logger.Info("Collected results", logging.Any("successful", numberOfSuccessful), logging.Any("failed", numberOfFailed))
 
 	return collectedResults
 }
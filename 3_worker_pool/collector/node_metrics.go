@@ -0,0 +1,37 @@
+package collector
+
+import (
+	"AwesomePresentation/metrics"
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// nodeMetrics are recorded for every calculation of a node, labelled by the collector and index of the node
+type nodeMetrics struct {
+	collector string
+	duration  *metrics.Histogram
+	timeouts  *metrics.Counter
+}
+
+// Nil registry means nothing is recorded
+func newNodeMetrics(registry *metrics.Registry, collector string) nodeMetrics {
+	return nodeMetrics{
+		collector: collector,
+		duration: registry.NewHistogram("collector_node_duration_seconds",
+			"How long calculations of processing nodes took, timed out ones included.",
+			[]float64{0.5, 1, 2, 3, 4, 5, 7.5, 10}, "collector", "node"),
+		timeouts: registry.NewCounter("collector_node_timeouts_total",
+			"Calculations of processing nodes which didn't finish before the collection timed out.", "collector", "node"),
+	}
+}
+
+// Records calculation of the node which started at the time and ended with the error
+func (m nodeMetrics) calculated(node int, started time.Time, err error) {
+	nodeLabel := strconv.Itoa(node)
+	m.duration.Observe(time.Since(started).Seconds(), m.collector, nodeLabel)
+	if errors.Is(err, context.DeadlineExceeded) {
+		m.timeouts.Inc(m.collector, nodeLabel)
+	}
+}
@@ -0,0 +1,37 @@
+package collector
+
+import (
+	"AwesomePresentation/tracing"
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"strconv"
+)
+
+// Name of the tracer collectors trace with
+const tracerName = "AwesomePresentation/3_worker_pool/collector"
+
+// Nil provider means nothing is traced
+func newTracer(provider trace.TracerProvider) trace.Tracer {
+	return tracing.OrNoop(provider).Tracer(tracerName)
+}
+
+// Starts span of the collection for the value, calculations of the nodes are its children
+func startCollection(tracer trace.Tracer, collector string, value float64) (context.Context, trace.Span) {
+	return tracer.Start(context.TODO(), "collector.collect",
+		trace.WithAttributes(tracing.Value(value), attribute.String("collector", collector)))
+}
+
+// Starts span of calculation of the node, child of the collection in the context
+func startCalculation(ctx context.Context, tracer trace.Tracer, node int) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "node.calculate", trace.WithAttributes(tracing.Node(strconv.Itoa(node))))
+}
+
+// Ends span of the calculation which ended with the error
+func endCalculation(span trace.Span, err error) {
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"AwesomePresentation/logging"
+	"AwesomePresentation/metrics"
+	"AwesomePresentation/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Option configures collectors created by this package, every collector takes the same options
+type Option func(*config)
+
+type config struct {
+	logger         logging.Logger
+	metrics        *metrics.Registry
+	tracerProvider trace.TracerProvider
+}
+
+func newConfig(options []Option) config {
+	cfg := config{
+		logger:         logging.Nop(),
+		tracerProvider: noop.NewTracerProvider(),
+	}
+
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	return cfg
+}
+
+// WithLogger sets where collectors log to. By default nothing is logged.
+func WithLogger(logger logging.Logger) Option {
+	return func(c *config) {
+		c.logger = logging.OrNop(logger)
+	}
+}
+
+// WithMetrics sets registry collectors record latency and timeouts of the nodes in. By default nothing
+// is recorded.
+func WithMetrics(registry *metrics.Registry) Option {
+	return func(c *config) {
+		c.metrics = registry
+	}
+}
+
+// WithTracerProvider sets provider of the tracer collectors trace with. Every collection is a span and
+// calculations of the nodes are its children. By default nothing is traced.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tracing.OrNoop(provider)
+	}
+}
@@ -2,33 +2,46 @@ package collector
 
 import (
 	"AwesomePresentation/3_worker_pool/model"
+	"AwesomePresentation/logging"
 	"context"
 	"fmt"
+	"go.opentelemetry.io/otel/trace"
 	"runtime/debug"
 	"sync"
 	"time"
 )
 
 type waitGroupCollector struct {
-	nodes []model.ProcessingNode
+	nodes   []model.ProcessingNode
+	logger  logging.Logger
+	metrics nodeMetrics
+	tracer  trace.Tracer
 }
 
-func NewWaitGroupCollector(nodes []model.ProcessingNode) model.Collector {
+// By default nothing is logged, recorded or traced, see `Option`
+func NewWaitGroupCollector(nodes []model.ProcessingNode, options ...Option) model.Collector {
+	cfg := newConfig(options)
 	return &waitGroupCollector{
-		nodes: nodes,
+		nodes:   nodes,
+		logger:  cfg.logger,
+		metrics: newNodeMetrics(cfg.metrics, "waitgroup"),
+		tracer:  newTracer(cfg.tracerProvider),
 	}
 }
 
 func (c *waitGroupCollector) CollectResultsForValue(value float64) model.CollectionResult {
-	// This is creating new context
-	ctx := context.TODO()
+	logger := c.logger.With(logging.Value(value))
+
+	// This is creating new context, traced as the collection
+	ctx, span := startCollection(c.tracer, "waitgroup", value)
+	defer span.End()
 
 	// Create context which will time out after configured amount of time
 	ctxWithTimeout, cancelFunc := context.WithTimeout(ctx, time.Duration(5)*time.Second)
 	defer cancelFunc()
 	defer func() {
 		if ctxErr := ctxWithTimeout.Err(); ctxErr != nil {
-			fmt.Println(fmt.Errorf("Collect: Unexpected context error (adjust timeout accordingly): %v", ctxErr))
+			logger.Warn("Unexpected context error (adjust timeout accordingly)", logging.Err(ctxErr))
 		}
 	}()
 
@@ -43,10 +56,10 @@ func (c *waitGroupCollector) CollectResultsForValue(value float64) model.Collect
 	waitGroup.Add(len(c.nodes))
 
 	// Iterate over all devices
-	for _, node := range c.nodes {
+	for index, node := range c.nodes {
 
 		// Process each node in goroutine
-		go func(processingNode model.ProcessingNode,
+		go func(nodeIndex int, processingNode model.ProcessingNode,
 			sharedLock *sync.Mutex,
 			sharedWaitGroup *sync.WaitGroup,
 			sharedNumberOfSuccessful *int,
@@ -55,14 +68,18 @@ func (c *waitGroupCollector) CollectResultsForValue(value float64) model.Collect
 			var err error = fmt.Errorf("goroutine exited before collection could finish")
 
 			var output *float64
+			started := time.Now()
+			calculationCtx, calculationSpan := startCalculation(ctxWithTimeout, c.tracer, nodeIndex)
 			defer (func() {
 				defer sharedWaitGroup.Done()
 
 				// If it panics in goroutine, we need to return error to channel
 				if panic := recover(); panic != nil {
-					fmt.Println("panicked in goroutine: %v \n\n %v", panic, string(debug.Stack()))
+					logger.Error("Panicked in goroutine", logging.Any("panic", panic), logging.Any("stack", string(debug.Stack())))
 					err = fmt.Errorf("PANIC: %v", panic)
 				}
+				c.metrics.calculated(nodeIndex, started, err)
+				endCalculation(calculationSpan, err)
 
 				calculationOutputResult := model.CalculationOutput{
 					Result: output,
@@ -79,12 +96,12 @@ func (c *waitGroupCollector) CollectResultsForValue(value float64) model.Collect
 				}
 			})()
 
-			output, err = processingNode.Calculate(ctxWithTimeout, model.CalculationInput{InputValue: value})
-		}(node, lock, waitGroup, &numberOfSuccessful, &numberOfFailed)
+			output, err = processingNode.Calculate(calculationCtx, model.CalculationInput{InputValue: value})
+		}(index, node, lock, waitGroup, &numberOfSuccessful, &numberOfFailed)
 	}
 
 	waitGroup.Wait()
-	fmt.Println(fmt.Sprintf("Collected results: %v, Collected errors: %v", numberOfSuccessful, numberOfFailed))
+	logger.Info("Collected results", logging.Any("successful", numberOfSuccessful), logging.Any("failed", numberOfFailed))
 
 	return collectedResults
 }
@@ -4,23 +4,32 @@ import (
 	"AwesomePresentation/3_worker_pool/collector"
 	"AwesomePresentation/3_worker_pool/model"
 	"AwesomePresentation/3_worker_pool/node"
+	"AwesomePresentation/logging"
+	"AwesomePresentation/metrics"
 	"fmt"
+	"log/slog"
+	"os"
 	"time"
 )
 
 func main() {
+	// Nodes and collectors log everything they do
+	logger := logging.NewSlogLogger(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	// Latency and timeouts of the nodes, printed once all rounds are done
+	registry := metrics.NewRegistry()
+
 	nodes := []model.ProcessingNode{
-		node.NewDivideProcessingNode(1),
-		node.NewDivideProcessingNode(2),
-		node.NewDivideProcessingNode(3),
-		node.NewDivideProcessingNode(3),
-		node.NewMultiplyProcessingNode(3),
-		node.NewMultiplyProcessingNode(2),
-		node.NewMultiplyProcessingNode(1),
+		node.NewDivideProcessingNode(1, logger),
+		node.NewDivideProcessingNode(2, logger),
+		node.NewDivideProcessingNode(3, logger),
+		node.NewDivideProcessingNode(3, logger),
+		node.NewMultiplyProcessingNode(3, logger),
+		node.NewMultiplyProcessingNode(2, logger),
+		node.NewMultiplyProcessingNode(1, logger),
 	}
-	//resultsCollector := collector.NewLockingCollector(nodes)
-	//resultsCollector := collector.NewWaitGroupCollector(nodes)
-	resultsCollector := collector.NewChannelCollector(nodes)
+	//resultsCollector := collector.NewLockingCollector(nodes, collector.WithLogger(logger), collector.WithMetrics(registry))
+	//resultsCollector := collector.NewWaitGroupCollector(nodes, collector.WithLogger(logger), collector.WithMetrics(registry))
+	resultsCollector := collector.NewChannelCollector(nodes, collector.WithLogger(logger), collector.WithMetrics(registry))
 
 	resultsCollector.CollectResultsForValue(1)
 	fmt.Print("\nFinished Round 1\n\n\n") // Finish, round 1
@@ -31,6 +40,8 @@ func main() {
 	time.Sleep(time.Duration(5) * time.Second)
 
 	resultsCollector.CollectResultsForValue(3)
-	fmt.Print("\nFinished Round 3\n\n\n")   // Finish, round 3
+	fmt.Print("\nFinished Round 3\n\n\n") // Finish, round 3
+
+	_ = registry.WriteText(os.Stdout)
 	fmt.Print("\n\nThank You and goodbye!") // Graceful finish :))
 }
@@ -2,6 +2,7 @@ package node
 
 import (
 	"AwesomePresentation/3_worker_pool/model"
+	"AwesomePresentation/logging"
 	"context"
 	"fmt"
 	"math"
@@ -12,11 +13,14 @@ import (
 // The purpose of this struct/object is to calculate value and output result divided by the `factor`
 type divideProcessingNode struct {
 	factor float64
+	logger logging.Logger
 }
 
-func NewDivideProcessingNode(factor float64) model.ProcessingNode {
+// Nil logger means nothing is logged
+func NewDivideProcessingNode(factor float64, logger logging.Logger) model.ProcessingNode {
 	return &divideProcessingNode{
 		factor: factor,
+		logger: logging.OrNop(logger).With(logging.Node("divide"), logging.Any("factor", factor)),
 	}
 }
 
@@ -24,18 +28,18 @@ func (p *divideProcessingNode) Calculate(ctx context.Context, input model.Calcul
 	randomTime := (rand.Float64() * 10) + 1
 	randomDuration := time.Duration(math.Abs(randomTime)) * time.Second
 
-	fmt.Println(fmt.Sprintf("Multiply Processing Node: Picked random time : %v, duration: %v", randomTime, randomDuration))
+	logger := p.logger.With(logging.Value(input.InputValue))
+	logger.Debug("Picked random time", logging.Any("randomTime", randomTime), logging.Any("duration", randomDuration))
 	tick := time.NewTicker(randomDuration)
 
 	select {
 	case <-tick.C:
 		result := input.InputValue / p.factor
-		fmt.Println(fmt.Sprintf("Divide Processing Node: Returning : %v", result))
+		logger.Info("Returning result", logging.Any("result", result))
 
 		return &result, nil
 	case <-ctx.Done():
-		msg := "Divide Processing Node: Timed out"
-		fmt.Println(msg)
-		return nil, fmt.Errorf(msg)
+		logger.Warn("Timed out")
+		return nil, fmt.Errorf("Divide Processing Node: Timed out: %w", ctx.Err())
 	}
 }
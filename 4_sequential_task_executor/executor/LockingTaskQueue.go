@@ -1,93 +1,263 @@
 package executor
 
 import (
-	"fmt"
-	"github.com/google/uuid"
+	"AwesomePresentation/logging"
+	"context"
 	"sync"
+	"time"
 )
 
 type lockingTaskQueue struct {
-	tasks []Task
+	tasks *taskLedger
+
+	drainOnShutdown bool
+	logger          logging.Logger
 
 	lock sync.Mutex
+	// Signalled when task is pushed, broadcasted when the queue closes
+	available *sync.Cond
+}
+
+func NewLockingTaskQueue(options ...Option) TaskQueue {
+	cfg := newConfig(options)
+	queue := newLockingTaskQueue(cfg)
+	queue.tasks.unregisterMetrics = registerQueueMetrics(cfg.metrics, queue)
+
+	return queue
 }
 
-func NewLockingTaskQueue() TaskQueue {
+func newLockingTaskQueue(cfg config) *lockingTaskQueue {
 	queue := &lockingTaskQueue{
-		tasks: make([]Task, 0),
-		lock:  sync.Mutex{},
+		tasks:           newTaskLedger(cfg),
+		drainOnShutdown: cfg.drainOnShutdown,
+		logger:          cfg.logger,
+		lock:            sync.Mutex{},
 	}
+	queue.available = sync.NewCond(&queue.lock)
 
 	return queue
 }
 
+// NewLockingPriorityTaskQueue creates mutex based queue which pops tasks with the highest `Priority` first.
+// Tasks of equal priority are popped in the order they were pushed.
+func NewLockingPriorityTaskQueue(options ...Option) TaskQueue {
+	return NewLockingTaskQueue(append([]Option{withPriorityOrdering()}, options...)...)
+}
+
 func (q *lockingTaskQueue) Pop() *Task {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	fmt.Println(fmt.Sprintf("queue called pop"))
-	if len(q.tasks) == 0 {
-		fmt.Println(fmt.Errorf("queue called pop with empty queue"))
+	q.logger.Debug("queue called pop")
+	if q.tasks.length() == 0 {
+		q.logger.Debug("queue called pop with empty queue")
 		return nil
 	}
 
-	firstTask := q.tasks[0]
-	q.tasks = q.tasks[1:]
-
-	return &firstTask
+	return q.tasks.pop(0)
 }
 
-func (q *lockingTaskQueue) Push(task Task) string {
+func (q *lockingTaskQueue) PopContext(ctx context.Context) (*Task, error) {
+	// sync.Cond knows nothing about contexts, so waiting goroutines are woken up once the context is done
+	if ctx.Done() != nil {
+		stopWaking := make(chan struct{})
+		defer close(stopWaking)
+
+		go func() {
+			select {
+			case <-ctx.Done():
+				q.lock.Lock()
+				q.available.Broadcast()
+				q.lock.Unlock()
+			case <-stopWaking:
+			}
+		}()
+	}
+
 	q.lock.Lock()
 	defer q.lock.Unlock()
-	fmt.Println(fmt.Sprintf("queue called enqueue"))
+	q.logger.Debug("queue called blocking pop")
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if q.tasks.length() > 0 {
+			return q.tasks.pop(WorkerFromContext(ctx)), nil
+		}
+		if q.tasks.closed {
+			return nil, ErrQueueClosed
+		}
+
+		q.waitForTask()
+	}
+}
+
+// Waits until a task is pushed, queue closes or the next scheduled task becomes due. Must hold the lock.
+func (q *lockingTaskQueue) waitForTask() {
+	dueAt, scheduled := q.tasks.nextDue()
+	if !scheduled {
+		q.available.Wait()
+		return
+	}
+
+	dueTimer := time.AfterFunc(time.Until(dueAt), func() {
+		q.lock.Lock()
+		q.available.Broadcast()
+		q.lock.Unlock()
+	})
+	q.available.Wait()
+	dueTimer.Stop()
+}
+
+func (q *lockingTaskQueue) Push(task Task) (string, error) {
+	return q.PushAt(task, time.Time{})
+}
 
-	generatedTaskId, err := uuid.NewRandom()
+func (q *lockingTaskQueue) PushAfter(task Task, delay time.Duration) (string, error) {
+	return q.PushAt(task, time.Now().Add(delay))
+}
+
+func (q *lockingTaskQueue) PushAt(task Task, dueAt time.Time) (string, error) {
+	handle, err := q.submitAt(task, dueAt)
 	if err != nil {
-		fmt.Println(fmt.Errorf("failed to generate uuid for operation: %w", err))
+		return "", err
+	}
+	return handle.Id(), nil
+}
+
+func (q *lockingTaskQueue) Submit(task Task) (*TaskHandle, error) {
+	return q.submitAt(task, time.Time{})
+}
+
+func (q *lockingTaskQueue) submitAt(task Task, dueAt time.Time) (*TaskHandle, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.logger.Debug("queue called enqueue")
+
+	// full queue with `OverflowBlock` makes the push wait, without holding the lock
+	for spaceFreed, full := q.tasks.waitForSpace(task); full; spaceFreed, full = q.tasks.waitForSpace(task) {
+		q.lock.Unlock()
+		<-spaceFreed
+		q.lock.Lock()
 	}
 
-	taskIdString := generatedTaskId.String()
-	copiedTask := task
-	copiedTask.Id = taskIdString
+	// waiting pops either take the task or start waiting for it to become due
+	handle, err := q.tasks.enqueue(task, dueAt)
+	if err == nil {
+		q.available.Signal()
+	}
+	return handle, err
+}
 
-	q.tasks = append(q.tasks, copiedTask)
+func (q *lockingTaskQueue) Complete(id string, err error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.logger.Debug("queue called complete")
 
-	return taskIdString
+	q.tasks.complete(id, err)
+	// failed task might have been scheduled for a retry, waiting pops need to know when it's due
+	q.available.Broadcast()
+}
+
+func (q *lockingTaskQueue) Cancel(id string) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.logger.Debug("queue called cancel")
+
+	return q.tasks.cancelTask(id)
 }
 
 func (q *lockingTaskQueue) List() []TaskInfo {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	fmt.Println(fmt.Sprintf("queue called get list of tasks"))
+	q.logger.Debug("queue called get list of tasks")
+
+	return q.tasks.list()
+}
+
+func (q *lockingTaskQueue) Get(id string) *TaskInfo {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.logger.Debug("queue called get task by id")
 
-	tasksCopy := make([]TaskInfo, 0)
-	for _, task := range q.tasks {
-		tasksCopy = append(tasksCopy, TaskInfo{
-			Id: task.Id,
-		})
+	returnedTask := q.tasks.get(id)
+	if returnedTask != nil {
+		q.logger.Debug("get task by id - found", logging.TaskId(id))
 	}
 
-	return tasksCopy
+	return returnedTask
 }
 
-func (q *lockingTaskQueue) Get(id string) *TaskInfo {
+func (q *lockingTaskQueue) DeadLetters() []TaskInfo {
 	q.lock.Lock()
 	defer q.lock.Unlock()
-	fmt.Println(fmt.Sprintf("queue called get task by id"))
+	q.logger.Debug("queue called get dead letters")
 
-	var returnedTask *TaskInfo = nil
-	for _, taskInQueue := range q.tasks {
-		if taskInQueue.Id == id {
-			returnedTask = &TaskInfo{
-				Id: taskInQueue.Id,
-			}
+	return q.tasks.listDeadLetters()
+}
+
+func (q *lockingTaskQueue) Requeue(id string) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.logger.Debug("queue called requeue")
+
+	err := q.tasks.requeue(id)
+	if err == nil {
+		q.available.Signal()
+	}
+	return err
+}
 
-			fmt.Println(fmt.Sprintf("get task by id - found"))
-			break
+func (q *lockingTaskQueue) Stats() QueueStats {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.logger.Debug("queue called get stats")
+
+	return q.tasks.stats()
+}
+
+func (q *lockingTaskQueue) Subscribe(ctx context.Context) <-chan TaskInfo {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.logger.Debug("queue called subscribe")
+
+	subscription := q.tasks.subscribe()
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.lock.Lock()
+			q.tasks.unsubscribe(subscription)
+			q.lock.Unlock()
+		case <-subscription.done:
 		}
+	}()
+
+	return subscription.events
+}
+
+func (q *lockingTaskQueue) Shutdown(ctx context.Context) error {
+	q.lock.Lock()
+	q.logger.Debug("queue called shutdown", logging.Any("drain", q.drainOnShutdown))
+	drained := q.tasks.close(q.drainOnShutdown)
+	// nothing is going to be pushed anymore, blocked pops should notice that
+	q.available.Broadcast()
+	q.lock.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
 
-	return returnedTask
+func (q *lockingTaskQueue) Stop() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.logger.Debug("queue called stop")
+
+	q.tasks.abort()
+	q.available.Broadcast()
 }
@@ -1,60 +1,300 @@
 package executor
 
 import (
-	"fmt"
+	"AwesomePresentation/logging"
+	"AwesomePresentation/tracing"
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"runtime/debug"
+	"sync"
 	"time"
 )
 
 type Executor struct {
 	queue TaskQueue
+
+	// Cancelling the context makes all worker goroutines exit, `done` is closed once they did
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	lock sync.Mutex
+	// Active workers, the most recently started last. Retired workers are removed right away,
+	// even though they might still be finishing their task
+	workers      []*worker
+	lastWorkerId int
+	stopped      bool
+	// Counts worker goroutines which haven't exited yet, retired ones included
+	running sync.WaitGroup
+
+	logger  logging.Logger
+	metrics executorMetrics
+	tracer  trace.Tracer
+
+	// Applied to every executed task, see `WithMiddleware` and `WithHooks`
+	middleware []Middleware
+	hooks      []Hooks
+
+	// See `WithTaskTimeout` and `WithTimeoutPolicy`
+	taskTimeout   time.Duration
+	timeoutPolicy TimeoutPolicy
 }
 
-func NewChannelQueueExecutor() (TaskQueue, Executor) {
-	taskQueue := NewTaskQueue()
+// worker is a goroutine executing tasks one by one, cancelling its context retires it
+type worker struct {
+	id     int
+	cancel context.CancelFunc
+}
+
+func NewChannelQueueExecutor(options ...Option) (TaskQueue, *Executor) {
+	taskQueue := NewTaskQueue(options...)
 	// At this point queue is already running
 
-	executor := Executor{queue: taskQueue}
-	go executor.runExecutor()
-	// Starting executor
+	return taskQueue, NewExecutor(taskQueue, options...)
+}
 
-	return taskQueue, executor
+func NewLockingQueueExecutor(options ...Option) (TaskQueue, *Executor) {
+	taskQueue := NewLockingTaskQueue(options...)
+
+	return taskQueue, NewExecutor(taskQueue, options...)
 }
 
-func NewLockingQueueExecutor() (TaskQueue, Executor) {
-	taskQueue := NewLockingTaskQueue()
+// NewExecutor starts executing tasks from any queue, e.g. one created with `NewPriorityTaskQueue`.
+// Executor owns the queue from now on - shutting down the executor shuts down the queue as well.
+//
+// Tasks are executed by a single worker goroutine, so they run one by one in the order of the queue.
+// `WithWorkers` starts more of them - tasks are still popped in order, but run concurrently then.
+func NewExecutor(queue TaskQueue, options ...Option) *Executor {
+	cfg := newConfig(options)
+	ctx, cancel := context.WithCancel(context.Background())
+	executor := &Executor{
+		queue:   queue,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		workers: make([]*worker, 0, cfg.workers),
+		logger:  cfg.logger,
+		metrics: newExecutorMetrics(cfg.metrics),
+		tracer:  cfg.tracerProvider.Tracer(tracerName),
+
+		middleware: cfg.middleware,
+		hooks:      cfg.hooks,
+
+		taskTimeout:   cfg.taskTimeout,
+		timeoutPolicy: cfg.timeoutPolicy,
+	}
 
-	executor := Executor{queue: taskQueue}
-	go executor.runExecutor()
 	// Starting executor
+	executor.Resize(cfg.workers)
+
+	return executor
+}
+
+// Resize changes the number of workers, at least one is always kept. New workers start popping tasks right
+// away, retired ones finish their current task first. Does nothing once the executor is shutting down.
+func (e *Executor) Resize(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.stopped {
+		return
+	}
+	e.logger.Info("executor resized", logging.Any("from", len(e.workers)), logging.Any("to", workers))
+
+	for len(e.workers) < workers {
+		e.lastWorkerId++
+		workerCtx, cancel := context.WithCancel(e.ctx)
+		started := &worker{id: e.lastWorkerId, cancel: cancel}
+		e.workers = append(e.workers, started)
+
+		e.running.Add(1)
+		go e.runWorker(workerCtx, started.id)
+	}
+
+	for len(e.workers) > workers {
+		retired := e.workers[len(e.workers)-1]
+		e.workers = e.workers[:len(e.workers)-1]
+		retired.cancel()
+	}
+}
+
+// Workers returns the current number of workers
+func (e *Executor) Workers() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return len(e.workers)
+}
+
+// Shutdown stops the queue from accepting new tasks, lets the running tasks finish (and the queued ones too,
+// unless created with `WithDrainOnShutdown(false)`) and then stops the worker goroutines. If the context
+// is done first its error is returned and the executor keeps running - use `Stop` to abort it.
+func (e *Executor) Shutdown(ctx context.Context) error {
+	if err := e.queue.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	// drained queue makes the workers exit on their own, this is just to be sure
+	e.stopWorkers()
+
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop cancels all queued tasks and the running ones, then waits for the worker goroutines to exit.
+// Executable which ignores its context is still awaited, because it occupies its worker goroutine.
+func (e *Executor) Stop() {
+	e.queue.Stop()
+	e.stopWorkers()
+
+	<-e.done
+}
+
+// Makes all workers exit and closes `done` once they did. No more workers are started afterwards.
+func (e *Executor) stopWorkers() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.stopped {
+		return
+	}
+
+	e.stopped = true
+	e.workers = e.workers[:0]
+	e.cancel()
+
+	go func() {
+		e.running.Wait()
+		close(e.done)
+	}()
+}
+
+// Runs the task, turning its panic into an error - one misbehaving task must not stop the executor
+func (e *Executor) execute(task *Task, workerId int) (err error) {
+	// span is a child of the task's trace context, which the queue has put into the task's context
+	ctx, span := e.tracer.Start(task.context(), "executor.execute",
+		trace.WithAttributes(tracing.TaskId(task.Id), tracing.Worker(workerId)))
+	defer (func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	})()
+
+	e.runHooks(task, func(hooks Hooks) {
+		if hooks.OnStart != nil {
+			hooks.OnStart(ctx, *task)
+		}
+	})
 
-	return taskQueue, executor
+	err = e.runWithTimeout(ctx, task)
+
+	var panicErr *PanicError
+	e.runHooks(task, func(hooks Hooks) {
+		switch {
+		case errors.As(err, &panicErr):
+			if hooks.OnPanic != nil {
+				hooks.OnPanic(ctx, *task, panicErr)
+			}
+		case err != nil:
+			if hooks.OnFailure != nil {
+				hooks.OnFailure(ctx, *task, err)
+			}
+		default:
+			if hooks.OnSuccess != nil {
+				hooks.OnSuccess(ctx, *task)
+			}
+		}
+	})
+	return err
 }
 
-func (e *Executor) runExecutor() {
-	var task *Task
+// Runs executable of the task wrapped in the middleware, turning its panic into an error
+func (e *Executor) run(ctx context.Context, task *Task) (err error) {
 	defer (func() {
 		if panic := recover(); panic != nil {
-			fmt.Println(fmt.Errorf("executor goroutine panicked: %v \n\n %v", panic, string(debug.Stack())))
+			stack := string(debug.Stack())
+			e.logger.Warn("task panicked", logging.TaskId(task.Id), logging.Any("panic", panic), logging.Any("stack", stack))
+			err = &PanicError{Value: panic, Stack: stack}
 		}
 	})()
 
-	for {
-		task = e.queue.Pop()
+	// context is cancelled when somebody calls `Cancel` with id of the task
+	return AdaptExecutable(applyMiddleware(task.TaskExecutable, e.middleware)).ExecuteContext(ctx)
+}
 
-		if task != nil {
-			fmt.Println(fmt.Sprintf("found task: %v", task))
+// Calls the hook of every set of hooks in order. Panicking hook is logged, it doesn't affect the task.
+func (e *Executor) runHooks(task *Task, call func(hooks Hooks)) {
+	for _, hooks := range e.hooks {
+		func() {
+			defer (func() {
+				if panic := recover(); panic != nil {
+					e.logger.Error("task hook panicked", logging.TaskId(task.Id), logging.Any("panic", panic),
+						logging.Any("stack", string(debug.Stack())))
+				}
+			})()
+			call(hooks)
+		}()
+	}
+}
 
-			// TODO: consider passing cancellable context
-			err := task.TaskExecutable.Execute()
-			if err != nil {
-				fmt.Println(fmt.Errorf("finished execution of task with error: %v", err))
+func (e *Executor) runWorker(ctx context.Context, workerId int) {
+	// worker is considered finished only once the panic (if any) has been handled
+	defer e.running.Done()
+	logger := e.logger.With(logging.Worker(workerId))
+	defer (func() {
+		if panic := recover(); panic != nil {
+			logger.Error("worker goroutine panicked", logging.Any("panic", panic), logging.Any("stack", string(debug.Stack())))
+		}
+	})()
+
+	// queue learns from the context which worker popped the task, see `TaskInfo.Worker`
+	ctx = withWorker(ctx, workerId)
+	for {
+		// blocks until there is a task to execute
+		task, err := e.queue.PopContext(ctx)
+		if err != nil {
+			if errors.Is(err, ErrQueueClosed) || errors.Is(err, context.Canceled) {
+				logger.Debug("worker stopped", logging.Err(err))
+				return
 			}
 
-			fmt.Println(fmt.Sprintf("finished execution of task: %v", task))
-		} else {
-			fmt.Println(fmt.Sprintf("Returned nil task, need to wait for task to appear"))
-			time.Sleep(1 * time.Second)
+			logger.Error("worker failed to pop task", logging.Err(err))
+			continue
 		}
+
+		logger.Debug("worker found task", logging.TaskId(task.Id))
+
+		started := time.Now()
+		err = e.execute(task, workerId)
+		e.metrics.executed(started, err)
+		if err != nil {
+			logger.Info("finished execution of task with error", logging.TaskId(task.Id), logging.Err(err))
+		}
+		e.queue.Complete(task.Id, err)
+
+		logger.Debug("worker finished execution of task", logging.TaskId(task.Id))
 	}
 }
+
+type workerContextKey struct{}
+
+func withWorker(ctx context.Context, workerId int) context.Context {
+	return context.WithValue(ctx, workerContextKey{}, workerId)
+}
+
+// WorkerFromContext returns id of the worker running the task, executables get it through the context passed
+// to `ExecuteContext`. Workers are numbered from 1 in the order they were started, zero means the task
+// wasn't popped by an executor.
+func WorkerFromContext(ctx context.Context) int {
+	workerId, _ := ctx.Value(workerContextKey{}).(int)
+	return workerId
+}
@@ -1,13 +1,15 @@
 package executor
 
 import (
+	"AwesomePresentation/logging"
+	"context"
 	"fmt"
-	"github.com/google/uuid"
 	"runtime/debug"
+	"time"
 )
 
 type taskQueue struct {
-	tasks []Task
+	tasks *taskLedger
 
 	requestChannel  chan queueRequest
 	responseChannel chan queueResponse
@@ -15,70 +17,239 @@ type taskQueue struct {
 	// We keep those separate because there are cases we're going to ignore the call
 	executorRequestChannel  chan queueRequest
 	executorResponseChannel chan queueResponse
+
+	drainOnShutdown bool
+	logger          logging.Logger
+
+	// Closed when the receiver goroutine exits. From then on nobody modifies `tasks` anymore
+	done       chan struct{}
+	terminated bool
 }
 type TaskQueue interface {
-	// Fetches task from queue if there is one present
+	// Fetches task from queue if there is one present, returns nil otherwise
 	Pop() *Task
 
-	// Pushes new task to the queue, task is copied in the method. Returns task id
-	Push(task Task) string
+	// Fetches task from queue, waiting for one to be pushed if the queue is empty. Returns context error if
+	// it's done first, or `ErrQueueClosed` once the queue is shut down and there is nothing left to pop
+	PopContext(ctx context.Context) (*Task, error)
+
+	// Pushes new task to the queue, task is copied in the method. Returns task id, or `ErrQueueClosed`
+	// if the queue has been shut down. Full queue might block or reject the task, see `WithCapacity`
+	Push(task Task) (string, error)
+
+	// Pushes new task which is going to be queued once `dueAt` passes. Until then it's listed as scheduled
+	// and can be cancelled. Tasks scheduled in the past are queued right away
+	PushAt(task Task, dueAt time.Time) (string, error)
+
+	// Pushes new task which is going to be queued after the delay, see `PushAt`
+	PushAfter(task Task, delay time.Duration) (string, error)
+
+	// Pushes new task like `Push`, but returns a handle which can be used to wait for the task to finish
+	Submit(task Task) (*TaskHandle, error)
 
-	// Lists all available tasks (creates copy of all tasks)
+	// Reports that popped task has finished. Error is the one returned by the task's executable
+	Complete(id string, err error)
+
+	// Removes queued task from the queue, or cancels context of the running one. Returns false if there
+	// was nothing to cancel (task is unknown or already finished)
+	Cancel(id string) bool
+
+	// Lists all tasks known to the queue - queued, running and recently finished ones (creates copy of all tasks)
 	List() []TaskInfo
 
 	// Fetches task that supposedly exists in the queue by it's ID. Might return nil if task wasn't found
+	// (or it has finished long enough ago to be forgotten)
 	Get(id string) *TaskInfo
+
+	// Lists failed tasks - the ones which ran out of retries or panicked - the oldest first
+	DeadLetters() []TaskInfo
+
+	// Pushes dead-lettered task back to the queue under the same id, with a fresh set of retry attempts.
+	// Returns `ErrNotDeadLettered` if the task isn't in the dead letter queue
+	Requeue(id string) error
+
+	// Streams a copy of the task info on every change of a task's state, until the context is done or the
+	// queue has stopped - the channel is closed then. Subscriber which doesn't keep up is unsubscribed (its
+	// channel is closed too) rather than missing changes, it can `Get` the tasks it's interested in instead
+	Subscribe(ctx context.Context) <-chan TaskInfo
+
+	// Returns current counters of the queue, e.g. how many tasks were rejected or dropped because it was full
+	Stats() QueueStats
+
+	// Stops accepting new tasks and waits until queued and running tasks finish. Queued tasks are cancelled
+	// instead, if the queue was created with `WithDrainOnShutdown(false)`. Returns context error when
+	// it's done before the queue has drained - the queue keeps draining, `Stop` can be used to abort it.
+	Shutdown(ctx context.Context) error
+
+	// Stops accepting new tasks and cancels all queued and running ones right away
+	Stop()
 }
 
-func NewTaskQueue() TaskQueue {
+func NewTaskQueue(options ...Option) TaskQueue {
+	cfg := newConfig(options)
 	taskQueue := &taskQueue{
-		tasks:                   make([]Task, 0),
+		tasks:                   newTaskLedger(cfg),
 		requestChannel:          make(chan queueRequest),
 		responseChannel:         make(chan queueResponse),
 		executorRequestChannel:  make(chan queueRequest),
 		executorResponseChannel: make(chan queueResponse),
+		drainOnShutdown:         cfg.drainOnShutdown,
+		logger:                  cfg.logger,
+		done:                    make(chan struct{}),
 	}
 
+	// registered before the queue runs, the ledger belongs to its goroutine then
+	taskQueue.tasks.unregisterMetrics = registerQueueMetrics(cfg.metrics, taskQueue)
 	go taskQueue.RunQueue()
 	return taskQueue
 }
 
+// NewPriorityTaskQueue creates channel based queue which pops tasks with the highest `Priority` first.
+// Tasks of equal priority are popped in the order they were pushed.
+func NewPriorityTaskQueue(options ...Option) TaskQueue {
+	return NewTaskQueue(append([]Option{withPriorityOrdering()}, options...)...)
+}
+
 func (q *taskQueue) Pop() *Task {
-	q.executorRequestChannel <- queueGetTaskRequest{}
+	select {
+	case q.requestChannel <- queueTryGetTaskRequest{}:
+	case <-q.done:
+		return nil
+	}
 
-	response := <-q.executorResponseChannel
+	response := <-q.responseChannel
 
-	var result Task
+	var result *Task
 
 	switch castedResponse := response.(type) {
 	case queueGetTaskResponse:
 		result = castedResponse.task
 	default:
-		fmt.Println(fmt.Errorf("failed to pop task, incorrect type"))
+		q.logger.Error("failed to pop task, incorrect type")
+	}
+
+	return result
+}
+
+func (q *taskQueue) PopContext(ctx context.Context) (*Task, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// receiver goroutine only accepts the request once it has something to respond with
+	select {
+	case q.executorRequestChannel <- queueGetTaskRequest{workerId: WorkerFromContext(ctx)}:
+	case <-q.done:
+		return nil, ErrQueueClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	response := <-q.executorResponseChannel
+
+	switch castedResponse := response.(type) {
+	case queueGetTaskResponse:
+		return castedResponse.task, castedResponse.err
+	default:
+		return nil, fmt.Errorf("failed to pop task, incorrect type")
+	}
+}
+
+func (q *taskQueue) Push(task Task) (string, error) {
+	return q.PushAt(task, time.Time{})
+}
+
+func (q *taskQueue) PushAfter(task Task, delay time.Duration) (string, error) {
+	return q.PushAt(task, time.Now().Add(delay))
+}
+
+func (q *taskQueue) PushAt(task Task, dueAt time.Time) (string, error) {
+	handle, err := q.submitAt(task, dueAt)
+	if err != nil {
+		return "", err
+	}
+	return handle.Id(), nil
+}
+
+func (q *taskQueue) Submit(task Task) (*TaskHandle, error) {
+	return q.submitAt(task, time.Time{})
+}
+
+func (q *taskQueue) submitAt(task Task, dueAt time.Time) (*TaskHandle, error) {
+	for {
+		select {
+		case q.requestChannel <- queueEnqueueTaskRequest{task: task, dueAt: dueAt}:
+		case <-q.done:
+			return nil, ErrQueueClosed
+		}
+
+		response := <-q.responseChannel
+
+		castedResponse, ok := response.(queueEnqueueTaskResponse)
+		if !ok {
+			err := fmt.Errorf("failed to push task, incorrect type")
+			q.logger.Error(err.Error())
+			return nil, err
+		}
+		if castedResponse.spaceFreed == nil {
+			return castedResponse.handle, castedResponse.err
+		}
+
+		// queue is full and the push has to wait, receiver goroutine can't do that for us
+		select {
+		case <-castedResponse.spaceFreed:
+		case <-q.done:
+			return nil, ErrQueueClosed
+		}
+	}
+}
+
+func (q *taskQueue) Complete(id string, err error) {
+	select {
+	case q.requestChannel <- queueCompleteTaskRequest{taskId: id, err: err}:
+	case <-q.done:
+		q.logger.Warn("queue is stopped, ignoring completion of task", logging.TaskId(id))
+		return
 	}
 
-	return &result
+	response := <-q.responseChannel
+
+	switch castedResponse := response.(type) {
+	case queueCompleteTaskResponse:
+	default:
+		q.logger.Error("failed to complete task, incorrect type", logging.Any("response", castedResponse))
+	}
 }
 
-func (q *taskQueue) Push(task Task) string {
-	q.requestChannel <- queueEnqueueTaskRequest{task: task}
+func (q *taskQueue) Cancel(id string) bool {
+	select {
+	case q.requestChannel <- queueCancelTaskRequest{taskId: id}:
+	case <-q.done:
+		// everything has been cancelled already when stopping
+		return false
+	}
 
 	response := <-q.responseChannel
 
-	var result string
+	var result bool
 
 	switch castedResponse := response.(type) {
-	case queueEnqueueTaskResponse:
-		result = castedResponse.taskId
+	case queueCancelTaskResponse:
+		result = castedResponse.cancelled
 	default:
-		fmt.Println(fmt.Errorf("failed to push task, incorrect type"))
+		q.logger.Error("failed to cancel task, incorrect type")
 	}
 
 	return result
 }
 
 func (q *taskQueue) List() []TaskInfo {
-	q.requestChannel <- queueGetListOfTasksRequest{}
+	select {
+	case q.requestChannel <- queueGetListOfTasksRequest{}:
+	case <-q.done:
+		// receiver goroutine is gone, it's safe to read the tasks directly
+		return q.tasks.list()
+	}
 
 	response := <-q.responseChannel
 
@@ -88,14 +259,19 @@ func (q *taskQueue) List() []TaskInfo {
 	case queueGetListOfTasksResponse:
 		result = castedResponse.tasks
 	default:
-		fmt.Println(fmt.Errorf("failed to fetch list of tasks, incorrect type"))
+		q.logger.Error("failed to fetch list of tasks, incorrect type")
 	}
 
 	return result
 }
 
 func (q *taskQueue) Get(id string) *TaskInfo {
-	q.requestChannel <- queueGetTaskByIdRequest{taskId: id}
+	select {
+	case q.requestChannel <- queueGetTaskByIdRequest{taskId: id}:
+	case <-q.done:
+		// receiver goroutine is gone, it's safe to read the tasks directly
+		return q.tasks.get(id)
+	}
 
 	response := <-q.responseChannel
 
@@ -105,23 +281,188 @@ func (q *taskQueue) Get(id string) *TaskInfo {
 	case queueGetTaskByIdResponse:
 		result = castedResponse.task
 	default:
-		fmt.Println(fmt.Errorf("failed to fetch task by id, incorrect type"))
+		q.logger.Error("failed to fetch task by id, incorrect type")
 	}
 
 	return result
 }
 
+func (q *taskQueue) DeadLetters() []TaskInfo {
+	select {
+	case q.requestChannel <- queueGetDeadLettersRequest{}:
+	case <-q.done:
+		// receiver goroutine is gone, it's safe to read the tasks directly
+		return q.tasks.listDeadLetters()
+	}
+
+	response := <-q.responseChannel
+
+	var result []TaskInfo
+
+	switch castedResponse := response.(type) {
+	case queueGetListOfTasksResponse:
+		result = castedResponse.tasks
+	default:
+		q.logger.Error("failed to fetch dead letters, incorrect type")
+	}
+
+	return result
+}
+
+func (q *taskQueue) Requeue(id string) error {
+	select {
+	case q.requestChannel <- queueRequeueTaskRequest{taskId: id}:
+	case <-q.done:
+		return ErrQueueClosed
+	}
+
+	response := <-q.responseChannel
+
+	var err error
+
+	switch castedResponse := response.(type) {
+	case queueRequeueTaskResponse:
+		err = castedResponse.err
+	default:
+		err = fmt.Errorf("failed to requeue task, incorrect type")
+		q.logger.Error(err.Error())
+	}
+
+	return err
+}
+
+func (q *taskQueue) Subscribe(ctx context.Context) <-chan TaskInfo {
+	select {
+	case q.requestChannel <- queueSubscribeRequest{}:
+	case <-q.done:
+		// nothing is going to change anymore
+		closed := make(chan TaskInfo)
+		close(closed)
+		return closed
+	}
+
+	response := <-q.responseChannel
+
+	castedResponse, ok := response.(queueSubscribeResponse)
+	if !ok {
+		q.logger.Error("failed to subscribe, incorrect type", logging.Any("response", response))
+		closed := make(chan TaskInfo)
+		close(closed)
+		return closed
+	}
+
+	subscription := castedResponse.subscription
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-subscription.done:
+			return
+		}
+
+		select {
+		case q.requestChannel <- queueUnsubscribeRequest{subscription: subscription}:
+			<-q.responseChannel
+		case <-q.done:
+			// subscriptions have been closed when the queue stopped
+		}
+	}()
+
+	return subscription.events
+}
+
+func (q *taskQueue) Stats() QueueStats {
+	select {
+	case q.requestChannel <- queueGetStatsRequest{}:
+	case <-q.done:
+		// receiver goroutine is gone, it's safe to read the tasks directly
+		return q.tasks.stats()
+	}
+
+	response := <-q.responseChannel
+
+	var result QueueStats
+
+	switch castedResponse := response.(type) {
+	case queueGetStatsResponse:
+		result = castedResponse.stats
+	default:
+		q.logger.Error("failed to fetch queue stats, incorrect type")
+	}
+
+	return result
+}
+
+func (q *taskQueue) Shutdown(ctx context.Context) error {
+	select {
+	case q.requestChannel <- queueCloseRequest{drain: q.drainOnShutdown}:
+	case <-q.done:
+		return nil
+	}
+
+	response := <-q.responseChannel
+
+	var drained <-chan struct{}
+
+	switch castedResponse := response.(type) {
+	case queueCloseResponse:
+		drained = castedResponse.drained
+	default:
+		return fmt.Errorf("failed to shut down queue, incorrect type")
+	}
+
+	select {
+	case <-drained:
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	q.terminate(false)
+	return nil
+}
+
+func (q *taskQueue) Stop() {
+	q.terminate(true)
+}
+
+// Makes the receiver goroutine exit and waits for it. With `abort` all remaining tasks are cancelled first.
+func (q *taskQueue) terminate(abort bool) {
+	select {
+	case q.requestChannel <- queueTerminateRequest{abort: abort}:
+		<-q.responseChannel
+	case <-q.done:
+		return
+	}
+
+	<-q.done
+}
+
 func (q *taskQueue) RunQueue() {
+	// closing `done` has to happen last, once nothing is going to touch the tasks anymore
+	defer close(q.done)
 	defer (func() {
 		if panic := recover(); panic != nil {
-			fmt.Println(fmt.Errorf("queue receiver goroutine panicked: %v \n\n %v", panic, string(debug.Stack())))
+			q.logger.Error("queue receiver goroutine panicked", logging.Any("panic", panic),
+				logging.Any("stack", string(debug.Stack())))
 		}
 	})()
 
-	for {
-		fmt.Println(fmt.Errorf("queue iteration, current length is: %v awaiting requests", len(q.tasks)))
-		if len(q.tasks) > 0 {
-			// if there are elements enqueued we await both incoming and outgoing messages
+	for !q.terminated {
+		q.logger.Debug("queue iteration, awaiting requests", logging.Any("length", q.tasks.length()))
+
+		// if there are scheduled tasks, we need to wake up once the first of them is due
+		// (receiving from nil channel blocks forever, so without scheduled tasks it never fires)
+		var dueChannel <-chan time.Time
+		var dueTimer *time.Timer
+		if dueAt, scheduled := q.tasks.nextDue(); scheduled {
+			dueTimer = time.NewTimer(time.Until(dueAt))
+			dueChannel = dueTimer.C
+		}
+
+		if q.tasks.length() > 0 || q.tasks.closed {
+			// if there are elements enqueued (or the queue is closed and waiting executors should be told so)
+			// we await both incoming and outgoing messages
 			select {
 			case request := <-q.executorRequestChannel:
 				// if there is active listener on outgoing channel, pop top of the queue
@@ -129,11 +470,21 @@ func (q *taskQueue) RunQueue() {
 			case request := <-q.requestChannel:
 				// accept incoming requests and process them
 				q.processRequest(request)
+			case <-dueChannel:
+				// scheduled task is due, next iteration is going to queue it
 			}
 		} else {
 			// if the queue is empty we await incoming messages
-			request := <-q.requestChannel
-			q.processRequest(request)
+			select {
+			case request := <-q.requestChannel:
+				q.processRequest(request)
+			case <-dueChannel:
+				// scheduled task is due, next iteration is going to queue it
+			}
+		}
+
+		if dueTimer != nil {
+			dueTimer.Stop()
 		}
 	}
 }
@@ -142,88 +493,169 @@ func (q *taskQueue) RunQueue() {
 //	pulling requests from it and handling them correctly. Goroutine is handling three
 //	types of requests: `enqueue`, `stop` (aka cancel), `containsTaskWithPriority`
 func (q *taskQueue) processExecutorRequest(request queueRequest) {
-	fmt.Println(fmt.Sprintf("queue received executor request: %v", request))
+	q.logger.Debug("queue received executor request", logging.Any("request", request))
 
 	switch req := request.(type) {
 	case queueGetTaskRequest:
-		q.processQueueGetTaskRequest()
+		q.processQueueGetTaskRequest(req)
 	default:
 		// we need to handle default not to be blocked
-		fmt.Println(fmt.Errorf("queue received invalid/unknown executor request type: %v discarded", req))
+		q.logger.Error("queue received invalid/unknown executor request type, discarded", logging.Any("request", req))
 	}
 }
 
 func (q *taskQueue) processRequest(request queueRequest) {
-	fmt.Println(fmt.Sprintf("queue received request: %v", request))
+	q.logger.Debug("queue received request", logging.Any("request", request))
 
 	switch req := request.(type) {
+	case queueTryGetTaskRequest:
+		q.processQueueTryGetTaskRequest()
 	case queueGetTaskByIdRequest:
 		q.processQueueGetTaskByIdRequest(req)
 	case queueGetListOfTasksRequest:
 		q.processQueueGetListOfTasksRequest(req)
 	case queueEnqueueTaskRequest:
 		q.processQueueEnqueueTaskRequest(req)
+	case queueCompleteTaskRequest:
+		q.processQueueCompleteTaskRequest(req)
+	case queueCancelTaskRequest:
+		q.processQueueCancelTaskRequest(req)
+	case queueGetDeadLettersRequest:
+		q.processQueueGetDeadLettersRequest(req)
+	case queueRequeueTaskRequest:
+		q.processQueueRequeueTaskRequest(req)
+	case queueSubscribeRequest:
+		q.processQueueSubscribeRequest(req)
+	case queueUnsubscribeRequest:
+		q.processQueueUnsubscribeRequest(req)
+	case queueGetStatsRequest:
+		q.processQueueGetStatsRequest(req)
+	case queueCloseRequest:
+		q.processQueueCloseRequest(req)
+	case queueTerminateRequest:
+		q.processQueueTerminateRequest(req)
 	default:
 		// we need to handle default not to be blocked
-		fmt.Println(fmt.Errorf("queue received invalid/unknown request type: %v discarded", req))
+		q.logger.Error("queue received invalid/unknown request type, discarded", logging.Any("request", req))
 	}
 }
 
-func (q *taskQueue) processQueueGetTaskRequest() {
-	fmt.Println(fmt.Sprintf("queue called pop"))
-	if len(q.tasks) == 0 {
-		fmt.Println(fmt.Errorf("queue called pop with empty queue"))
+func (q *taskQueue) processQueueGetTaskRequest(request queueGetTaskRequest) {
+	q.logger.Debug("queue called blocking pop")
+	if q.tasks.length() == 0 {
+		// request is only accepted from empty queue once it's closed
+		q.executorResponseChannel <- queueGetTaskResponse{err: ErrQueueClosed}
 		return
 	}
 
-	firstTask := q.tasks[0]
-	q.tasks = q.tasks[1:]
+	q.executorResponseChannel <- queueGetTaskResponse{task: q.tasks.pop(request.workerId)}
+}
+
+func (q *taskQueue) processQueueTryGetTaskRequest() {
+	q.logger.Debug("queue called pop")
+	if q.tasks.length() == 0 {
+		q.logger.Debug("queue called pop with empty queue")
+	}
 
-	q.executorResponseChannel <- queueGetTaskResponse{firstTask}
+	// nil if there is nothing to pop
+	q.responseChannel <- queueGetTaskResponse{task: q.tasks.pop(0)}
 }
 
 func (q *taskQueue) processQueueEnqueueTaskRequest(request queueEnqueueTaskRequest) {
-	fmt.Println(fmt.Sprintf("queue called enqueue"))
+	q.logger.Debug("queue called enqueue")
 
-	generatedTaskId, err := uuid.NewRandom()
-	if err != nil {
-		fmt.Println(fmt.Errorf("failed to generate uuid for operation: %w", err))
+	if spaceFreed, full := q.tasks.waitForSpace(request.task); full {
+		// caller waits for the space and tries again, the receiver goroutine keeps serving others meanwhile
+		q.responseChannel <- queueEnqueueTaskResponse{spaceFreed: spaceFreed}
+		return
 	}
 
-	taskIdString := generatedTaskId.String()
-	copiedTask := request.task
-	copiedTask.Id = taskIdString
+	handle, err := q.tasks.enqueue(request.task, request.dueAt)
+	q.responseChannel <- queueEnqueueTaskResponse{handle: handle, err: err}
+}
+
+func (q *taskQueue) processQueueCompleteTaskRequest(request queueCompleteTaskRequest) {
+	q.logger.Debug("queue called complete")
 
-	q.tasks = append(q.tasks, copiedTask)
-	q.responseChannel <- queueEnqueueTaskResponse{taskId: taskIdString}
+	q.tasks.complete(request.taskId, request.err)
+	q.responseChannel <- queueCompleteTaskResponse{}
 }
 
-func (q *taskQueue) processQueueGetListOfTasksRequest(req queueGetListOfTasksRequest) {
-	fmt.Println(fmt.Sprintf("queue called get list of tasks"))
+func (q *taskQueue) processQueueCancelTaskRequest(request queueCancelTaskRequest) {
+	q.logger.Debug("queue called cancel")
+
+	cancelled := q.tasks.cancelTask(request.taskId)
+	q.responseChannel <- queueCancelTaskResponse{cancelled: cancelled}
+}
+
+func (q *taskQueue) processQueueGetDeadLettersRequest(req queueGetDeadLettersRequest) {
+	q.logger.Debug("queue called get dead letters")
+
+	q.responseChannel <- queueGetListOfTasksResponse{tasks: q.tasks.listDeadLetters()}
+}
+
+func (q *taskQueue) processQueueRequeueTaskRequest(request queueRequeueTaskRequest) {
+	q.logger.Debug("queue called requeue")
+
+	err := q.tasks.requeue(request.taskId)
+	q.responseChannel <- queueRequeueTaskResponse{err: err}
+}
+
+func (q *taskQueue) processQueueSubscribeRequest(req queueSubscribeRequest) {
+	q.logger.Debug("queue called subscribe")
+
+	q.responseChannel <- queueSubscribeResponse{subscription: q.tasks.subscribe()}
+}
+
+func (q *taskQueue) processQueueUnsubscribeRequest(req queueUnsubscribeRequest) {
+	q.logger.Debug("queue called unsubscribe")
+
+	q.tasks.unsubscribe(req.subscription)
+	q.responseChannel <- queueUnsubscribeResponse{}
+}
+
+func (q *taskQueue) processQueueGetStatsRequest(req queueGetStatsRequest) {
+	q.logger.Debug("queue called get stats")
+
+	q.responseChannel <- queueGetStatsResponse{stats: q.tasks.stats()}
+}
+
+func (q *taskQueue) processQueueCloseRequest(request queueCloseRequest) {
+	q.logger.Debug("queue called close", logging.Any("drain", request.drain))
+
+	drained := q.tasks.close(request.drain)
+	q.responseChannel <- queueCloseResponse{drained: drained}
+}
+
+func (q *taskQueue) processQueueTerminateRequest(request queueTerminateRequest) {
+	q.logger.Debug("queue called terminate", logging.Any("abort", request.abort))
 
-	tasksCopy := make([]TaskInfo, 0)
-	for _, task := range q.tasks {
-		tasksCopy = append(tasksCopy, TaskInfo{
-			Id: task.Id,
-		})
+	if request.abort {
+		q.tasks.abort()
+	} else {
+		q.tasks.close(q.drainOnShutdown)
 	}
 
-	q.responseChannel <- queueGetListOfTasksResponse{tasks: tasksCopy}
+	// subscribers can't unsubscribe once the receiver goroutine is gone
+	q.tasks.stopped()
+
+	// receiver loop finishes after this iteration
+	q.terminated = true
+	q.responseChannel <- queueTerminateResponse{}
 }
 
-func (q *taskQueue) processQueueGetTaskByIdRequest(req queueGetTaskByIdRequest) {
-	fmt.Println(fmt.Sprintf("queue called get task by id"))
+func (q *taskQueue) processQueueGetListOfTasksRequest(req queueGetListOfTasksRequest) {
+	q.logger.Debug("queue called get list of tasks")
 
-	var returnedTask *TaskInfo = nil
-	for _, taskInQueue := range q.tasks {
-		if taskInQueue.Id == req.taskId {
-			returnedTask = &TaskInfo{
-				Id: taskInQueue.Id,
-			}
+	q.responseChannel <- queueGetListOfTasksResponse{tasks: q.tasks.list()}
+}
 
-			fmt.Println(fmt.Sprintf("get task by id - found"))
-			break
-		}
+func (q *taskQueue) processQueueGetTaskByIdRequest(req queueGetTaskByIdRequest) {
+	q.logger.Debug("queue called get task by id")
+
+	returnedTask := q.tasks.get(req.taskId)
+	if returnedTask != nil {
+		q.logger.Debug("get task by id - found", logging.TaskId(req.taskId))
 	}
 
 	q.responseChannel <- queueGetTaskByIdResponse{task: returnedTask}
@@ -232,15 +664,47 @@ func (q *taskQueue) processQueueGetTaskByIdRequest(req queueGetTaskByIdRequest)
 // Queue Requests
 // queueRequest is an internal interface (used only within this class)
 type queueRequest interface{}
-type queueGetTaskRequest struct{}
-type queueEnqueueTaskRequest struct{ task Task }
+type queueGetTaskRequest struct{ workerId int }
+type queueTryGetTaskRequest struct{}
+type queueEnqueueTaskRequest struct {
+	task  Task
+	dueAt time.Time
+}
+type queueCompleteTaskRequest struct {
+	taskId string
+	err    error
+}
+type queueCancelTaskRequest struct{ taskId string }
+type queueCloseRequest struct{ drain bool }
+type queueTerminateRequest struct{ abort bool }
 type queueGetListOfTasksRequest struct{}
 type queueGetTaskByIdRequest struct{ taskId string }
+type queueGetDeadLettersRequest struct{}
+type queueRequeueTaskRequest struct{ taskId string }
+type queueSubscribeRequest struct{}
+type queueUnsubscribeRequest struct{ subscription *taskSubscription }
+type queueGetStatsRequest struct{}
 
 // Queue Requests
 // queueRequest is an internal interface (used only within this class)
 type queueResponse interface{}
-type queueGetTaskResponse struct{ task Task }
-type queueEnqueueTaskResponse struct{ taskId string }
+type queueGetTaskResponse struct {
+	task *Task
+	err  error
+}
+type queueEnqueueTaskResponse struct {
+	handle *TaskHandle
+	err    error
+	// Set instead of the handle when the push has to wait for space, see `taskLedger.waitForSpace`
+	spaceFreed <-chan struct{}
+}
+type queueCompleteTaskResponse struct{}
+type queueCancelTaskResponse struct{ cancelled bool }
+type queueCloseResponse struct{ drained <-chan struct{} }
+type queueTerminateResponse struct{}
 type queueGetListOfTasksResponse struct{ tasks []TaskInfo }
 type queueGetTaskByIdResponse struct{ task *TaskInfo }
+type queueRequeueTaskResponse struct{ err error }
+type queueSubscribeResponse struct{ subscription *taskSubscription }
+type queueUnsubscribeResponse struct{}
+type queueGetStatsResponse struct{ stats QueueStats }
@@ -0,0 +1,128 @@
+package executor
+
+import (
+	"errors"
+	"gotest.tools/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Executable panicking given number of times before it finally succeeds
+type panickingExecutable struct {
+	panics int
+	calls  int
+}
+
+func (e *panickingExecutable) Execute() error {
+	e.calls++
+	if e.calls <= e.panics {
+		panic("something went terribly wrong")
+	}
+	return nil
+}
+
+func TestPanickingTaskDoesNotStopExecutor(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor()
+			defer executor.Stop()
+
+			panickingId := mustPush(t, queue, Task{
+				TaskExecutable: &panickingExecutable{panics: 1},
+				// panicking task is never retried
+				Retry: &RetryPolicy{MaxAttempts: 3},
+			})
+			nextId := mustPush(t, queue, Task{TaskExecutable: &flakyExecutable{}})
+
+			next := waitForFinishedTask(queue, nextId, 5*time.Second)
+			assert.Equal(t, TaskSucceeded, next.State)
+
+			panicked := queue.Get(panickingId)
+			assert.Equal(t, TaskFailed, panicked.State)
+			assert.Equal(t, 1, panicked.Attempts)
+			assert.Check(t, panicked.DeadLettered)
+			assert.Check(t, strings.Contains(panicked.Stack, "panickingExecutable"))
+
+			var panicErr *PanicError
+			assert.Check(t, errors.As(panicked.Error, &panicErr))
+			assert.Equal(t, "something went terribly wrong", panicErr.Value)
+		})
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor()
+			defer executor.Stop()
+
+			failingId := mustPush(t, queue, Task{
+				TaskExecutable: &flakyExecutable{failures: 5},
+				Retry:          &RetryPolicy{MaxAttempts: 2, InitialBackoff: 10 * time.Millisecond},
+			})
+			panickingId := mustPush(t, queue, Task{TaskExecutable: &panickingExecutable{panics: 1}})
+			succeedingId := mustPush(t, queue, Task{TaskExecutable: &flakyExecutable{}})
+
+			waitForFinishedTask(queue, failingId, 5*time.Second)
+			waitForFinishedTask(queue, panickingId, 5*time.Second)
+			waitForFinishedTask(queue, succeedingId, 5*time.Second)
+
+			deadLetters := queue.DeadLetters()
+			assert.Equal(t, 2, len(deadLetters))
+			ids := []string{deadLetters[0].Id, deadLetters[1].Id}
+			assert.Check(t, ids[0] == panickingId || ids[1] == panickingId)
+			assert.Check(t, ids[0] == failingId || ids[1] == failingId)
+		})
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor()
+			defer executor.Stop()
+
+			executable := &panickingExecutable{panics: 1}
+			id := mustPush(t, queue, Task{TaskExecutable: executable})
+			waitForFinishedTask(queue, id, 5*time.Second)
+
+			assert.Equal(t, nil, queue.Requeue(id))
+			assert.Equal(t, 0, len(queue.DeadLetters()))
+
+			info := waitForFinishedTask(queue, id, 5*time.Second)
+			assert.Equal(t, TaskSucceeded, info.State)
+			assert.Equal(t, 1, info.Attempts)
+			assert.Equal(t, 2, executable.calls)
+			assert.Check(t, !info.DeadLettered)
+
+			// task is not dead-lettered anymore
+			assert.Equal(t, ErrNotDeadLettered, queue.Requeue(id))
+			assert.Equal(t, ErrNotDeadLettered, queue.Requeue("unknown"))
+		})
+	}
+}
+
+func TestDeadLetterRetention(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue(WithDeadLetterRetention(2))
+			defer queue.Stop()
+
+			ids := make([]string, 0)
+			for i := 0; i < 3; i++ {
+				id := mustPush(t, queue, Task{TaskExecutable: &flakyExecutable{failures: 1}})
+				queue.Pop()
+				queue.Complete(id, errFlaky)
+				ids = append(ids, id)
+			}
+
+			deadLetters := queue.DeadLetters()
+			assert.Equal(t, 2, len(deadLetters))
+			assert.Equal(t, ids[1], deadLetters[0].Id)
+			assert.Equal(t, ids[2], deadLetters[1].Id)
+			// the oldest one is forgotten
+			assert.Check(t, queue.Get(ids[0]) == nil)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Returned by `Push` once the queue has been shut down or stopped
+var ErrQueueClosed = errors.New("task queue is closed")
+
+// Returned by `Push` when the queue is at its capacity and overflow policy rejects new tasks, see `WithCapacity`
+var ErrQueueFull = errors.New("task queue is full")
+
+// Error of a task which was dropped from a full queue, see `OverflowDropOldest` and `OverflowDropNewest`
+var ErrTaskDropped = errors.New("task was dropped from a full queue")
+
+// Returned by `PopContext` of queues whose tasks are popped by their own executors, e.g. the keyed one
+var ErrPopNotSupported = errors.New("tasks of this queue can't be popped directly")
+
+// Returned by `Requeue` when the task is not in the dead letter queue
+var ErrNotDeadLettered = errors.New("task is not in the dead letter queue")
+
+// Returned by `Push` when the task depends on a task the queue doesn't know (anymore)
+var ErrUnknownDependency = errors.New("task depends on unknown task")
+
+// Returned by `SubmitGraph` when the tasks depend on each other in a cycle
+var ErrDependencyCycle = errors.New("task dependencies form a cycle")
+
+// Error of a task which ran longer than its timeout, see `Task.Timeout`
+var ErrTaskTimedOut = errors.New("task timed out")
+
+// DependencyError is the error of a task which didn't run because its dependency didn't succeed
+type DependencyError struct {
+	Id    string
+	State TaskState
+}
+
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("dependency %v has not succeeded, it is %v", e.Id, e.State)
+}
+
+// PanicError is the error of a task whose executable panicked
+type PanicError struct {
+	Value interface{}
+	Stack string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v", e.Value)
+}
@@ -0,0 +1,209 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Returned when an executable (or its name) hasn't been registered
+var ErrUnknownExecutable = errors.New("executable type is not registered")
+
+// TaskSpec describes a task in a form which can be stored or sent over the wire, e.g.
+//
+//	{"type": "count", "params": {"countLimit": 3, "countPeriod": "1s"}}
+//
+// Params are JSON of the executable registered as `type`, missing params keep the values set by its factory.
+type TaskSpec struct {
+	Type     string          `json:"type"`
+	Params   json.RawMessage `json:"params,omitempty"`
+	Priority int             `json:"priority,omitempty"`
+	Retry    *RetryPolicy    `json:"retry,omitempty"`
+
+	PartitionKey   string `json:"partitionKey,omitempty"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+
+	DependsOn           []string                `json:"dependsOn,omitempty"`
+	OnDependencyFailure DependencyFailurePolicy `json:"onDependencyFailure,omitempty"`
+
+	// Duration string like "30s", as are the backoffs of `RetryPolicy`
+	Timeout Duration `json:"timeout,omitempty"`
+}
+
+// Duration is `time.Duration` serialized to JSON as a duration string like "1m30s". Plain numbers are read
+// as nanoseconds, which is how durations were serialized before.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		var nanoseconds int64
+		if json.Unmarshal(data, &nanoseconds) != nil {
+			return fmt.Errorf("invalid duration %s, expected a string like \"500ms\"", data)
+		}
+		*d = Duration(nanoseconds)
+		return nil
+	}
+
+	duration, err := time.ParseDuration(text)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// ExecutableRegistry knows executable types by name, so tasks can be stored (e.g. by the persistent queue)
+// and rebuilt later. Executable is stored as its name plus JSON of the executable itself, so only its
+// exported fields survive. Executables which need custom (de)serialization implement `json.Marshaler`
+// and `json.Unmarshaler`.
+type ExecutableRegistry struct {
+	lock      sync.RWMutex
+	factories map[string]func() Executable
+	names     map[reflect.Type]string
+}
+
+func NewExecutableRegistry() *ExecutableRegistry {
+	return &ExecutableRegistry{
+		factories: make(map[string]func() Executable),
+		names:     make(map[reflect.Type]string),
+	}
+}
+
+// NewDefaultExecutableRegistry creates registry which knows the sample executables of this package:
+// `count` (`ExecutableCounterWithSleep`), `child` (`ExecutableAnnoyingKid`) and `quickie` (`ExecutableQuickie`).
+// More types can be registered on top of them.
+func NewDefaultExecutableRegistry() *ExecutableRegistry {
+	registry := NewExecutableRegistry()
+
+	builtins := map[string]func() Executable{
+		"count":   func() Executable { return NewExecutableCounterWithSleep(10, 500*time.Millisecond).TaskExecutable },
+		"child":   func() Executable { return NewExecutableAnnoyingKid().TaskExecutable },
+		"quickie": func() Executable { return NewExecutableQuickie().TaskExecutable },
+	}
+	for name, factory := range builtins {
+		if err := registry.Register(name, factory); err != nil {
+			// names and types are distinct, this can't happen
+			panic(err)
+		}
+	}
+
+	return registry
+}
+
+// Register adds executable type under the name. Factory returns new executable with default values (usually
+// a pointer to a struct), which the params are unmarshalled into.
+func (r *ExecutableRegistry) Register(name string, factory func() Executable) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, found := r.factories[name]; found {
+		return fmt.Errorf("executable %q is already registered", name)
+	}
+
+	executableType := reflect.TypeOf(factory())
+	if registeredName, found := r.names[executableType]; found {
+		return fmt.Errorf("executable type %v is already registered as %q", executableType, registeredName)
+	}
+
+	r.factories[name] = factory
+	r.names[executableType] = name
+	return nil
+}
+
+// Encode returns name of the executable's type and the executable serialized to JSON
+func (r *ExecutableRegistry) Encode(executable Executable) (string, json.RawMessage, error) {
+	r.lock.RLock()
+	name, found := r.names[reflect.TypeOf(executable)]
+	r.lock.RUnlock()
+
+	if !found {
+		return "", nil, fmt.Errorf("%w: %T", ErrUnknownExecutable, executable)
+	}
+
+	payload, err := json.Marshal(executable)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to serialize executable %q: %w", name, err)
+	}
+
+	return name, payload, nil
+}
+
+// Decode rebuilds executable from its name and JSON created by `Encode`
+func (r *ExecutableRegistry) Decode(name string, payload json.RawMessage) (Executable, error) {
+	r.lock.RLock()
+	factory, found := r.factories[name]
+	r.lock.RUnlock()
+
+	if !found {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownExecutable, name)
+	}
+
+	executable := factory()
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, executable); err != nil {
+			return nil, fmt.Errorf("failed to deserialize executable %q: %w", name, err)
+		}
+	}
+
+	return executable, nil
+}
+
+// NewTask builds task described by the spec
+func (r *ExecutableRegistry) NewTask(spec TaskSpec) (Task, error) {
+	executable, err := r.Decode(spec.Type, spec.Params)
+	if err != nil {
+		return Task{}, err
+	}
+
+	return Task{
+		TaskExecutable:      executable,
+		Priority:            spec.Priority,
+		Retry:               spec.Retry,
+		PartitionKey:        spec.PartitionKey,
+		IdempotencyKey:      spec.IdempotencyKey,
+		DependsOn:           spec.DependsOn,
+		OnDependencyFailure: spec.OnDependencyFailure,
+		Timeout:             time.Duration(spec.Timeout),
+	}, nil
+}
+
+// ParseTask builds task from JSON of `TaskSpec`
+func (r *ExecutableRegistry) ParseTask(data []byte) (Task, error) {
+	spec := TaskSpec{}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return Task{}, fmt.Errorf("invalid task spec: %w", err)
+	}
+	if spec.Type == "" {
+		return Task{}, fmt.Errorf("invalid task spec: missing type")
+	}
+
+	return r.NewTask(spec)
+}
+
+// Spec describes the task, so that `NewTask` can build it again
+func (r *ExecutableRegistry) Spec(task Task) (TaskSpec, error) {
+	name, params, err := r.Encode(task.TaskExecutable)
+	if err != nil {
+		return TaskSpec{}, err
+	}
+
+	return TaskSpec{
+		Type:                name,
+		Params:              params,
+		Priority:            task.Priority,
+		Retry:               task.Retry,
+		PartitionKey:        task.PartitionKey,
+		IdempotencyKey:      task.IdempotencyKey,
+		DependsOn:           task.DependsOn,
+		OnDependencyFailure: task.OnDependencyFailure,
+		Timeout:             Duration(task.Timeout),
+	}, nil
+}
@@ -0,0 +1,174 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"gotest.tools/assert"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTask(t *testing.T) {
+	tests := []struct {
+		name               string
+		spec               string
+		expectedExecutable Executable
+		expectedPriority   int
+		expectedErr        string
+	}{
+		{
+			name:               "counter with params",
+			spec:               `{"type":"count","params":{"countLimit":3,"countPeriod":"1s"},"priority":2}`,
+			expectedExecutable: &ExecutableCounterWithSleep{CountLimit: 3, CountPeriod: time.Second},
+			expectedPriority:   2,
+		},
+		{
+			name:               "counter keeps default params",
+			spec:               `{"type":"count","params":{"countLimit":3}}`,
+			expectedExecutable: &ExecutableCounterWithSleep{CountLimit: 3, CountPeriod: 500 * time.Millisecond},
+		},
+		{
+			name:               "kid with sentences",
+			spec:               `{"type":"child","params":{"randomSentences":["Why?"]}}`,
+			expectedExecutable: &ExecutableAnnoyingKid{RandomSentences: []string{"Why?"}},
+		},
+		{
+			name:               "quickie without params",
+			spec:               `{"type":"quickie"}`,
+			expectedExecutable: &ExecutableQuickie{},
+		},
+		{
+			name:        "unknown type",
+			spec:        `{"type":"nap"}`,
+			expectedErr: "executable type is not registered",
+		},
+		{
+			name:        "missing type",
+			spec:        `{"params":{}}`,
+			expectedErr: "missing type",
+		},
+		{
+			name:        "invalid params",
+			spec:        `{"type":"count","params":{"countPeriod":"forever"}}`,
+			expectedErr: "invalid countPeriod",
+		},
+	}
+
+	registry := NewDefaultExecutableRegistry()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			task, err := registry.ParseTask([]byte(test.spec))
+			if test.expectedErr != "" {
+				assert.ErrorContains(t, err, test.expectedErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			// go-cmp can't compare unexported fields of the embedded task
+			assert.Check(t, reflect.DeepEqual(test.expectedExecutable, task.TaskExecutable))
+			assert.Equal(t, test.expectedPriority, task.Priority)
+		})
+	}
+}
+
+func TestTaskSpecRoundTrip(t *testing.T) {
+	registry := NewDefaultExecutableRegistry()
+	tasks := []Task{
+		NewExecutableCounterWithSleep(7, 250*time.Millisecond),
+		NewExecutableAnnoyingKid(),
+		NewExecutableQuickie(),
+	}
+	tasks[0].Priority = 5
+	tasks[1].Retry = &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second, Jitter: 0.2}
+	tasks[2].PartitionKey = "volume-1"
+	tasks[2].Timeout = 3 * time.Second
+
+	for _, task := range tasks {
+		spec, err := registry.Spec(task)
+		assert.NilError(t, err)
+
+		rebuilt, err := registry.NewTask(spec)
+		assert.NilError(t, err)
+		assert.Check(t, reflect.DeepEqual(task.TaskExecutable, rebuilt.TaskExecutable))
+		assert.Equal(t, task.Priority, rebuilt.Priority)
+		assert.DeepEqual(t, task.Retry, rebuilt.Retry)
+		assert.Equal(t, task.PartitionKey, rebuilt.PartitionKey)
+		assert.Equal(t, task.Timeout, rebuilt.Timeout)
+	}
+}
+
+func TestTaskSpecDurationsJSON(t *testing.T) {
+	tests := []struct {
+		name            string
+		spec            string
+		expectedTimeout time.Duration
+		expectedRetry   *RetryPolicy
+		expectedErr     string
+	}{
+		{
+			name:            "duration strings",
+			spec:            `{"type":"quickie","timeout":"1.5s","retry":{"MaxAttempts":3,"InitialBackoff":"500ms","MaxBackoff":"1m"}}`,
+			expectedTimeout: 1500 * time.Millisecond,
+			expectedRetry:   &RetryPolicy{MaxAttempts: 3, InitialBackoff: 500 * time.Millisecond, MaxBackoff: time.Minute},
+		},
+		{
+			name:            "nanoseconds written before",
+			spec:            `{"type":"quickie","timeout":1500000000,"retry":{"MaxAttempts":3,"InitialBackoff":500000000}}`,
+			expectedTimeout: 1500 * time.Millisecond,
+			expectedRetry:   &RetryPolicy{MaxAttempts: 3, InitialBackoff: 500 * time.Millisecond},
+		},
+		{
+			name:        "invalid timeout",
+			spec:        `{"type":"quickie","timeout":"forever"}`,
+			expectedErr: "invalid duration",
+		},
+		{
+			name:        "invalid backoff",
+			spec:        `{"type":"quickie","retry":{"InitialBackoff":true}}`,
+			expectedErr: "invalid duration",
+		},
+	}
+
+	registry := NewDefaultExecutableRegistry()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			task, err := registry.ParseTask([]byte(test.spec))
+			if test.expectedErr != "" {
+				assert.ErrorContains(t, err, test.expectedErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, test.expectedTimeout, task.Timeout)
+			assert.DeepEqual(t, test.expectedRetry, task.Retry)
+
+			spec, err := registry.Spec(task)
+			assert.NilError(t, err)
+			data, err := json.Marshal(spec)
+			assert.NilError(t, err)
+			assert.Check(t, strings.Contains(string(data), `"timeout":"1.5s"`), "spec: %s", data)
+			assert.Check(t, strings.Contains(string(data), `"InitialBackoff":"500ms"`), "spec: %s", data)
+
+			rebuilt, err := registry.ParseTask(data)
+			assert.NilError(t, err)
+			assert.Equal(t, task.Timeout, rebuilt.Timeout)
+			assert.DeepEqual(t, task.Retry, rebuilt.Retry)
+		})
+	}
+}
+
+func TestRegisterExecutable(t *testing.T) {
+	registry := NewDefaultExecutableRegistry()
+
+	assert.NilError(t, registry.Register("stored", func() Executable { return &storedExecutable{} }))
+	assert.ErrorContains(t, registry.Register("stored", func() Executable { return &flakyExecutable{} }), "already registered")
+	assert.ErrorContains(t, registry.Register("another", func() Executable { return &storedExecutable{} }), "already registered")
+
+	task, err := registry.ParseTask([]byte(`{"type":"stored","params":{"Name":"user type"}}`))
+	assert.NilError(t, err)
+	assert.Equal(t, "user type", task.TaskExecutable.(*storedExecutable).Name)
+
+	_, err = registry.Spec(Task{TaskExecutable: &flakyExecutable{}})
+	assert.Check(t, errors.Is(err, ErrUnknownExecutable))
+}
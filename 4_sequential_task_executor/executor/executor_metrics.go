@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"AwesomePresentation/metrics"
+	"errors"
+	"time"
+)
+
+// Outcomes of task executions, values of the `outcome` label of `executor_task_executions_total`
+const (
+	outcomeSucceeded = "succeeded"
+	outcomeFailed    = "failed"
+	outcomePanicked  = "panicked"
+	outcomeTimedOut  = "timed_out"
+)
+
+// Registers gauges of the queue's length and counters of its rejected and dropped tasks. Queues sharing
+// the registry (e.g. lanes of a keyed executor) are summed up. Returned function unregisters them, queues
+// call it once they stop - the registry doesn't keep stopped queues, nor counts them anymore.
+func registerQueueMetrics(registry *metrics.Registry, queue TaskQueue) func() {
+	unregisters := []func(){
+		registry.NewGaugeFunc("executor_queue_waiting_tasks",
+			"Tasks waiting to be executed, including scheduled and blocked ones.",
+			func() float64 { return float64(queue.Stats().Waiting) }),
+		registry.NewGaugeFunc("executor_queue_running_tasks",
+			"Tasks being executed.",
+			func() float64 { return float64(queue.Stats().Running) }),
+		registry.NewCounterFunc("executor_queue_rejected_total",
+			"Pushes rejected because the queue was full.",
+			func() float64 { return float64(queue.Stats().Rejected) }),
+		registry.NewCounterFunc("executor_queue_dropped_total",
+			"Queued tasks dropped to make space for new ones.",
+			func() float64 { return float64(queue.Stats().Dropped) }),
+	}
+
+	return func() {
+		for _, unregister := range unregisters {
+			unregister()
+		}
+	}
+}
+
+func newTaskWaitHistogram(registry *metrics.Registry) *metrics.Histogram {
+	return registry.NewHistogram("executor_task_wait_seconds",
+		"Time from when a task was due (pushed, scheduled or retried) until it started.", nil)
+}
+
+// executorMetrics are recorded by the workers of an executor
+type executorMetrics struct {
+	executions *metrics.Counter
+	duration   *metrics.Histogram
+}
+
+func newExecutorMetrics(registry *metrics.Registry) executorMetrics {
+	return executorMetrics{
+		executions: registry.NewCounter("executor_task_executions_total",
+			"Executions of tasks by their outcome: succeeded, failed, panicked or timed_out.", "outcome"),
+		duration: registry.NewHistogram("executor_task_execution_seconds",
+			"How long executions of tasks took.", nil, "outcome"),
+	}
+}
+
+// Records execution which started at the time and ended with the error
+func (m executorMetrics) executed(started time.Time, err error) {
+	outcome := outcomeSucceeded
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		outcome = outcomePanicked
+	} else if errors.Is(err, ErrTaskTimedOut) {
+		outcome = outcomeTimedOut
+	} else if err != nil {
+		outcome = outcomeFailed
+	}
+
+	m.executions.Inc(outcome)
+	m.duration.Observe(time.Since(started).Seconds(), outcome)
+}
@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"AwesomePresentation/metrics"
+	"bytes"
+	"context"
+	"gotest.tools/assert"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecutorMetrics(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			registry := metrics.NewRegistry()
+			queue, executor := constructor.newExecutor(WithMetrics(registry))
+
+			ids := []string{
+				mustPush(t, queue, NewExecutableQuickie()),
+				mustPush(t, queue, Task{TaskExecutable: &flakyExecutable{failures: 1}}),
+				mustPush(t, queue, Task{TaskExecutable: &panickingExecutable{panics: 1}}),
+			}
+			for _, id := range ids {
+				assert.Check(t, waitForFinishedTask(queue, id, 5*time.Second).State.IsFinished())
+			}
+
+			text := metricsText(t, registry)
+			for _, expected := range []string{
+				`executor_queue_waiting_tasks 0`,
+				`executor_queue_running_tasks 0`,
+			} {
+				assert.Check(t, strings.Contains(text, expected+"\n"), "missing %q in:\n%v", expected, text)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			assert.NilError(t, executor.Shutdown(ctx))
+
+			text = metricsText(t, registry)
+			for _, expected := range []string{
+				`executor_task_executions_total{outcome="succeeded"} 1`,
+				`executor_task_executions_total{outcome="failed"} 1`,
+				`executor_task_executions_total{outcome="panicked"} 1`,
+				`executor_task_execution_seconds_count{outcome="succeeded"} 1`,
+				`executor_task_wait_seconds_count 3`,
+			} {
+				assert.Check(t, strings.Contains(text, expected+"\n"), "missing %q in:\n%v", expected, text)
+			}
+			// stopped queue is unregistered
+			assert.Check(t, !strings.Contains(text, "executor_queue_waiting_tasks"), text)
+		})
+	}
+}
+
+func TestQueueMetricsAreSummed(t *testing.T) {
+	registry := metrics.NewRegistry()
+	for _, constructor := range queueConstructors {
+		queue := constructor.newQueue(WithMetrics(registry))
+		defer queue.Stop()
+
+		mustPush(t, queue, NewExecutableQuickie())
+		mustPush(t, queue, NewExecutableQuickie())
+		queue.Pop()
+	}
+
+	text := metricsText(t, registry)
+	running := len(queueConstructors)
+	assert.Check(t, strings.Contains(text, "executor_queue_running_tasks "+strconv.Itoa(running)+"\n"), text)
+	assert.Check(t, strings.Contains(text, "executor_queue_waiting_tasks "+strconv.Itoa(running)+"\n"), text)
+	assert.Check(t, strings.Contains(text, "executor_task_wait_seconds_count "+strconv.Itoa(running)+"\n"), text)
+}
+
+func TestStoppedQueueMetricsAreUnregistered(t *testing.T) {
+	registry := metrics.NewRegistry()
+	for _, constructor := range queueConstructors {
+		queue := constructor.newQueue(WithMetrics(registry))
+		mustPush(t, queue, NewExecutableQuickie())
+		assert.Check(t, strings.Contains(metricsText(t, registry), "executor_queue_waiting_tasks 1\n"))
+
+		queue.Stop()
+		assert.Check(t, !strings.Contains(metricsText(t, registry), "executor_queue_"), constructor.name)
+	}
+}
+
+func metricsText(t *testing.T, registry *metrics.Registry) string {
+	t.Helper()
+
+	output := &bytes.Buffer{}
+	assert.NilError(t, registry.WriteText(output))
+	return output.String()
+}
@@ -0,0 +1,154 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gotest.tools/assert"
+	"runtime"
+	"testing"
+	"time"
+)
+
+var executorConstructors = []struct {
+	name        string
+	newExecutor func(options ...Option) (TaskQueue, *Executor)
+}{
+	{name: "channel queue executor", newExecutor: NewChannelQueueExecutor},
+	{name: "locking queue executor", newExecutor: NewLockingQueueExecutor},
+}
+
+func TestExecutorShutdownDrainsQueue(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor()
+
+			ids := make([]string, 0)
+			for i := 0; i < 3; i++ {
+				ids = append(ids, mustPush(t, queue, NewExecutableCounterWithSleep(2, 10*time.Millisecond)))
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			assert.NilError(t, executor.Shutdown(ctx))
+
+			for _, id := range ids {
+				assert.Equal(t, TaskSucceeded, queue.Get(id).State)
+			}
+
+			_, err := queue.Push(NewExecutableQuickie())
+			assert.Check(t, errors.Is(err, ErrQueueClosed))
+		})
+	}
+}
+
+func TestExecutorShutdownWithoutDrain(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor(WithDrainOnShutdown(false))
+
+			runningId := mustPush(t, queue, NewExecutableCounterWithSleep(5, 20*time.Millisecond))
+			queuedId := mustPush(t, queue, NewExecutableQuickie())
+			waitForTaskState(t, queue, runningId, TaskRunning)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			assert.NilError(t, executor.Shutdown(ctx))
+
+			// running task was allowed to finish, the queued one was never started
+			assert.Equal(t, TaskSucceeded, queue.Get(runningId).State)
+			assert.Equal(t, TaskCancelled, queue.Get(queuedId).State)
+		})
+	}
+}
+
+func TestExecutorShutdownTimesOut(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor()
+			defer executor.Stop()
+
+			id := mustPush(t, queue, NewExecutableCounterWithSleep(1000, 10*time.Millisecond))
+			waitForTaskState(t, queue, id, TaskRunning)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			assert.Check(t, errors.Is(executor.Shutdown(ctx), context.DeadlineExceeded))
+
+			// shutdown has started anyway, so no new tasks are accepted
+			_, err := queue.Push(NewExecutableQuickie())
+			assert.Check(t, errors.Is(err, ErrQueueClosed))
+		})
+	}
+}
+
+func TestExecutorStop(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor()
+
+			runningId := mustPush(t, queue, NewExecutableCounterWithSleep(1000, 100*time.Millisecond))
+			queuedId := mustPush(t, queue, NewExecutableQuickie())
+			waitForTaskState(t, queue, runningId, TaskRunning)
+
+			executor.Stop()
+			// stopping twice is fine
+			executor.Stop()
+
+			assert.Equal(t, TaskCancelled, queue.Get(runningId).State)
+			assert.Equal(t, TaskCancelled, queue.Get(queuedId).State)
+
+			_, err := queue.Push(NewExecutableQuickie())
+			assert.Check(t, errors.Is(err, ErrQueueClosed))
+		})
+	}
+}
+
+func TestShutdownLeavesNoGoroutines(t *testing.T) {
+	goroutinesBefore := runtime.NumGoroutine()
+
+	for _, constructor := range executorConstructors {
+		for i := 0; i < 5; i++ {
+			queue, executor := constructor.newExecutor()
+			mustPush(t, queue, NewExecutableQuickie())
+			mustPush(t, queue, NewExecutableCounterWithSleep(1000, time.Millisecond))
+
+			if i%2 == 0 {
+				executor.Stop()
+			} else {
+				queue.Cancel(queue.List()[1].Id)
+				assert.NilError(t, executor.Shutdown(context.Background()))
+			}
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		queue := NewTaskQueue()
+		mustPush(t, queue, NewExecutableQuickie())
+		queue.Stop()
+	}
+
+	// goroutines need a moment to actually disappear after they've signalled they're done
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > goroutinesBefore && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	fmt.Println(fmt.Sprintf("goroutines before: %v, after: %v", goroutinesBefore, runtime.NumGoroutine()))
+	assert.Check(t, runtime.NumGoroutine() <= goroutinesBefore)
+}
+
+// Polls the queue until the task reaches expected state, fails the test after a while
+func waitForTaskState(t *testing.T, queue TaskQueue, id string, state TaskState) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if info := queue.Get(id); info != nil && info.State == state {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("task %v did not reach state %v", id, state)
+}
@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"fmt"
 	"gotest.tools/assert"
 	"sync"
@@ -11,19 +12,20 @@ import (
 func TestThreadSafetyExecutor(t *testing.T) {
 	tests := []struct {
 		name           string
-		testedFunction func(queue TaskQueue, collection *[]int, value int, wg *sync.WaitGroup)
+		testedFunction func(queue TaskQueue, collection *[]int, value int) *TaskHandle
 		iterations     int
 		processes      int
 		collection     []int
 	}{
 		{
 			name: "priority queue push is thread safe",
-			testedFunction: func(queue TaskQueue, collection *[]int, value int, wg *sync.WaitGroup) {
+			testedFunction: func(queue TaskQueue, collection *[]int, value int) *TaskHandle {
 				// create task
-				task := NewTestSliceCollectingExecutable(value, collection, wg)
+				task := NewTestSliceCollectingExecutable(value, collection)
 
 				// submit it
-				queue.Push(task)
+				handle, _ := queue.Submit(task)
+				return handle
 			},
 			iterations: 100,
 			processes:  3,
@@ -31,14 +33,15 @@ func TestThreadSafetyExecutor(t *testing.T) {
 		},
 		{
 			name: "priority queue push and list is thread safe",
-			testedFunction: func(queue TaskQueue, collection *[]int, value int, wg *sync.WaitGroup) {
+			testedFunction: func(queue TaskQueue, collection *[]int, value int) *TaskHandle {
 				// create task
-				task := NewTestSliceCollectingExecutable(value, collection, wg)
+				task := NewTestSliceCollectingExecutable(value, collection)
 
 				// submit it
-				queue.Push(task)
+				handle, _ := queue.Submit(task)
 
 				queue.List()
+				return handle
 			},
 			iterations: 100,
 			processes:  3,
@@ -46,17 +49,18 @@ func TestThreadSafetyExecutor(t *testing.T) {
 		},
 		{
 			name: "priority queue push, contains, get by id is thread safe",
-			testedFunction: func(queue TaskQueue, collection *[]int, value int, wg *sync.WaitGroup) {
-				task := NewTestSliceCollectingExecutable(value, collection, wg)
+			testedFunction: func(queue TaskQueue, collection *[]int, value int) *TaskHandle {
+				task := NewTestSliceCollectingExecutable(value, collection)
 
 				// ignore result
 				queue.List()
-				someId := queue.Push(task)
+				handle, _ := queue.Submit(task)
 
-				queue.Get(someId)
+				queue.Get(handle.Id())
 
 				// ignore result
 				queue.List()
+				return handle
 			},
 			iterations: 100,
 			processes:  3,
@@ -68,19 +72,25 @@ func TestThreadSafetyExecutor(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			fmt.Println(fmt.Sprintf("Start: %v", test.name))
 
-			queue, _ := NewChannelQueueExecutor()
-			//queue, _ := NewLockingQueueExecutor()
+			queue, executor := NewChannelQueueExecutor()
+			//queue, executor := NewLockingQueueExecutor()
+			defer executor.Stop()
 
 			setOfOperations := make([]ExecutableTestOperation, 0, test.iterations)
-			wg := sync.WaitGroup{}
-			wg.Add(test.iterations)
+			// we need to wait for each task to finish, this way we can be sure test can terminate once all executable tasks are done, not before
+			handles := make([]*TaskHandle, 0, test.iterations)
+			handlesLock := sync.Mutex{}
 
 			for i := 0; i < test.iterations; i++ {
 				copyOfFunction := test.testedFunction
 				copyOfIndex := i
 
 				operation := func() error {
-					copyOfFunction(queue, &test.collection, copyOfIndex, &wg)
+					handle := copyOfFunction(queue, &test.collection, copyOfIndex)
+
+					handlesLock.Lock()
+					handles = append(handles, handle)
+					handlesLock.Unlock()
 					return nil
 				}
 
@@ -90,8 +100,14 @@ func TestThreadSafetyExecutor(t *testing.T) {
 			fmt.Println(fmt.Sprintf("Testing number of operations: %v", len(setOfOperations)))
 			_ = ParallelOperationsExecutor(t, test.processes, setOfOperations)
 
-			result := WaitGroupWithTimeout(&wg, 5*time.Second)
-			assert.Check(t, result)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			assert.Equal(t, test.iterations, len(handles))
+			for _, handle := range handles {
+				assert.NilError(t, handle.Wait(ctx))
+			}
+			assert.Equal(t, test.iterations, len(test.collection))
 		})
 	}
 }
@@ -0,0 +1,123 @@
+package executor
+
+import (
+	"context"
+	"gotest.tools/assert"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Reports id of the worker running it
+type workerReportingExecutable struct {
+	workers chan int
+}
+
+func (e *workerReportingExecutable) ExecuteContext(ctx context.Context) error {
+	e.workers <- WorkerFromContext(ctx)
+	return nil
+}
+
+func (e *workerReportingExecutable) Execute() error {
+	return e.ExecuteContext(context.Background())
+}
+
+func TestExecutorWorkersRunTasksConcurrently(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor(WithWorkers(3))
+			defer executor.Stop()
+			assert.Equal(t, 3, executor.Workers())
+
+			release := make(chan struct{})
+			ids := make([]string, 0)
+			for i := 0; i < 3; i++ {
+				ids = append(ids, mustPush(t, queue, Task{TaskExecutable: &blockingExecutable{release: release}}))
+			}
+
+			// all of them are running at once, each on a different worker
+			workers := make([]int, 0)
+			for _, id := range ids {
+				waitForTaskState(t, queue, id, TaskRunning)
+				workers = append(workers, queue.Get(id).Worker)
+			}
+			sort.Ints(workers)
+			assert.DeepEqual(t, []int{1, 2, 3}, workers)
+
+			close(release)
+			for _, id := range ids {
+				info := waitForFinishedTask(queue, id, 5*time.Second)
+				assert.Equal(t, TaskSucceeded, info.State)
+				assert.Check(t, info.Worker > 0)
+			}
+		})
+	}
+}
+
+func TestExecutorResize(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor()
+			defer executor.Stop()
+			assert.Equal(t, 1, executor.Workers())
+
+			release := make(chan struct{})
+			firstId := mustPush(t, queue, Task{TaskExecutable: &blockingExecutable{release: release}})
+			secondId := mustPush(t, queue, Task{TaskExecutable: &blockingExecutable{release: release}})
+			waitForTaskState(t, queue, firstId, TaskRunning)
+
+			// the only worker is busy
+			time.Sleep(20 * time.Millisecond)
+			assert.Equal(t, TaskQueued, queue.Get(secondId).State)
+
+			executor.Resize(2)
+			assert.Equal(t, 2, executor.Workers())
+			waitForTaskState(t, queue, secondId, TaskRunning)
+			assert.Equal(t, 2, queue.Get(secondId).Worker)
+
+			// retired worker finishes its task, then the remaining one takes over
+			executor.Resize(0)
+			assert.Equal(t, 1, executor.Workers())
+			close(release)
+			assert.Equal(t, TaskSucceeded, waitForFinishedTask(queue, secondId, 5*time.Second).State)
+
+			reports := make(chan int, 3)
+			for i := 0; i < 3; i++ {
+				mustPush(t, queue, Task{TaskExecutable: &workerReportingExecutable{workers: reports}})
+			}
+			for i := 0; i < 3; i++ {
+				select {
+				case worker := <-reports:
+					assert.Equal(t, 1, worker)
+				case <-time.After(5 * time.Second):
+					t.Fatal("task was not executed")
+				}
+			}
+		})
+	}
+}
+
+func TestExecutorWithWorkersShutdown(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor(WithWorkers(4))
+
+			ids := make([]string, 0)
+			for i := 0; i < 10; i++ {
+				ids = append(ids, mustPush(t, queue, NewExecutableCounterWithSleep(2, 5*time.Millisecond)))
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			assert.NilError(t, executor.Shutdown(ctx))
+
+			for _, id := range ids {
+				assert.Equal(t, TaskSucceeded, queue.Get(id).State)
+			}
+
+			// nothing to resize anymore
+			executor.Resize(2)
+			assert.Equal(t, 0, executor.Workers())
+		})
+	}
+}
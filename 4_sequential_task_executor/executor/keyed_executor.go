@@ -0,0 +1,297 @@
+package executor
+
+import (
+	"AwesomePresentation/logging"
+	"context"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KeyedExecutor runs tasks with the same `PartitionKey` strictly one by one, in the order they were pushed,
+// while tasks with different keys run in parallel. Keys are hashed onto sequential lanes - every lane is
+// an ordinary queue with its own single worker executor, so unrelated keys sharing a lane wait for each other.
+// Tasks without a key are spread over the lanes (by their `IdempotencyKey`, if they have one), their order
+// is not guaranteed. Lanes only know their own tasks, so a task can only depend on tasks with the same key,
+// see `Task.DependsOn`.
+//
+// Two things break the order of a key, the lane doesn't hold back its other tasks for them:
+//   - Retry of a failed task is scheduled like any other retry (see `RetryPolicy`), tasks of the key pushed
+//     after it run while it waits for its backoff.
+//   - Execution abandoned by `TimeoutAbandon` keeps running while the lane's worker moves on, so it may
+//     overlap with the following tasks of the key. The default `TimeoutWait` keeps them apart, unless
+//     the executable is a plain `Executable`, see `TimeoutPolicy`.
+//
+// Tasks which must not be reordered should use `Task.DependsOn` instead of retries, or not use retries at all.
+type KeyedExecutor struct {
+	queue *keyedTaskQueue
+}
+
+// keyedLane is a queue and the executor which owns it
+type keyedLane struct {
+	queue    TaskQueue
+	executor *Executor
+}
+
+// Tells whether the lane's queue has stopped publishing changes, as opposed to unsubscribing a subscriber
+// which fell behind. Queues which can't tell are taken as stopped, their subscriptions end on their own.
+func (l *keyedLane) stopped() bool {
+	queue, ok := l.queue.(publishingQueue)
+	if !ok {
+		return true
+	}
+
+	select {
+	case <-queue.publishingDone():
+		return true
+	default:
+		return false
+	}
+}
+
+// keyedTaskQueue routes pushed tasks to their lanes. Task ids don't tell the lane, so operations
+// on a single task ask the lanes one by one.
+type keyedTaskQueue struct {
+	lanes []*keyedLane
+
+	// Picks lane of the next task without a key
+	nextUnkeyedLane atomic.Uint64
+
+	logger logging.Logger
+}
+
+func NewKeyedChannelQueueExecutor(options ...Option) (TaskQueue, *KeyedExecutor) {
+	return NewKeyedExecutor(NewTaskQueue, options...)
+}
+
+func NewKeyedLockingQueueExecutor(options ...Option) (TaskQueue, *KeyedExecutor) {
+	return NewKeyedExecutor(NewLockingTaskQueue, options...)
+}
+
+// NewKeyedExecutor creates `WithLanes` lanes, each with a queue created by `newQueue`. Options are passed to
+// every lane, so e.g. capacity applies to each lane separately. Every lane has exactly one worker.
+func NewKeyedExecutor(newQueue func(options ...Option) TaskQueue, options ...Option) (TaskQueue, *KeyedExecutor) {
+	cfg := newConfig(options)
+	queue := &keyedTaskQueue{lanes: make([]*keyedLane, 0, cfg.lanes), logger: cfg.logger}
+	for i := 0; i < cfg.lanes; i++ {
+		// ordering within the lane is the whole point
+		laneOptions := append(append([]Option{}, options...),
+			WithWorkers(1), WithLogger(cfg.logger.With(logging.Any("lane", i))))
+		laneQueue := newQueue(laneOptions...)
+		queue.lanes = append(queue.lanes, &keyedLane{
+			queue:    laneQueue,
+			executor: NewExecutor(laneQueue, laneOptions...),
+		})
+	}
+
+	return queue, &KeyedExecutor{queue: queue}
+}
+
+// Lanes returns number of the lanes
+func (e *KeyedExecutor) Lanes() int {
+	return len(e.queue.lanes)
+}
+
+// Lane returns index of the lane running tasks with the key
+func (e *KeyedExecutor) Lane(key string) int {
+	return e.queue.laneIndex(key)
+}
+
+// Shutdown shuts down all lanes at once, see `Executor.Shutdown`. Returns the first error of a lane.
+func (e *KeyedExecutor) Shutdown(ctx context.Context) error {
+	errs := make(chan error, len(e.queue.lanes))
+	for _, lane := range e.queue.lanes {
+		go func(lane *keyedLane) {
+			errs <- lane.executor.Shutdown(ctx)
+		}(lane)
+	}
+
+	var firstErr error
+	for range e.queue.lanes {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Stop stops all lanes, see `Executor.Stop`
+func (e *KeyedExecutor) Stop() {
+	for _, lane := range e.queue.lanes {
+		lane.executor.Stop()
+	}
+}
+
+func (q *keyedTaskQueue) laneIndex(key string) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	return int(hash.Sum32() % uint32(len(q.lanes)))
+}
+
+func (q *keyedTaskQueue) laneOf(task Task) *keyedLane {
+	if task.PartitionKey == "" {
+		// duplicates have to end up in the lane which knows the original task
+		if task.IdempotencyKey != "" {
+			return q.lanes[q.laneIndex(task.IdempotencyKey)]
+		}
+		return q.lanes[(q.nextUnkeyedLane.Add(1)-1)%uint64(len(q.lanes))]
+	}
+	return q.lanes[q.laneIndex(task.PartitionKey)]
+}
+
+// Returns lane which knows the task, nil if none does
+func (q *keyedTaskQueue) findLane(id string) (*keyedLane, *TaskInfo) {
+	for _, lane := range q.lanes {
+		if info := lane.queue.Get(id); info != nil {
+			return lane, info
+		}
+	}
+	return nil, nil
+}
+
+// Pop always returns nil, tasks are popped by executors of the lanes
+func (q *keyedTaskQueue) Pop() *Task {
+	return nil
+}
+
+// PopContext always returns `ErrPopNotSupported`, tasks are popped by executors of the lanes
+func (q *keyedTaskQueue) PopContext(ctx context.Context) (*Task, error) {
+	return nil, ErrPopNotSupported
+}
+
+func (q *keyedTaskQueue) Push(task Task) (string, error) {
+	return q.laneOf(task).queue.Push(task)
+}
+
+func (q *keyedTaskQueue) PushAt(task Task, dueAt time.Time) (string, error) {
+	return q.laneOf(task).queue.PushAt(task, dueAt)
+}
+
+func (q *keyedTaskQueue) PushAfter(task Task, delay time.Duration) (string, error) {
+	return q.laneOf(task).queue.PushAfter(task, delay)
+}
+
+func (q *keyedTaskQueue) Submit(task Task) (*TaskHandle, error) {
+	return q.laneOf(task).queue.Submit(task)
+}
+
+func (q *keyedTaskQueue) Complete(id string, err error) {
+	lane, _ := q.findLane(id)
+	if lane == nil {
+		q.logger.Warn("completed task is not known to any lane, ignoring", logging.TaskId(id))
+		return
+	}
+	lane.queue.Complete(id, err)
+}
+
+func (q *keyedTaskQueue) Cancel(id string) bool {
+	lane, _ := q.findLane(id)
+	return lane != nil && lane.queue.Cancel(id)
+}
+
+// List returns tasks of all lanes, in the order they were pushed
+func (q *keyedTaskQueue) List() []TaskInfo {
+	tasks := make([]TaskInfo, 0)
+	for _, lane := range q.lanes {
+		tasks = append(tasks, lane.queue.List()...)
+	}
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].EnqueuedAt.Before(tasks[j].EnqueuedAt)
+	})
+	return tasks
+}
+
+func (q *keyedTaskQueue) Get(id string) *TaskInfo {
+	_, info := q.findLane(id)
+	return info
+}
+
+// DeadLetters returns failed tasks of all lanes, the oldest failure first
+func (q *keyedTaskQueue) DeadLetters() []TaskInfo {
+	deadLetters := make([]TaskInfo, 0)
+	for _, lane := range q.lanes {
+		deadLetters = append(deadLetters, lane.queue.DeadLetters()...)
+	}
+
+	sort.SliceStable(deadLetters, func(i, j int) bool {
+		return deadLetters[i].FinishedAt.Before(deadLetters[j].FinishedAt)
+	})
+	return deadLetters
+}
+
+func (q *keyedTaskQueue) Requeue(id string) error {
+	lane, _ := q.findLane(id)
+	if lane == nil {
+		return ErrNotDeadLettered
+	}
+	return lane.queue.Requeue(id)
+}
+
+// Subscribe merges changes of all lanes. Channel is closed once the context is done, all lanes stopped,
+// or any lane has unsubscribed the subscriber for not keeping up.
+func (q *keyedTaskQueue) Subscribe(ctx context.Context) <-chan TaskInfo {
+	events := make(chan TaskInfo)
+	// lane which has dropped the subscriber ends subscriptions to the others
+	ctx, cancel := context.WithCancel(ctx)
+
+	forwarders := sync.WaitGroup{}
+	forwarders.Add(len(q.lanes))
+	for _, lane := range q.lanes {
+		go func(lane *keyedLane, laneEvents <-chan TaskInfo) {
+			defer forwarders.Done()
+
+			for info := range laneEvents {
+				select {
+				case events <- info:
+				case <-ctx.Done():
+					// lane closes its channel on its own
+				}
+			}
+			if !lane.stopped() {
+				cancel()
+			}
+		}(lane, lane.queue.Subscribe(ctx))
+	}
+
+	go func() {
+		forwarders.Wait()
+		cancel()
+		close(events)
+	}()
+
+	return events
+}
+
+// Stats sums counters of all lanes
+func (q *keyedTaskQueue) Stats() QueueStats {
+	total := QueueStats{}
+	for _, lane := range q.lanes {
+		stats := lane.queue.Stats()
+		total.Waiting += stats.Waiting
+		total.Running += stats.Running
+		total.Capacity += stats.Capacity
+		total.Rejected += stats.Rejected
+		total.Dropped += stats.Dropped
+	}
+	return total
+}
+
+// Shutdown shuts down queues of all lanes one by one, returns the first error of a lane
+func (q *keyedTaskQueue) Shutdown(ctx context.Context) error {
+	var firstErr error
+	for _, lane := range q.lanes {
+		if err := lane.queue.Shutdown(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (q *keyedTaskQueue) Stop() {
+	for _, lane := range q.lanes {
+		lane.queue.Stop()
+	}
+}
@@ -0,0 +1,232 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"gotest.tools/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+var keyedExecutorConstructors = []struct {
+	name        string
+	newExecutor func(options ...Option) (TaskQueue, *KeyedExecutor)
+}{
+	{name: "keyed channel queue executor", newExecutor: NewKeyedChannelQueueExecutor},
+	{name: "keyed locking queue executor", newExecutor: NewKeyedLockingQueueExecutor},
+}
+
+// Appends its sequence number to the slice of its key. There is no lock on purpose - tasks with the same
+// key running at the same time are reported by the race detector.
+type sequenceRecordingExecutable struct {
+	sequence int
+	recorded *[]int
+}
+
+func (e *sequenceRecordingExecutable) Execute() error {
+	*e.recorded = append(*e.recorded, e.sequence)
+	// give other tasks of the key a chance to overlap, if the executor let them
+	time.Sleep(time.Millisecond)
+	return nil
+}
+
+func TestKeyedExecutorKeepsOrderPerKey(t *testing.T) {
+	for _, constructor := range keyedExecutorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor(WithLanes(4))
+
+			keys := 6
+			tasksPerKey := 30
+			recorded := make(map[string]*[]int)
+			for i := 0; i < keys; i++ {
+				recorded[fmt.Sprintf("volume-%v", i)] = &[]int{}
+			}
+
+			// every key is pushed by its own goroutine, so the order of pushes is known per key only
+			operations := make([]ExecutableTestOperation, 0, keys)
+			for key, sequences := range recorded {
+				key, sequences := key, sequences
+				operations = append(operations, func() error {
+					for sequence := 0; sequence < tasksPerKey; sequence++ {
+						task := Task{
+							TaskExecutable: &sequenceRecordingExecutable{sequence: sequence, recorded: sequences},
+							PartitionKey:   key,
+						}
+						if _, err := queue.Push(task); err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+			}
+			for _, err := range ParallelOperationsExecutor(t, 4, operations) {
+				assert.NilError(t, err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			assert.NilError(t, executor.Shutdown(ctx))
+
+			for key, sequences := range recorded {
+				assert.Equal(t, tasksPerKey, len(*sequences), key)
+				for index, sequence := range *sequences {
+					assert.Equal(t, index, sequence, key)
+				}
+			}
+			assert.Equal(t, keys*tasksPerKey, len(queue.List()))
+		})
+	}
+}
+
+func TestKeyedExecutorRunsKeysInParallel(t *testing.T) {
+	for _, constructor := range keyedExecutorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor(WithLanes(4))
+			defer executor.Stop()
+
+			// find two keys which don't share a lane
+			firstKey, secondKey := "volume-0", ""
+			for i := 1; secondKey == ""; i++ {
+				if key := fmt.Sprintf("volume-%v", i); executor.Lane(key) != executor.Lane(firstKey) {
+					secondKey = key
+				}
+			}
+
+			release := make(chan struct{})
+			firstId := mustPush(t, queue, Task{TaskExecutable: &blockingExecutable{release: release}, PartitionKey: firstKey})
+			queuedId := mustPush(t, queue, Task{TaskExecutable: &blockingExecutable{release: release}, PartitionKey: firstKey})
+			secondId := mustPush(t, queue, Task{TaskExecutable: &blockingExecutable{release: release}, PartitionKey: secondKey})
+
+			// different keys run at once, the same key waits
+			waitForTaskState(t, queue, firstId, TaskRunning)
+			waitForTaskState(t, queue, secondId, TaskRunning)
+			assert.Equal(t, TaskQueued, queue.Get(queuedId).State)
+			assert.Equal(t, firstKey, queue.Get(queuedId).PartitionKey)
+			assert.Equal(t, 1, queue.Stats().Waiting)
+
+			assert.Check(t, queue.Cancel(queuedId))
+			assert.Equal(t, TaskCancelled, queue.Get(queuedId).State)
+			assert.Check(t, queue.Get("unknown") == nil)
+
+			close(release)
+			assert.Equal(t, TaskSucceeded, waitForFinishedTask(queue, firstId, 5*time.Second).State)
+			assert.Equal(t, TaskSucceeded, waitForFinishedTask(queue, secondId, 5*time.Second).State)
+		})
+	}
+}
+
+func TestKeyedExecutorSubscribe(t *testing.T) {
+	queue, executor := NewKeyedLockingQueueExecutor(WithLanes(3))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := queue.Subscribe(ctx)
+
+	succeeded := make(map[string]bool)
+	lock := sync.Mutex{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for info := range events {
+			if info.State == TaskSucceeded {
+				lock.Lock()
+				succeeded[info.Id] = true
+				lock.Unlock()
+			}
+		}
+	}()
+
+	ids := make([]string, 0)
+	for i := 0; i < 6; i++ {
+		ids = append(ids, mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, PartitionKey: fmt.Sprintf("volume-%v", i)}))
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	assert.NilError(t, executor.Shutdown(shutdownCtx))
+
+	// stream ends once all lanes stopped
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not end")
+	}
+	for _, id := range ids {
+		assert.Check(t, succeeded[id], id)
+	}
+}
+
+func TestKeyedExecutorSlowSubscriberIsUnsubscribed(t *testing.T) {
+	queue, executor := NewKeyedLockingQueueExecutor(WithLanes(2))
+	defer executor.Stop()
+
+	slow := queue.Subscribe(context.Background())
+	ids := make([]string, 0)
+	for i := 0; i <= subscriptionBufferSize; i++ {
+		ids = append(ids, mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, PartitionKey: "volume-1"}))
+	}
+	// tasks of the same key run in order
+	assert.Equal(t, TaskSucceeded, waitForFinishedTask(queue, ids[len(ids)-1], 5*time.Second).State)
+
+	// the lane has dropped the subscriber, the merged stream ends even though the other lane still runs
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case _, open := <-slow:
+			if !open {
+				return
+			}
+		case <-deadline:
+			t.Fatal("stream did not end")
+		}
+	}
+}
+
+func TestKeyedExecutorRetryIsReordered(t *testing.T) {
+	for _, constructor := range keyedExecutorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor(WithLanes(1))
+			defer executor.Stop()
+
+			retriedId := mustPush(t, queue, Task{
+				TaskExecutable: &flakyExecutable{failures: 1},
+				Retry:          &RetryPolicy{MaxAttempts: 2, InitialBackoff: 50 * time.Millisecond},
+				PartitionKey:   "volume-1",
+			})
+			nextId := mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, PartitionKey: "volume-1"})
+
+			retried := waitForFinishedTask(queue, retriedId, 5*time.Second)
+			next := waitForFinishedTask(queue, nextId, 5*time.Second)
+			assert.Equal(t, TaskSucceeded, retried.State)
+			assert.Equal(t, 2, retried.Attempts)
+			// the next task of the key hasn't waited for the retry
+			assert.Check(t, next.FinishedAt.Before(retried.StartedAt))
+		})
+	}
+}
+
+func TestKeyedExecutorAbandonedExecutionOverlaps(t *testing.T) {
+	for _, constructor := range keyedExecutorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor(WithLanes(1), WithTimeoutPolicy(TimeoutAbandon))
+			defer executor.Stop()
+
+			release := make(chan struct{})
+			defer close(release)
+			abandonedId := mustPush(t, queue, Task{
+				TaskExecutable: ExecutableFunc(func(ctx context.Context) error {
+					<-release
+					return nil
+				}),
+				Timeout:      20 * time.Millisecond,
+				PartitionKey: "volume-1",
+			})
+			nextId := mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, PartitionKey: "volume-1"})
+
+			// the next task of the key runs while the abandoned execution is still blocked
+			assert.Equal(t, TaskSucceeded, waitForFinishedTask(queue, nextId, 5*time.Second).State)
+			assert.Equal(t, TaskTimedOut, queue.Get(abandonedId).State)
+		})
+	}
+}
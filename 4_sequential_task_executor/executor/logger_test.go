@@ -0,0 +1,111 @@
+package executor
+
+import (
+	"AwesomePresentation/logging"
+	"context"
+	"gotest.tools/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+type logEntry struct {
+	level  string
+	msg    string
+	fields map[string]interface{}
+}
+
+// Keeps entries in memory, loggers created by `With` share them
+type recordingLogger struct {
+	lock    *sync.Mutex
+	entries *[]logEntry
+	fields  []logging.Field
+}
+
+func newRecordingLogger() *recordingLogger {
+	return &recordingLogger{lock: &sync.Mutex{}, entries: &[]logEntry{}}
+}
+
+func (l *recordingLogger) Debug(msg string, fields ...logging.Field) { l.log("debug", msg, fields) }
+func (l *recordingLogger) Info(msg string, fields ...logging.Field)  { l.log("info", msg, fields) }
+func (l *recordingLogger) Warn(msg string, fields ...logging.Field)  { l.log("warn", msg, fields) }
+func (l *recordingLogger) Error(msg string, fields ...logging.Field) { l.log("error", msg, fields) }
+
+func (l *recordingLogger) With(fields ...logging.Field) logging.Logger {
+	return &recordingLogger{lock: l.lock, entries: l.entries, fields: append(append([]logging.Field{}, l.fields...), fields...)}
+}
+
+func (l *recordingLogger) log(level string, msg string, fields []logging.Field) {
+	entry := logEntry{level: level, msg: msg, fields: make(map[string]interface{})}
+	for _, field := range append(append([]logging.Field{}, l.fields...), fields...) {
+		entry.fields[field.Key] = field.Value
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	*l.entries = append(*l.entries, entry)
+}
+
+// Entries with the message
+func (l *recordingLogger) find(msg string) []logEntry {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	found := make([]logEntry, 0)
+	for _, entry := range *l.entries {
+		if entry.msg == msg {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+func TestExecutableLogsWithTaskId(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			logger := newRecordingLogger()
+			queue, executor := constructor.newExecutor(WithLogger(logger))
+			defer executor.Stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			handle, err := queue.Submit(NewExecutableQuickie())
+			assert.NilError(t, err)
+			assert.NilError(t, handle.Wait(ctx))
+
+			entries := logger.find("Quickie finished")
+			assert.Equal(t, 1, len(entries))
+			assert.Equal(t, "info", entries[0].level)
+			assert.Equal(t, handle.Id(), entries[0].fields["taskId"])
+		})
+	}
+}
+
+func TestRetryIsLogged(t *testing.T) {
+	logger := newRecordingLogger()
+	queue := NewLockingTaskQueue(WithLogger(logger))
+	defer queue.Stop()
+
+	id := mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, Retry: &RetryPolicy{MaxAttempts: 2}})
+	queue.Pop()
+	queue.Complete(id, errFlaky)
+
+	entries := logger.find("task failed, retrying")
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, id, entries[0].fields["taskId"])
+	assert.Equal(t, errFlaky, entries[0].fields["error"])
+}
+
+func TestLoggerIsOptional(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue(WithLogger(nil))
+			defer queue.Stop()
+
+			id := mustPush(t, queue, NewExecutableQuickie())
+			queue.Complete(id, nil)
+			assert.Equal(t, TaskQueued, queue.Get(id).State)
+		})
+	}
+}
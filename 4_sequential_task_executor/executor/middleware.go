@@ -0,0 +1,68 @@
+package executor
+
+import "context"
+
+// Middleware wraps executables of all tasks run by an executor, e.g. to time them, check permissions or limit
+// their rate, see `WithMiddleware`. Returned executable should pass the context on to the next one, which is
+// easiest with `ExecutableFunc` and `AdaptExecutable`:
+//
+//	func(next Executable) Executable {
+//		return ExecutableFunc(func(ctx context.Context) error {
+//			started := time.Now()
+//			defer func() { fmt.Println(TaskIdFromContext(ctx), time.Since(started)) }()
+//			return AdaptExecutable(next).ExecuteContext(ctx)
+//		})
+//	}
+//
+// Returning without calling the next executable skips the task's executable, the returned error becomes
+// the task's error.
+type Middleware func(next Executable) Executable
+
+// ExecutableFunc makes a function usable as `Executable`, mostly for middleware
+type ExecutableFunc func(ctx context.Context) error
+
+func (f ExecutableFunc) Execute() error {
+	return f(context.Background())
+}
+
+func (f ExecutableFunc) ExecuteContext(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Hooks are called as tasks go through the queue and the executor, see `WithHooks`. Any of them can be nil.
+//
+// `OnEnqueue` is called by the queue while it's adding the task, it must be quick and must not call the queue.
+// The others are called by the worker executing the task, with the context the task is executed with.
+type Hooks struct {
+	// Task has been accepted by the queue, its id is already set. Not called for duplicates, see `IdempotencyKey`
+	OnEnqueue func(task Task)
+	// Worker is about to execute the task, called for every attempt
+	OnStart func(ctx context.Context, task Task)
+	// Execution has returned nil
+	OnSuccess func(ctx context.Context, task Task)
+	// Execution has returned the error. Task might still be retried, see `RetryPolicy`
+	OnFailure func(ctx context.Context, task Task, err error)
+	// Execution has panicked, `OnFailure` is not called then
+	OnPanic func(ctx context.Context, task Task, err *PanicError)
+}
+
+// Wraps the executable with the middleware, the first one ends up the outermost
+func applyMiddleware(executable Executable, middleware []Middleware) Executable {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		executable = middleware[i](executable)
+	}
+	return executable
+}
+
+type taskIdContextKey struct{}
+
+func withTaskId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, taskIdContextKey{}, id)
+}
+
+// TaskIdFromContext returns id of the task being executed, executables and middleware get it through
+// the context passed to `ExecuteContext`. Empty if the context doesn't belong to a popped task.
+func TaskIdFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(taskIdContextKey{}).(string)
+	return id
+}
@@ -0,0 +1,187 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gotest.tools/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Records calls of hooks and middleware, safe for concurrent use
+type callRecorder struct {
+	lock  sync.Mutex
+	calls []string
+}
+
+func (r *callRecorder) record(format string, args ...interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.calls = append(r.calls, fmt.Sprintf(format, args...))
+}
+
+func (r *callRecorder) recorded() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string{}, r.calls...)
+}
+
+func (r *callRecorder) hooks(name string) Hooks {
+	return Hooks{
+		OnEnqueue: func(task Task) { r.record("%v enqueue", name) },
+		OnStart:   func(ctx context.Context, task Task) { r.record("%v start", name) },
+		OnSuccess: func(ctx context.Context, task Task) { r.record("%v success", name) },
+		OnFailure: func(ctx context.Context, task Task, err error) { r.record("%v failure: %v", name, err) },
+		OnPanic:   func(ctx context.Context, task Task, err *PanicError) { r.record("%v panic: %v", name, err.Value) },
+	}
+}
+
+func (r *callRecorder) middleware(name string) Middleware {
+	return func(next Executable) Executable {
+		return ExecutableFunc(func(ctx context.Context) error {
+			r.record("%v before", name)
+			err := AdaptExecutable(next).ExecuteContext(ctx)
+			r.record("%v after", name)
+			return err
+		})
+	}
+}
+
+func TestHooks(t *testing.T) {
+	tests := []struct {
+		name          string
+		newExecutable func() Executable
+		expectedCalls []string
+	}{
+		{
+			name:          "success",
+			newExecutable: func() Executable { return &ExecutableQuickie{} },
+			expectedCalls: []string{
+				"first enqueue", "second enqueue",
+				"first start", "second start",
+				"first success", "second success",
+			},
+		},
+		{
+			name:          "failure",
+			newExecutable: func() Executable { return &flakyExecutable{failures: 1} },
+			expectedCalls: []string{
+				"first enqueue", "second enqueue",
+				"first start", "second start",
+				"first failure: attempt 1: flaky failure", "second failure: attempt 1: flaky failure",
+			},
+		},
+		{
+			name:          "panic",
+			newExecutable: func() Executable { return &panickingExecutable{panics: 1} },
+			expectedCalls: []string{
+				"first enqueue", "second enqueue",
+				"first start", "second start",
+				"first panic: something went terribly wrong", "second panic: something went terribly wrong",
+			},
+		},
+	}
+
+	for _, constructor := range executorConstructors {
+		for _, test := range tests {
+			t.Run(constructor.name+": "+test.name, func(t *testing.T) {
+				recorder := &callRecorder{}
+				queue, executor := constructor.newExecutor(WithHooks(recorder.hooks("first")),
+					WithHooks(recorder.hooks("second")))
+				defer executor.Stop()
+
+				id := mustPush(t, queue, Task{TaskExecutable: test.newExecutable()})
+				assert.Check(t, waitForFinishedTask(queue, id, 5*time.Second).State.IsFinished())
+
+				assert.DeepEqual(t, test.expectedCalls, recorder.recorded())
+			})
+		}
+	}
+}
+
+func TestPanickingHookDoesNotAffectTask(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			panicking := Hooks{
+				OnEnqueue: func(task Task) { panic("enqueue") },
+				OnStart:   func(ctx context.Context, task Task) { panic("start") },
+				OnSuccess: func(ctx context.Context, task Task) { panic("success") },
+			}
+			queue, executor := constructor.newExecutor(WithHooks(panicking))
+			defer executor.Stop()
+
+			id := mustPush(t, queue, NewExecutableQuickie())
+			assert.Equal(t, TaskSucceeded, waitForFinishedTask(queue, id, 5*time.Second).State)
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			recorder := &callRecorder{}
+			taskIds := make(chan string, 1)
+			inner := func(next Executable) Executable {
+				return ExecutableFunc(func(ctx context.Context) error {
+					taskIds <- TaskIdFromContext(ctx)
+					return AdaptExecutable(next).ExecuteContext(ctx)
+				})
+			}
+			queue, executor := constructor.newExecutor(WithMiddleware(recorder.middleware("outer")),
+				WithMiddleware(recorder.middleware("middle"), inner), WithHooks(recorder.hooks("hook")))
+			defer executor.Stop()
+
+			id := mustPush(t, queue, NewExecutableQuickie())
+			assert.Equal(t, TaskSucceeded, waitForFinishedTask(queue, id, 5*time.Second).State)
+
+			assert.Equal(t, id, <-taskIds)
+			assert.DeepEqual(t, []string{
+				"hook enqueue", "hook start",
+				"outer before", "middle before", "middle after", "outer after",
+				"hook success",
+			}, recorder.recorded())
+		})
+	}
+}
+
+func TestMiddlewareCanRejectTask(t *testing.T) {
+	errForbidden := errors.New("forbidden")
+
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			executed := make(chan struct{}, 1)
+			deny := func(next Executable) Executable {
+				return ExecutableFunc(func(ctx context.Context) error {
+					return errForbidden
+				})
+			}
+			panicking := func(next Executable) Executable {
+				return ExecutableFunc(func(ctx context.Context) error {
+					panic("middleware went wrong")
+				})
+			}
+			queue, executor := constructor.newExecutor(WithMiddleware(deny))
+			defer executor.Stop()
+
+			id := mustPush(t, queue, Task{TaskExecutable: ExecutableFunc(func(ctx context.Context) error {
+				executed <- struct{}{}
+				return nil
+			})})
+			info := waitForFinishedTask(queue, id, 5*time.Second)
+			assert.Equal(t, TaskFailed, info.State)
+			assert.Check(t, errors.Is(info.Error, errForbidden))
+			assert.Equal(t, 0, len(executed))
+
+			// panic of middleware is handled like panic of the executable
+			panickingQueue, panickingExecutor := constructor.newExecutor(WithMiddleware(panicking))
+			defer panickingExecutor.Stop()
+
+			id = mustPush(t, panickingQueue, NewExecutableQuickie())
+			info = waitForFinishedTask(panickingQueue, id, 5*time.Second)
+			var panicErr *PanicError
+			assert.Check(t, errors.As(info.Error, &panicErr))
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package executor
+
+import (
+	"AwesomePresentation/logging"
+	"AwesomePresentation/metrics"
+	"AwesomePresentation/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"runtime"
+	"time"
+)
+
+// How many finished tasks are remembered by the queue when no other value is configured
+const DefaultFinishedTaskRetention = 100
+
+// How many failed tasks are kept in the dead letter queue when no other value is configured
+const DefaultDeadLetterRetention = 100
+
+// How often the persistent queue flushes its log with `SyncPeriodically`, when no other value is configured
+const DefaultSyncInterval = 100 * time.Millisecond
+
+// How often the persistent queue compacts its log when no other value is configured
+const DefaultCompactionInterval = time.Minute
+
+// Name of the tracer queues and executors trace tasks with, see `WithTracerProvider`
+const tracerName = "AwesomePresentation/4_sequential_task_executor/executor"
+
+// Option configures queues and executors created by this package. The same set of options can be passed
+// to every constructor - options which are not relevant for the created component are simply ignored.
+// This way `NewLockingQueueExecutor(...)` can hand the options over to both the queue and the executor.
+type Option func(*config)
+
+type config struct {
+	finishedTaskRetention int
+	deadLetterRetention   int
+	drainOnShutdown       bool
+	priorityOrdering      bool
+	priorityAgingInterval time.Duration
+	syncPolicy            SyncPolicy
+	syncInterval          time.Duration
+	compactionInterval    time.Duration
+	capacity              int
+	overflowPolicy        OverflowPolicy
+	workers               int
+	lanes                 int
+	idempotencyWindow     time.Duration
+	logger                logging.Logger
+	metrics               *metrics.Registry
+	tracerProvider        trace.TracerProvider
+	middleware            []Middleware
+	hooks                 []Hooks
+	taskTimeout           time.Duration
+	timeoutPolicy         TimeoutPolicy
+}
+
+func newConfig(options []Option) config {
+	cfg := config{
+		finishedTaskRetention: DefaultFinishedTaskRetention,
+		deadLetterRetention:   DefaultDeadLetterRetention,
+		drainOnShutdown:       true,
+		syncPolicy:            SyncEveryWrite,
+		syncInterval:          DefaultSyncInterval,
+		compactionInterval:    DefaultCompactionInterval,
+		workers:               1,
+		lanes:                 runtime.NumCPU(),
+		logger:                logging.Nop(),
+		tracerProvider:        noop.NewTracerProvider(),
+	}
+
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	return cfg
+}
+
+// WithFinishedTaskRetention limits how many finished (succeeded, failed or cancelled) tasks are kept,
+// so they can still be fetched with `Get` and `List`. Once the limit is reached the oldest finished task
+// is forgotten. Zero means finished tasks are forgotten right away.
+func WithFinishedTaskRetention(retention int) Option {
+	return func(c *config) {
+		if retention < 0 {
+			retention = 0
+		}
+		c.finishedTaskRetention = retention
+	}
+}
+
+// WithDeadLetterRetention limits how many failed (or timed out) tasks are kept in the dead letter queue. Once the limit
+// is reached the oldest dead letter is forgotten. Zero means failed tasks are forgotten right away.
+func WithDeadLetterRetention(retention int) Option {
+	return func(c *config) {
+		if retention < 0 {
+			retention = 0
+		}
+		c.deadLetterRetention = retention
+	}
+}
+
+// WithDrainOnShutdown decides what happens to queued tasks on `Shutdown`. By default they are all executed
+// before the shutdown completes, with `false` they are cancelled and only the running task is awaited.
+func WithDrainOnShutdown(drain bool) Option {
+	return func(c *config) {
+		c.drainOnShutdown = drain
+	}
+}
+
+// Makes the queue pop tasks by their priority, used by priority queue constructors
+func withPriorityOrdering() Option {
+	return func(c *config) {
+		c.priorityOrdering = true
+	}
+}
+
+// WithPriorityAging makes waiting tasks in priority queues gain one priority level per each `interval` spent
+// in the queue, so low priority tasks eventually run even if higher priority ones keep coming.
+// Zero (the default) disables aging.
+func WithPriorityAging(interval time.Duration) Option {
+	return func(c *config) {
+		c.priorityAgingInterval = interval
+	}
+}
+
+// WithSyncPolicy decides when the persistent queue flushes its log to the disk, see `SyncPolicy`
+func WithSyncPolicy(policy SyncPolicy) Option {
+	return func(c *config) {
+		c.syncPolicy = policy
+	}
+}
+
+// WithSyncInterval sets how often the persistent queue flushes its log with `SyncPeriodically`
+func WithSyncInterval(interval time.Duration) Option {
+	return func(c *config) {
+		if interval > 0 {
+			c.syncInterval = interval
+		}
+	}
+}
+
+// WithCompactionInterval sets how often the persistent queue rewrites its log, dropping records of
+// finished tasks. The log is always compacted when the queue is opened.
+func WithCompactionInterval(interval time.Duration) Option {
+	return func(c *config) {
+		if interval > 0 {
+			c.compactionInterval = interval
+		}
+	}
+}
+
+// WithCapacity limits how many tasks can wait in the queue - queued, scheduled and blocked ones. Policy decides
+// what happens to tasks pushed once the limit is reached, see `OverflowPolicy`. Running tasks don't count.
+// Failed tasks scheduled for a retry are never rejected, as they have been accepted already, but they take
+// space until they run again and `OverflowDropOldest` may drop them. Zero (the default) means the queue
+// is not bounded.
+func WithCapacity(capacity int, policy OverflowPolicy) Option {
+	return func(c *config) {
+		if capacity < 0 {
+			capacity = 0
+		}
+		c.capacity = capacity
+		c.overflowPolicy = policy
+	}
+}
+
+// WithWorkers sets how many tasks the executor runs at the same time. With one worker (the default) tasks
+// run strictly one after another, more workers still pop them in order but finish in any order.
+// The number can be changed later with `Executor.Resize`.
+func WithWorkers(workers int) Option {
+	return func(c *config) {
+		if workers > 0 {
+			c.workers = workers
+		}
+	}
+}
+
+// WithIdempotencyWindow sets how long the key of a finished task is remembered, so that pushing the same
+// `Task.IdempotencyKey` still returns the finished task instead of running it again. Keys of queued and running
+// tasks are always remembered. Zero (the default) forgets the key as soon as the task finishes.
+func WithIdempotencyWindow(window time.Duration) Option {
+	return func(c *config) {
+		if window < 0 {
+			window = 0
+		}
+		c.idempotencyWindow = window
+	}
+}
+
+// WithTaskTimeout sets timeout of tasks without `Task.Timeout` of their own. By default they have none.
+//
+// Timeout frees the worker of a hung task only if the task can be stopped or left behind: `ContextExecutable`s
+// have their context cancelled, but one ignoring the cancellation keeps holding the worker unless
+// `TimeoutAbandon` is set, see `WithTimeoutPolicy`. Plain `Executable`s are always abandoned.
+func WithTaskTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		if timeout < 0 {
+			timeout = 0
+		}
+		c.taskTimeout = timeout
+	}
+}
+
+// WithTimeoutPolicy sets what executors do with executables which keep running after their task has timed out,
+// see `TimeoutPolicy`. By default they wait for `ContextExecutable`s (`TimeoutWait`) and abandon the others.
+func WithTimeoutPolicy(policy TimeoutPolicy) Option {
+	return func(c *config) {
+		c.timeoutPolicy = policy
+	}
+}
+
+// WithLogger sets where queues and executors log to. By default nothing is logged. Executables get the logger
+// (with id of the task attached) through their context, see `logging.FromContext`.
+func WithLogger(logger logging.Logger) Option {
+	return func(c *config) {
+		c.logger = logging.OrNop(logger)
+	}
+}
+
+// WithMetrics sets registry queues and executors record their metrics in: length of the queue, how long tasks
+// wait before they start, how long their executions take and how they end. By default nothing is recorded.
+// Serve the registry over HTTP to let Prometheus scrape it, see `metrics.Registry.ServeHTTP`.
+func WithMetrics(registry *metrics.Registry) Option {
+	return func(c *config) {
+		c.metrics = registry
+	}
+}
+
+// WithMiddleware wraps executables of all tasks run by executors, see `Middleware`. Middleware passed first
+// is the outermost, passing the option again adds more middleware inside of the previous ones.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(c *config) {
+		c.middleware = append(append([]Middleware{}, c.middleware...), middleware...)
+	}
+}
+
+// WithHooks adds hooks called by queues and executors as tasks go through them, see `Hooks`. Passing
+// the option again adds another set of hooks, called after the previous ones.
+func WithHooks(hooks Hooks) Option {
+	return func(c *config) {
+		c.hooks = append(append([]Hooks{}, c.hooks...), hooks)
+	}
+}
+
+// WithTracerProvider sets provider of the tracer queues and executors trace tasks with. Time a task spends
+// waiting in the queue and each of its executions are traced as children of `Task.TraceContext`. Executables
+// get the execution span through their context, so they can trace their own work as its children.
+// By default nothing is traced.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tracing.OrNoop(provider)
+	}
+}
+
+// WithLanes sets how many sequential lanes keyed executors spread the tasks over, see `NewKeyedExecutor`.
+// By default there is one lane per CPU.
+func WithLanes(lanes int) Option {
+	return func(c *config) {
+		if lanes > 0 {
+			c.lanes = lanes
+		}
+	}
+}
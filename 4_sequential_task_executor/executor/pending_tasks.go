@@ -0,0 +1,180 @@
+package executor
+
+import (
+	"container/heap"
+	"time"
+)
+
+// pendingTasks decides in which order queued tasks are popped. Like the ledger, it's not thread safe.
+type pendingTasks interface {
+	push(record *taskRecord)
+
+	// Removes and returns the task which should run next, nil if there is none
+	pop() *taskRecord
+
+	// Removes given task, returns false if it wasn't pending
+	remove(record *taskRecord) bool
+
+	// Removes and returns all pending tasks
+	clear() []*taskRecord
+
+	len() int
+}
+
+// fifoPendingTasks pops tasks in the order they were pushed
+type fifoPendingTasks struct {
+	records []*taskRecord
+}
+
+func newFifoPendingTasks() *fifoPendingTasks {
+	return &fifoPendingTasks{records: make([]*taskRecord, 0)}
+}
+
+func (p *fifoPendingTasks) push(record *taskRecord) {
+	p.records = append(p.records, record)
+}
+
+func (p *fifoPendingTasks) pop() *taskRecord {
+	if len(p.records) == 0 {
+		return nil
+	}
+
+	first := p.records[0]
+	p.records = p.records[1:]
+	return first
+}
+
+func (p *fifoPendingTasks) remove(record *taskRecord) bool {
+	for index, pendingRecord := range p.records {
+		if pendingRecord == record {
+			p.records = append(p.records[:index], p.records[index+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (p *fifoPendingTasks) clear() []*taskRecord {
+	cleared := p.records
+	p.records = make([]*taskRecord, 0)
+	return cleared
+}
+
+func (p *fifoPendingTasks) len() int {
+	return len(p.records)
+}
+
+// priorityPendingTasks pops tasks with the highest priority first, tasks of equal priority in the order
+// they were pushed. With aging, waiting task gains one priority level every `agingInterval`, so that
+// low priority tasks are not starved by a steady stream of important ones.
+//
+// Since every waiting task ages at the same pace, comparing `priority + waited/agingInterval` of two tasks
+// gives the same answer at any moment - which means it's enough to compute the score once, when pushing.
+type priorityPendingTasks struct {
+	items    priorityHeap
+	itemsMap map[*taskRecord]*priorityItem
+
+	agingInterval time.Duration
+	epoch         time.Time
+	sequence      uint64
+}
+
+type priorityItem struct {
+	record   *taskRecord
+	score    float64
+	sequence uint64
+	index    int
+}
+
+func newPriorityPendingTasks(agingInterval time.Duration) *priorityPendingTasks {
+	return &priorityPendingTasks{
+		items:         make(priorityHeap, 0),
+		itemsMap:      make(map[*taskRecord]*priorityItem),
+		agingInterval: agingInterval,
+		epoch:         time.Now(),
+	}
+}
+
+func (p *priorityPendingTasks) push(record *taskRecord) {
+	score := float64(record.task.Priority)
+	if p.agingInterval > 0 {
+		// the later the task arrives, the less it has aged compared to the ones already waiting
+		score -= float64(time.Since(p.epoch)) / float64(p.agingInterval)
+	}
+
+	item := &priorityItem{
+		record:   record,
+		score:    score,
+		sequence: p.sequence,
+	}
+	p.sequence++
+
+	heap.Push(&p.items, item)
+	p.itemsMap[record] = item
+}
+
+func (p *priorityPendingTasks) pop() *taskRecord {
+	if len(p.items) == 0 {
+		return nil
+	}
+
+	item := heap.Pop(&p.items).(*priorityItem)
+	delete(p.itemsMap, item.record)
+	return item.record
+}
+
+func (p *priorityPendingTasks) remove(record *taskRecord) bool {
+	item, found := p.itemsMap[record]
+	if !found {
+		return false
+	}
+
+	heap.Remove(&p.items, item.index)
+	delete(p.itemsMap, record)
+	return true
+}
+
+func (p *priorityPendingTasks) clear() []*taskRecord {
+	cleared := make([]*taskRecord, 0, len(p.items))
+	for p.len() > 0 {
+		cleared = append(cleared, p.pop())
+	}
+	return cleared
+}
+
+func (p *priorityPendingTasks) len() int {
+	return len(p.items)
+}
+
+// priorityHeap implements `heap.Interface`, the item with the highest score is on top
+type priorityHeap []*priorityItem
+
+func (h priorityHeap) Len() int { return len(h) }
+
+func (h priorityHeap) Less(i, j int) bool {
+	if h[i].score != h[j].score {
+		return h[i].score > h[j].score
+	}
+	return h[i].sequence < h[j].sequence
+}
+
+func (h priorityHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *priorityHeap) Push(x interface{}) {
+	item := x.(*priorityItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *priorityHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
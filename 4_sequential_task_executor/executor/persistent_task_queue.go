@@ -0,0 +1,64 @@
+package executor
+
+import (
+	"AwesomePresentation/logging"
+	"context"
+)
+
+// persistentTaskQueue is the locking queue, which additionally writes every change to a log file (see
+// `writeAheadLog`). When the queue is opened again, tasks which haven't finished are restored - queued
+// and scheduled ones keep waiting, the ones which were running are queued again.
+//
+// Tasks cancelled by `Cancel` are forgotten, tasks cancelled because the queue is shut down or stopped are not.
+type persistentTaskQueue struct {
+	*lockingTaskQueue
+
+	wal *writeAheadLog
+}
+
+// NewPersistentTaskQueue opens queue stored in the file at `path`, creating the file if it doesn't exist.
+// Executables of pushed tasks have to be registered in the registry, so they can be stored and restored.
+// Besides the usual options, the queue is configured by `WithSyncPolicy`, `WithSyncInterval`
+// and `WithCompactionInterval`.
+func NewPersistentTaskQueue(path string, registry *ExecutableRegistry, options ...Option) (TaskQueue, error) {
+	cfg := newConfig(options)
+
+	wal, err := openWriteAheadLog(path, registry, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := newLockingTaskQueue(cfg)
+	if err := wal.restoreInto(queue.tasks); err != nil {
+		_ = wal.close()
+		return nil, err
+	}
+	// restored tasks are already in the log, only the following changes are written
+	queue.tasks.journal = wal
+
+	persistentQueue := &persistentTaskQueue{
+		lockingTaskQueue: queue,
+		wal:              wal,
+	}
+	queue.tasks.unregisterMetrics = registerQueueMetrics(cfg.metrics, persistentQueue)
+
+	return persistentQueue, nil
+}
+
+// Shutdown waits for the queue to drain like `lockingTaskQueue.Shutdown`, then closes the log
+func (q *persistentTaskQueue) Shutdown(ctx context.Context) error {
+	if err := q.lockingTaskQueue.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	return q.wal.close()
+}
+
+// Stop gives up on all tasks right away and closes the log. Unfinished tasks are restored by the next queue.
+func (q *persistentTaskQueue) Stop() {
+	q.lockingTaskQueue.Stop()
+
+	if err := q.wal.close(); err != nil {
+		q.logger.Error("failed to close task log", logging.Err(err))
+	}
+}
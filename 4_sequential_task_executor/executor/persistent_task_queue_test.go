@@ -0,0 +1,181 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"gotest.tools/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Executable which can be stored by the persistent queue
+type storedExecutable struct {
+	Name string
+}
+
+func (e *storedExecutable) Execute() error {
+	return nil
+}
+
+func newTestRegistry(t *testing.T) *ExecutableRegistry {
+	registry := NewExecutableRegistry()
+	assert.NilError(t, registry.Register("stored", func() Executable { return &storedExecutable{} }))
+	return registry
+}
+
+func openPersistentQueue(t *testing.T, path string, options ...Option) TaskQueue {
+	t.Helper()
+
+	queue, err := NewPersistentTaskQueue(path, newTestRegistry(t), options...)
+	assert.NilError(t, err)
+	return queue
+}
+
+func TestPersistentTaskQueueRestoresUnfinishedTasks(t *testing.T) {
+	syncPolicies := []SyncPolicy{SyncEveryWrite, SyncPeriodically, SyncNever}
+
+	for _, syncPolicy := range syncPolicies {
+		t.Run(syncPolicy.String(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tasks.log")
+			queue := openPersistentQueue(t, path, WithSyncPolicy(syncPolicy))
+
+			succeededId := mustPush(t, queue, Task{TaskExecutable: &storedExecutable{Name: "succeeded"}})
+			runningId := mustPush(t, queue, Task{TaskExecutable: &storedExecutable{Name: "running"}})
+			cancelledId := mustPush(t, queue, Task{TaskExecutable: &storedExecutable{Name: "cancelled"}})
+			queuedId := mustPush(t, queue, Task{TaskExecutable: &storedExecutable{Name: "queued"}, Priority: 3})
+			scheduledId, err := queue.PushAfter(Task{TaskExecutable: &storedExecutable{Name: "scheduled"}}, time.Hour)
+			assert.NilError(t, err)
+
+			assert.Equal(t, succeededId, queue.Pop().Id)
+			queue.Complete(succeededId, nil)
+			assert.Equal(t, runningId, queue.Pop().Id)
+			assert.Check(t, queue.Cancel(cancelledId))
+
+			// the process goes away while the task is running
+			queue.Stop()
+
+			restored := openPersistentQueue(t, path)
+			defer restored.Stop()
+
+			tasks := restored.List()
+			assert.Equal(t, 3, len(tasks))
+
+			assert.Equal(t, runningId, tasks[0].Id)
+			assert.Equal(t, TaskQueued, tasks[0].State)
+			assert.Equal(t, 1, tasks[0].Attempts)
+
+			assert.Equal(t, queuedId, tasks[1].Id)
+			assert.Equal(t, TaskQueued, tasks[1].State)
+			assert.Equal(t, 3, tasks[1].Priority)
+
+			assert.Equal(t, scheduledId, tasks[2].Id)
+			assert.Equal(t, TaskScheduled, tasks[2].State)
+
+			popped := restored.Pop()
+			assert.Equal(t, runningId, popped.Id)
+			assert.Equal(t, "running", popped.TaskExecutable.(*storedExecutable).Name)
+		})
+	}
+}
+
+func TestPersistentTaskQueueRestoresRetries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.log")
+	queue := openPersistentQueue(t, path)
+
+	retry := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}
+	id := mustPush(t, queue, Task{TaskExecutable: &storedExecutable{}, Retry: retry})
+	queue.Pop()
+	queue.Complete(id, errFlaky)
+	assert.NilError(t, queue.Shutdown(context.Background()))
+
+	restored := openPersistentQueue(t, path)
+	defer restored.Stop()
+
+	info := restored.Get(id)
+	assert.Equal(t, TaskScheduled, info.State)
+	assert.Equal(t, 1, info.Attempts)
+	assert.Check(t, time.Until(info.DueAt) > 50*time.Minute)
+}
+
+func TestPersistentTaskQueueWithExecutor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.log")
+	queue := openPersistentQueue(t, path)
+	executor := NewExecutor(queue)
+
+	handle, err := queue.Submit(Task{TaskExecutable: &storedExecutable{}})
+	assert.NilError(t, err)
+	assert.NilError(t, handle.Wait(context.Background()))
+	assert.NilError(t, executor.Shutdown(context.Background()))
+
+	restored := openPersistentQueue(t, path)
+	defer restored.Stop()
+	assert.Equal(t, 0, len(restored.List()))
+}
+
+func TestPersistentTaskQueueRejectsUnregisteredExecutable(t *testing.T) {
+	queue := openPersistentQueue(t, filepath.Join(t.TempDir(), "tasks.log"))
+	defer queue.Stop()
+
+	_, err := queue.Push(NewExecutableQuickie())
+	assert.Check(t, errors.Is(err, ErrUnknownExecutable))
+	assert.Equal(t, 0, len(queue.List()))
+}
+
+func TestPersistentTaskQueueCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.log")
+	queue := openPersistentQueue(t, path, WithCompactionInterval(20*time.Millisecond))
+	defer queue.Stop()
+
+	for i := 0; i < 10; i++ {
+		id := mustPush(t, queue, Task{TaskExecutable: &storedExecutable{}})
+		queue.Pop()
+		queue.Complete(id, nil)
+	}
+	keptId := mustPush(t, queue, Task{TaskExecutable: &storedExecutable{Name: "kept"}})
+
+	// only the enqueue record of the unfinished task is left
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && countLines(t, path) != 1 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 1, countLines(t, path))
+
+	content, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Check(t, strings.Contains(string(content), keptId))
+}
+
+func TestPersistentTaskQueueIgnoresTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.log")
+	queue := openPersistentQueue(t, path)
+	id := mustPush(t, queue, Task{TaskExecutable: &storedExecutable{}})
+	queue.Stop()
+
+	// process died in the middle of writing a record
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NilError(t, err)
+	_, err = file.WriteString(`{"op":"complete","id":"` + id)
+	assert.NilError(t, err)
+	assert.NilError(t, file.Close())
+
+	restored := openPersistentQueue(t, path)
+	defer restored.Stop()
+	assert.Equal(t, TaskQueued, restored.Get(id).State)
+}
+
+func TestPersistentTaskQueueFailsOnCorruptedLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.log")
+	assert.NilError(t, os.WriteFile(path, []byte("garbage\n{}\n"), 0644))
+
+	_, err := NewPersistentTaskQueue(path, newTestRegistry(t))
+	assert.ErrorContains(t, err, "corrupted task log")
+}
+
+func countLines(t *testing.T, path string) int {
+	content, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	return strings.Count(string(content), "\n")
+}
@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"context"
+	"gotest.tools/assert"
+	"testing"
+	"time"
+)
+
+// Executable reporting the moment it was started
+type startReportingExecutable struct {
+	started chan time.Time
+}
+
+func (e *startReportingExecutable) Execute() error {
+	e.started <- time.Now()
+	return nil
+}
+
+// Measures how long it takes from pushing a task to the moment the executor starts executing it
+func BenchmarkEnqueueToStartLatency(b *testing.B) {
+	for _, constructor := range executorConstructors {
+		b.Run(constructor.name, func(b *testing.B) {
+			queue, executor := constructor.newExecutor(WithFinishedTaskRetention(0))
+			defer executor.Stop()
+
+			executable := &startReportingExecutable{started: make(chan time.Time)}
+
+			var totalLatency time.Duration
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pushedAt := time.Now()
+				if _, err := queue.Push(Task{TaskExecutable: executable}); err != nil {
+					b.Fatal(err)
+				}
+
+				startedAt := <-executable.started
+				totalLatency += startedAt.Sub(pushedAt)
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(totalLatency.Nanoseconds())/float64(b.N), "ns/start")
+		})
+	}
+}
+
+func TestPopContext(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			// empty queue blocks until the context is done
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			task, err := queue.PopContext(ctx)
+			assert.Check(t, task == nil)
+			assert.Equal(t, context.DeadlineExceeded, err)
+
+			// task pushed while waiting is returned
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				_, _ = queue.Push(NewExecutableQuickie())
+			}()
+			task, err = queue.PopContext(context.Background())
+			assert.NilError(t, err)
+			assert.Check(t, task != nil)
+
+			// non blocking pop of an empty queue returns nil
+			assert.Check(t, queue.Pop() == nil)
+
+			// closing the queue releases waiting pops
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				queue.Stop()
+			}()
+			task, err = queue.PopContext(context.Background())
+			assert.Check(t, task == nil)
+			assert.Equal(t, ErrQueueClosed, err)
+		})
+	}
+}
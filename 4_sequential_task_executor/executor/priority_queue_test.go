@@ -0,0 +1,100 @@
+package executor
+
+import (
+	"gotest.tools/assert"
+	"testing"
+	"time"
+)
+
+var priorityQueueConstructors = []struct {
+	name     string
+	newQueue func(options ...Option) TaskQueue
+}{
+	{name: "priority channel queue", newQueue: NewPriorityTaskQueue},
+	{name: "priority locking queue", newQueue: NewLockingPriorityTaskQueue},
+}
+
+func TestPriorityQueueOrdering(t *testing.T) {
+	for _, constructor := range priorityQueueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			priorities := []int{0, 5, -1, 5, 0, 10, 5}
+			ids := make([]string, 0, len(priorities))
+			for _, priority := range priorities {
+				task := NewExecutableQuickie()
+				task.Priority = priority
+				ids = append(ids, mustPush(t, queue, task))
+			}
+
+			// highest priority first, equal priorities in the order of pushing
+			expectedOrder := []string{ids[5], ids[1], ids[3], ids[6], ids[0], ids[4], ids[2]}
+			for _, expectedId := range expectedOrder {
+				popped := queue.Pop()
+				assert.Assert(t, popped != nil)
+				assert.Equal(t, expectedId, popped.Id)
+				assert.Equal(t, queue.Get(expectedId).Priority, popped.Priority)
+			}
+			assert.Check(t, queue.Pop() == nil)
+		})
+	}
+}
+
+func TestPriorityQueueCancel(t *testing.T) {
+	for _, constructor := range priorityQueueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			low := NewExecutableQuickie()
+			high := NewExecutableQuickie()
+			high.Priority = 1
+
+			lowId := mustPush(t, queue, low)
+			highId := mustPush(t, queue, high)
+
+			assert.Check(t, queue.Cancel(highId))
+
+			popped := queue.Pop()
+			assert.Assert(t, popped != nil)
+			assert.Equal(t, lowId, popped.Id)
+			assert.Check(t, queue.Pop() == nil)
+		})
+	}
+}
+
+func TestPriorityQueueAging(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       []Option
+		expectedFirst string
+	}{
+		{name: "without aging important task goes first", options: nil, expectedFirst: "high"},
+		{name: "with aging long waiting task goes first", options: []Option{WithPriorityAging(5 * time.Millisecond)}, expectedFirst: "low"},
+	}
+
+	for _, constructor := range priorityQueueConstructors {
+		for _, test := range tests {
+			t.Run(constructor.name+": "+test.name, func(t *testing.T) {
+				queue := constructor.newQueue(test.options...)
+				defer queue.Stop()
+
+				low := NewExecutableQuickie()
+				lowId := mustPush(t, queue, low)
+
+				// low priority task waits long enough to gain way more than two priority levels
+				time.Sleep(100 * time.Millisecond)
+
+				high := NewExecutableQuickie()
+				high.Priority = 2
+				highId := mustPush(t, queue, high)
+
+				expectedIds := map[string]string{"low": lowId, "high": highId}
+				popped := queue.Pop()
+				assert.Assert(t, popped != nil)
+				assert.Equal(t, expectedIds[test.expectedFirst], popped.Id)
+			})
+		}
+	}
+}
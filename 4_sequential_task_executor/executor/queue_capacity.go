@@ -0,0 +1,44 @@
+package executor
+
+// OverflowPolicy decides what happens to a task pushed to a queue which is at its capacity, see `WithCapacity`
+type OverflowPolicy int
+
+const (
+	// Push waits until there is space in the queue, or the queue closes
+	OverflowBlock OverflowPolicy = iota
+	// Push fails with `ErrQueueFull`
+	OverflowReject
+	// The oldest waiting task is dropped to make space for the pushed one
+	OverflowDropOldest
+	// The pushed task is dropped right away. Push succeeds, the task ends up in `TaskDropped` state
+	OverflowDropNewest
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "Block"
+	case OverflowReject:
+		return "Reject"
+	case OverflowDropOldest:
+		return "DropOldest"
+	case OverflowDropNewest:
+		return "DropNewest"
+	default:
+		return "Unknown"
+	}
+}
+
+// QueueStats is a snapshot of queue counters, returned by `TaskQueue.Stats`
+type QueueStats struct {
+	// Tasks waiting to be popped - queued, scheduled and blocked ones
+	Waiting int
+	Running int
+
+	// Zero means the queue is not bounded
+	Capacity int
+	// Pushes (and requeues) which failed with `ErrQueueFull`, since the queue was created
+	Rejected uint64
+	// Tasks dropped because the queue was full, since the queue was created
+	Dropped uint64
+}
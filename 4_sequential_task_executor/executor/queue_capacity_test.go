@@ -0,0 +1,175 @@
+package executor
+
+import (
+	"errors"
+	"gotest.tools/assert"
+	"testing"
+	"time"
+)
+
+func TestQueueOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy OverflowPolicy
+		// states of the three pushed tasks, the queue fits two of them
+		expectedStates   []TaskState
+		expectedErr      error
+		expectedRejected uint64
+		expectedDropped  uint64
+	}{
+		{
+			name:             "reject",
+			policy:           OverflowReject,
+			expectedStates:   []TaskState{TaskQueued, TaskQueued},
+			expectedErr:      ErrQueueFull,
+			expectedRejected: 1,
+		},
+		{
+			name:            "drop oldest",
+			policy:          OverflowDropOldest,
+			expectedStates:  []TaskState{TaskDropped, TaskQueued, TaskQueued},
+			expectedDropped: 1,
+		},
+		{
+			name:            "drop newest",
+			policy:          OverflowDropNewest,
+			expectedStates:  []TaskState{TaskQueued, TaskQueued, TaskDropped},
+			expectedDropped: 1,
+		},
+	}
+
+	for _, test := range tests {
+		for _, constructor := range queueConstructors {
+			t.Run(test.name+" "+constructor.name, func(t *testing.T) {
+				queue := constructor.newQueue(WithCapacity(2, test.policy))
+				defer queue.Stop()
+
+				ids := []string{mustPush(t, queue, NewExecutableQuickie())}
+				// scheduled tasks take space as well
+				scheduledId, err := queue.PushAfter(NewExecutableQuickie(), time.Millisecond)
+				assert.NilError(t, err)
+				ids = append(ids, scheduledId)
+				time.Sleep(5 * time.Millisecond)
+
+				id, err := queue.Push(NewExecutableQuickie())
+				if test.expectedErr != nil {
+					assert.Check(t, errors.Is(err, test.expectedErr))
+				} else {
+					assert.NilError(t, err)
+					ids = append(ids, id)
+				}
+
+				assert.Equal(t, len(test.expectedStates), len(ids))
+				for index, id := range ids {
+					info := queue.Get(id)
+					assert.Equal(t, test.expectedStates[index], info.State)
+					if info.State == TaskDropped {
+						assert.Check(t, errors.Is(info.Error, ErrTaskDropped))
+					}
+				}
+
+				stats := queue.Stats()
+				assert.Equal(t, 2, stats.Waiting)
+				assert.Equal(t, 2, stats.Capacity)
+				assert.Equal(t, test.expectedRejected, stats.Rejected)
+				assert.Equal(t, test.expectedDropped, stats.Dropped)
+
+				// popped task makes space for another one
+				queue.Pop()
+				mustPush(t, queue, NewExecutableQuickie())
+			})
+		}
+	}
+}
+
+func TestQueueOverflowBlock(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue(WithCapacity(1, OverflowBlock))
+			defer queue.Stop()
+
+			firstId := mustPush(t, queue, NewExecutableQuickie())
+
+			pushed := make(chan error)
+			go func() {
+				_, err := queue.Push(NewExecutableQuickie())
+				pushed <- err
+			}()
+
+			select {
+			case <-pushed:
+				t.Fatal("push to full queue did not block")
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			assert.Check(t, queue.Cancel(firstId))
+			select {
+			case err := <-pushed:
+				assert.NilError(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("push did not continue once there was space")
+			}
+
+			// closing the queue releases blocked pushes
+			go func() {
+				_, err := queue.Push(NewExecutableQuickie())
+				pushed <- err
+			}()
+			time.Sleep(10 * time.Millisecond)
+			queue.Stop()
+
+			select {
+			case err := <-pushed:
+				assert.Check(t, errors.Is(err, ErrQueueClosed))
+			case <-time.After(5 * time.Second):
+				t.Fatal("push was not released when the queue stopped")
+			}
+			assert.Equal(t, uint64(0), queue.Stats().Rejected)
+		})
+	}
+}
+
+func TestRequeueToFullQueue(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue(WithCapacity(1, OverflowDropOldest))
+			defer queue.Stop()
+
+			failedId := mustPush(t, queue, NewExecutableQuickie())
+			queue.Pop()
+			queue.Complete(failedId, errors.New("failed on purpose"))
+			mustPush(t, queue, NewExecutableQuickie())
+
+			assert.Check(t, errors.Is(queue.Requeue(failedId), ErrQueueFull))
+			assert.Equal(t, uint64(1), queue.Stats().Rejected)
+		})
+	}
+}
+
+func TestRetriesTakeCapacity(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue(WithCapacity(1, OverflowDropOldest))
+			defer queue.Stop()
+
+			retriedId := mustPush(t, queue, Task{
+				TaskExecutable: NewExecutableQuickie().TaskExecutable,
+				Retry:          &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Hour},
+			})
+			queue.Pop()
+			queuedId := mustPush(t, queue, NewExecutableQuickie())
+
+			// retry is never rejected, even by a full queue, but it takes space
+			queue.Complete(retriedId, errors.New("failed on purpose"))
+			assert.Equal(t, TaskScheduled, queue.Get(retriedId).State)
+			assert.Equal(t, 2, queue.Stats().Waiting)
+
+			// waiting retry is the oldest waiting task
+			pushedId := mustPush(t, queue, NewExecutableQuickie())
+			assert.Equal(t, TaskDropped, queue.Get(retriedId).State)
+			assert.Equal(t, TaskQueued, queue.Get(queuedId).State)
+			assert.Equal(t, TaskQueued, queue.Get(pushedId).State)
+			assert.Equal(t, uint64(1), queue.Stats().Dropped)
+		})
+	}
+}
@@ -20,7 +20,7 @@ func TestThreadSafetyTaskPriorityQueue(t *testing.T) {
 			name: "priority queue push is thread safe",
 			testedFunction: func(queue TaskQueue, collection *[]int, value int, wg *sync.WaitGroup) {
 				// create task
-				task := NewTestSliceCollectingExecutable(value, collection, nil)
+				task := NewTestSliceCollectingExecutable(value, collection)
 
 				// submit it
 				queue.Push(task)
@@ -34,7 +34,7 @@ func TestThreadSafetyTaskPriorityQueue(t *testing.T) {
 			name: "priority queue push and pop is thread safe",
 			testedFunction: func(queue TaskQueue, collection *[]int, value int, wg *sync.WaitGroup) {
 				// create task
-				task := NewTestSliceCollectingExecutable(value, collection, nil)
+				task := NewTestSliceCollectingExecutable(value, collection)
 
 				// submit it
 				queue.Push(task)
@@ -52,7 +52,7 @@ func TestThreadSafetyTaskPriorityQueue(t *testing.T) {
 		{
 			name: "priority queue push, pop and contains is thread safe",
 			testedFunction: func(queue TaskQueue, collection *[]int, value int, wg *sync.WaitGroup) {
-				task := NewTestSliceCollectingExecutable(value, collection, nil)
+				task := NewTestSliceCollectingExecutable(value, collection)
 
 				queue.Push(task)
 
@@ -70,9 +70,9 @@ func TestThreadSafetyTaskPriorityQueue(t *testing.T) {
 		{
 			name: "priority queue push, pop, contains, get by id is thread safe",
 			testedFunction: func(queue TaskQueue, collection *[]int, value int, wg *sync.WaitGroup) {
-				task := NewTestSliceCollectingExecutable(value, collection, nil)
+				task := NewTestSliceCollectingExecutable(value, collection)
 
-				someId := queue.Push(task)
+				someId, _ := queue.Push(task)
 
 				queue.Get(someId)
 
@@ -90,34 +90,37 @@ func TestThreadSafetyTaskPriorityQueue(t *testing.T) {
 		},
 	}
 
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			fmt.Println(fmt.Sprintf("Start: %v", test.name))
+	for _, constructor := range queueConstructors {
+		for _, test := range tests {
+			t.Run(constructor.name+": "+test.name, func(t *testing.T) {
+				fmt.Println(fmt.Sprintf("Start: %v", test.name))
 
-			queue := NewTaskQueue()
-			//queue, _ := NewLockingQueueExecutor()
+				queue := constructor.newQueue()
+				//queue, _ := NewLockingQueueExecutor()
+				defer queue.Stop()
 
-			setOfOperations := make([]ExecutableTestOperation, 0, test.iterations)
-			wg := sync.WaitGroup{}
-			wg.Add(test.iterations)
+				setOfOperations := make([]ExecutableTestOperation, 0, test.iterations)
+				wg := sync.WaitGroup{}
+				wg.Add(test.iterations)
 
-			for i := 0; i < test.iterations; i++ {
-				copyOfFunction := test.testedFunction
-				copyOfIndex := i
+				for i := 0; i < test.iterations; i++ {
+					copyOfFunction := test.testedFunction
+					copyOfIndex := i
 
-				operation := func() error {
-					copyOfFunction(queue, &test.collection, copyOfIndex, &wg)
-					return nil
-				}
+					operation := func() error {
+						copyOfFunction(queue, &test.collection, copyOfIndex, &wg)
+						return nil
+					}
 
-				setOfOperations = append(setOfOperations, operation)
-			}
+					setOfOperations = append(setOfOperations, operation)
+				}
 
-			fmt.Println(fmt.Sprintf("Testing number of operations: %v", len(setOfOperations)))
-			_ = ParallelOperationsExecutor(t, test.processes, setOfOperations)
+				fmt.Println(fmt.Sprintf("Testing number of operations: %v", len(setOfOperations)))
+				_ = ParallelOperationsExecutor(t, test.processes, setOfOperations)
 
-			result := WaitGroupWithTimeout(&wg, 5*time.Second)
-			assert.Check(t, result)
-		})
+				result := WaitGroupWithTimeout(&wg, 5*time.Second)
+				assert.Check(t, result)
+			})
+		}
 	}
 }
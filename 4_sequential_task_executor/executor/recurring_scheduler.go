@@ -0,0 +1,317 @@
+package executor
+
+import (
+	"AwesomePresentation/logging"
+	"fmt"
+	"github.com/google/uuid"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// OverlapPolicy decides what happens when a recurring task is due while its previous run hasn't finished.
+// Executor runs tasks one by one, so a slow task easily overlaps with its next run.
+type OverlapPolicy int
+
+const (
+	// Don't push the task if the previous run is still queued or running
+	OverlapSkip OverlapPolicy = iota
+	// Always push the task, runs pile up in the queue
+	OverlapQueue
+	// Push the task unless the previous run is still waiting in the queue - at most one run waits at a time
+	OverlapCoalesce
+)
+
+func (p OverlapPolicy) String() string {
+	switch p {
+	case OverlapSkip:
+		return "Skip"
+	case OverlapQueue:
+		return "Queue"
+	case OverlapCoalesce:
+		return "Coalesce"
+	default:
+		return "Unknown"
+	}
+}
+
+type RecurringTaskInfo struct {
+	Id       string
+	Schedule string
+	Policy   OverlapPolicy
+
+	NextRunAt time.Time
+	LastRunAt time.Time
+	// Id of the task pushed most recently, can be used with `TaskQueue.Get`
+	LastTaskId string
+
+	// Number of pushed runs and runs which were not pushed because of the overlap policy
+	Runs    int
+	Skipped int
+
+	// Error returned by the queue on the last push attempt
+	LastError error
+}
+
+type recurringTask struct {
+	task     Task
+	schedule Schedule
+	info     RecurringTaskInfo
+}
+
+// RecurringScheduler pushes registered tasks to the queue according to their schedules.
+// It's a periodic goroutine (like `periodicNumberSender` in `2_channels_with_periodic_thread`), which sleeps
+// until the nearest run is due.
+type RecurringScheduler struct {
+	queue TaskQueue
+
+	lock  sync.Mutex
+	tasks map[string]*recurringTask
+	order []string
+
+	// Wakes the scheduler goroutine up, so it notices added or removed schedules
+	wakeUp chan struct{}
+	quit   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+
+	logger logging.Logger
+}
+
+// NewRecurringScheduler starts pushing registered tasks to the queue. Only `WithLogger` option is relevant.
+func NewRecurringScheduler(queue TaskQueue, options ...Option) *RecurringScheduler {
+	scheduler := &RecurringScheduler{
+		queue:  queue,
+		tasks:  make(map[string]*recurringTask),
+		order:  make([]string, 0),
+		wakeUp: make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+		logger: newConfig(options).logger,
+	}
+
+	go scheduler.run()
+	return scheduler
+}
+
+// Register makes the task run according to the schedule, e.g. `Every(time.Minute)` or the result
+// of `ParseCronSchedule("0 2 * * *")`. Returns id of the recurring task, which can be used to unregister it.
+// Schedule which never fires, or whose next run isn't in the future (e.g. `Every(0)`), is rejected.
+func (s *RecurringScheduler) Register(task Task, schedule Schedule, policy OverlapPolicy) (string, error) {
+	generatedId, err := uuid.NewRandom()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate uuid for recurring task: %w", err)
+	}
+
+	now := time.Now()
+	nextRunAt := schedule.Next(now)
+	if nextRunAt.IsZero() {
+		return "", fmt.Errorf("schedule %v never fires", schedule)
+	}
+	if !nextRunAt.After(now) {
+		return "", fmt.Errorf("schedule %v doesn't move forward in time", schedule)
+	}
+
+	id := generatedId.String()
+	recurring := &recurringTask{
+		task:     task,
+		schedule: schedule,
+		info: RecurringTaskInfo{
+			Id:        id,
+			Schedule:  fmt.Sprint(schedule),
+			Policy:    policy,
+			NextRunAt: nextRunAt,
+		},
+	}
+
+	s.lock.Lock()
+	s.tasks[id] = recurring
+	s.order = append(s.order, id)
+	s.lock.Unlock()
+
+	s.notify()
+	return id, nil
+}
+
+// Unregister stops future runs of the recurring task. Runs already pushed to the queue are not affected.
+func (s *RecurringScheduler) Unregister(id string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, found := s.tasks[id]; !found {
+		return false
+	}
+
+	s.remove(id)
+	return true
+}
+
+// Must hold the lock
+func (s *RecurringScheduler) remove(id string) {
+	delete(s.tasks, id)
+	for index, orderedId := range s.order {
+		if orderedId == id {
+			s.order = append(s.order[:index], s.order[index+1:]...)
+			break
+		}
+	}
+}
+
+func (s *RecurringScheduler) List() []RecurringTaskInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	infos := make([]RecurringTaskInfo, 0, len(s.order))
+	for _, id := range s.order {
+		infos = append(infos, s.tasks[id].info)
+	}
+
+	return infos
+}
+
+func (s *RecurringScheduler) Get(id string) *RecurringTaskInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	recurring, found := s.tasks[id]
+	if !found {
+		return nil
+	}
+
+	infoCopy := recurring.info
+	return &infoCopy
+}
+
+// Stop makes the scheduler stop pushing tasks and waits for its goroutine to exit. The queue is left intact.
+func (s *RecurringScheduler) Stop() {
+	s.once.Do(func() { close(s.quit) })
+	<-s.done
+}
+
+func (s *RecurringScheduler) notify() {
+	select {
+	case s.wakeUp <- struct{}{}:
+	default:
+		// goroutine is going to wake up anyway
+	}
+}
+
+func (s *RecurringScheduler) run() {
+	defer close(s.done)
+	defer (func() {
+		if panic := recover(); panic != nil {
+			s.logger.Error("recurring scheduler goroutine panicked", logging.Any("panic", panic),
+				logging.Any("stack", string(debug.Stack())))
+		}
+	})()
+
+	for {
+		var dueChannel <-chan time.Time
+		var dueTimer *time.Timer
+		if nextRunAt, found := s.nextRunAt(); found {
+			dueTimer = time.NewTimer(time.Until(nextRunAt))
+			dueChannel = dueTimer.C
+		}
+
+		select {
+		case <-dueChannel:
+			s.runDue(time.Now())
+		case <-s.wakeUp:
+			// schedules changed, timer has to be recalculated
+		case <-s.quit:
+			if dueTimer != nil {
+				dueTimer.Stop()
+			}
+			return
+		}
+
+		if dueTimer != nil {
+			dueTimer.Stop()
+		}
+	}
+}
+
+func (s *RecurringScheduler) nextRunAt() (time.Time, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var earliest time.Time
+	for _, recurring := range s.tasks {
+		if earliest.IsZero() || recurring.info.NextRunAt.Before(earliest) {
+			earliest = recurring.info.NextRunAt
+		}
+	}
+
+	return earliest, !earliest.IsZero()
+}
+
+// Pushes all recurring tasks which are due. Runs missed in the meantime (e.g. the process was suspended)
+// are not caught up, the next run is calculated from now.
+func (s *RecurringScheduler) runDue(now time.Time) {
+	s.lock.Lock()
+	due := make([]*recurringTask, 0)
+	for _, id := range s.order {
+		if recurring := s.tasks[id]; !recurring.info.NextRunAt.After(now) {
+			due = append(due, recurring)
+		}
+	}
+	s.lock.Unlock()
+
+	// queue is called without holding the lock, it might block for a moment
+	for _, recurring := range due {
+		s.runRecurring(recurring, now)
+	}
+}
+
+func (s *RecurringScheduler) runRecurring(recurring *recurringTask, now time.Time) {
+	s.lock.Lock()
+	if s.tasks[recurring.info.Id] != recurring {
+		// unregistered in the meantime
+		s.lock.Unlock()
+		return
+	}
+	policy, lastTaskId := recurring.info.Policy, recurring.info.LastTaskId
+	s.lock.Unlock()
+
+	push := true
+	if lastTaskId != "" && policy != OverlapQueue {
+		if lastRun := s.queue.Get(lastTaskId); lastRun != nil {
+			switch policy {
+			case OverlapSkip:
+				push = lastRun.State.IsFinished()
+			case OverlapCoalesce:
+				push = lastRun.State != TaskQueued && lastRun.State != TaskScheduled
+			}
+		}
+	}
+
+	var taskId string
+	var err error
+	if push {
+		taskId, err = s.queue.Push(recurring.task)
+		if err != nil {
+			s.logger.Error("failed to push recurring task", logging.Any("recurringTaskId", recurring.info.Id), logging.Err(err))
+		}
+	} else {
+		s.logger.Info("recurring task overlaps with its previous run, not pushed", logging.Any("recurringTaskId", recurring.info.Id))
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	recurring.info.NextRunAt = recurring.schedule.Next(now)
+	recurring.info.LastRunAt = now
+	recurring.info.LastError = err
+	switch {
+	case !push:
+		recurring.info.Skipped++
+	case err == nil:
+		recurring.info.Runs++
+		recurring.info.LastTaskId = taskId
+	}
+
+	if recurring.info.NextRunAt.IsZero() {
+		// schedule is exhausted
+		s.remove(recurring.info.Id)
+	}
+}
@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"gotest.tools/assert"
+	"testing"
+	"time"
+)
+
+// Executable which runs until the test lets it finish
+type blockingExecutable struct {
+	release chan struct{}
+}
+
+func (e *blockingExecutable) Execute() error {
+	<-e.release
+	return nil
+}
+
+func TestRecurringSchedulerOverlapPolicies(t *testing.T) {
+	tests := []struct {
+		policy OverlapPolicy
+		// tasks pushed while the first run blocks the executor
+		minimumRuns, maximumRuns int
+		expectSkipped            bool
+	}{
+		{policy: OverlapSkip, minimumRuns: 1, maximumRuns: 1, expectSkipped: true},
+		{policy: OverlapCoalesce, minimumRuns: 2, maximumRuns: 2, expectSkipped: true},
+		{policy: OverlapQueue, minimumRuns: 3, maximumRuns: 1000, expectSkipped: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			queue, executor := NewLockingQueueExecutor()
+			defer executor.Stop()
+
+			scheduler := NewRecurringScheduler(queue)
+			defer scheduler.Stop()
+
+			executable := &blockingExecutable{release: make(chan struct{})}
+			id, err := scheduler.Register(Task{TaskExecutable: executable}, Every(10*time.Millisecond), test.policy)
+			assert.NilError(t, err)
+
+			time.Sleep(150 * time.Millisecond)
+			assert.Check(t, scheduler.Unregister(id))
+			assert.Check(t, !scheduler.Unregister(id))
+			assert.Check(t, scheduler.Get(id) == nil)
+
+			pushed := len(queue.List())
+			assert.Check(t, pushed >= test.minimumRuns, "pushed %v tasks", pushed)
+			assert.Check(t, pushed <= test.maximumRuns, "pushed %v tasks", pushed)
+
+			close(executable.release)
+		})
+	}
+}
+
+func TestRecurringSchedulerList(t *testing.T) {
+	queue := NewLockingTaskQueue()
+	defer queue.Stop()
+
+	scheduler := NewRecurringScheduler(queue)
+	defer scheduler.Stop()
+
+	cron, err := ParseCronSchedule("0 2 * * *")
+	assert.NilError(t, err)
+
+	nightlyId, err := scheduler.Register(NewExecutableQuickie(), cron, OverlapSkip)
+	assert.NilError(t, err)
+	frequentId, err := scheduler.Register(NewExecutableQuickie(), Every(10*time.Millisecond), OverlapQueue)
+	assert.NilError(t, err)
+
+	time.Sleep(55 * time.Millisecond)
+
+	recurringTasks := scheduler.List()
+	assert.Equal(t, 2, len(recurringTasks))
+
+	assert.Equal(t, nightlyId, recurringTasks[0].Id)
+	assert.Equal(t, "0 2 * * *", recurringTasks[0].Schedule)
+	assert.Equal(t, 0, recurringTasks[0].Runs)
+	assert.Equal(t, 2, recurringTasks[0].NextRunAt.Hour())
+
+	assert.Equal(t, frequentId, recurringTasks[1].Id)
+	assert.Check(t, recurringTasks[1].Runs >= 2)
+	assert.Check(t, queue.Get(recurringTasks[1].LastTaskId) != nil)
+}
+
+func TestRecurringSchedulerRejectsNonPositiveInterval(t *testing.T) {
+	queue := NewLockingTaskQueue()
+	defer queue.Stop()
+
+	scheduler := NewRecurringScheduler(queue)
+	defer scheduler.Stop()
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		_, err := scheduler.Register(NewExecutableQuickie(), Every(interval), OverlapQueue)
+		assert.ErrorContains(t, err, "doesn't move forward")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 0, len(scheduler.List()))
+	assert.Equal(t, 0, len(queue.List()))
+}
@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy describes how a failed task is retried. Failed attempt is pushed back to the queue (with the
+// same id) after a backoff, which grows exponentially with every attempt:
+//
+//	InitialBackoff * Multiplier^(attempt-1), at most MaxBackoff
+//
+// Tasks cancelled with `Cancel` are never retried, neither are tasks failing while the queue shuts down.
+type RetryPolicy struct {
+	// How many times the task is executed at most, including the first attempt. 1 or less means no retries
+	MaxAttempts int
+
+	InitialBackoff time.Duration
+	// Zero means the backoff is not limited
+	MaxBackoff time.Duration
+	// Zero means backoff doubles with every attempt
+	Multiplier float64
+	// Fraction of the backoff which is randomized, between 0 and 1. With 0.2 the backoff is between 80% and 100%
+	// of the computed value. Spreads retries of tasks which failed at the same moment
+	Jitter float64
+
+	// Decides whether the error is worth retrying, nil means every error is
+	// Functions can't be serialized, persistent queue restores the policy without it
+	Retryable func(err error) bool `json:"-"`
+}
+
+// JSON form of the policy, backoffs are duration strings like "500ms"
+type retryPolicyJSON struct {
+	MaxAttempts    int
+	InitialBackoff Duration
+	MaxBackoff     Duration
+	Multiplier     float64
+	Jitter         float64
+}
+
+func (p RetryPolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(retryPolicyJSON{
+		MaxAttempts:    p.MaxAttempts,
+		InitialBackoff: Duration(p.InitialBackoff),
+		MaxBackoff:     Duration(p.MaxBackoff),
+		Multiplier:     p.Multiplier,
+		Jitter:         p.Jitter,
+	})
+}
+
+func (p *RetryPolicy) UnmarshalJSON(data []byte) error {
+	// missing fields keep their current values
+	policy := retryPolicyJSON{
+		MaxAttempts:    p.MaxAttempts,
+		InitialBackoff: Duration(p.InitialBackoff),
+		MaxBackoff:     Duration(p.MaxBackoff),
+		Multiplier:     p.Multiplier,
+		Jitter:         p.Jitter,
+	}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return err
+	}
+
+	p.MaxAttempts = policy.MaxAttempts
+	p.InitialBackoff = time.Duration(policy.InitialBackoff)
+	p.MaxBackoff = time.Duration(policy.MaxBackoff)
+	p.Multiplier = policy.Multiplier
+	p.Jitter = policy.Jitter
+	return nil
+}
+
+// Returns true if the task which failed with the error on given attempt (counted from 1) should be retried
+func (p *RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// Returns how long to wait before the next attempt, after given attempt (counted from 1) has failed
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		backoff -= backoff * math.Min(p.Jitter, 1) * rand.Float64()
+	}
+
+	return time.Duration(backoff)
+}
@@ -0,0 +1,170 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"gotest.tools/assert"
+	"testing"
+	"time"
+)
+
+var errFlaky = errors.New("flaky failure")
+
+// Executable failing given number of times before it finally succeeds
+type flakyExecutable struct {
+	failures int
+	calls    int
+}
+
+func (e *flakyExecutable) Execute() error {
+	e.calls++
+	if e.calls <= e.failures {
+		return fmt.Errorf("attempt %v: %w", e.calls, errFlaky)
+	}
+	return nil
+}
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name             string
+		failures         int
+		policy           *RetryPolicy
+		expectedState    TaskState
+		expectedAttempts int
+	}{
+		{
+			name:             "succeeds after retries",
+			failures:         2,
+			policy:           &RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond},
+			expectedState:    TaskSucceeded,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "fails when attempts are exhausted",
+			failures:         5,
+			policy:           &RetryPolicy{MaxAttempts: 2, InitialBackoff: 10 * time.Millisecond},
+			expectedState:    TaskFailed,
+			expectedAttempts: 2,
+		},
+		{
+			name:     "fails right away when error is not retryable",
+			failures: 5,
+			policy: &RetryPolicy{MaxAttempts: 5, InitialBackoff: 10 * time.Millisecond, Retryable: func(err error) bool {
+				return !errors.Is(err, errFlaky)
+			}},
+			expectedState:    TaskFailed,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "fails right away without policy",
+			failures:         1,
+			policy:           nil,
+			expectedState:    TaskFailed,
+			expectedAttempts: 1,
+		},
+	}
+
+	for _, constructor := range executorConstructors {
+		for _, test := range tests {
+			t.Run(constructor.name+": "+test.name, func(t *testing.T) {
+				queue, executor := constructor.newExecutor()
+				defer executor.Stop()
+
+				executable := &flakyExecutable{failures: test.failures}
+				id := mustPush(t, queue, Task{TaskExecutable: executable, Retry: test.policy})
+
+				info := waitForFinishedTask(queue, id, 5*time.Second)
+				assert.Equal(t, test.expectedState, info.State)
+				assert.Equal(t, test.expectedAttempts, info.Attempts)
+				assert.Equal(t, test.expectedAttempts, executable.calls)
+				assert.Check(t, errors.Is(info.LastError, errFlaky))
+				if test.expectedState == TaskSucceeded {
+					assert.NilError(t, info.Error)
+				} else {
+					assert.Equal(t, info.LastError, info.Error)
+				}
+			})
+		}
+	}
+}
+
+func TestRetryIsScheduled(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			id := mustPush(t, queue, Task{
+				TaskExecutable: &flakyExecutable{failures: 1},
+				Retry:          &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Hour},
+			})
+
+			popped := queue.Pop()
+			assert.Assert(t, popped != nil)
+			queue.Complete(popped.Id, errFlaky)
+
+			info := queue.Get(id)
+			assert.Equal(t, TaskScheduled, info.State)
+			assert.Equal(t, 1, info.Attempts)
+			assert.Equal(t, errFlaky, info.LastError)
+			assert.Check(t, info.DueAt.After(time.Now().Add(59*time.Minute)))
+
+			// waiting retry can be cancelled like any other scheduled task
+			assert.Check(t, queue.Cancel(id))
+			assert.Equal(t, TaskCancelled, queue.Get(id).State)
+		})
+	}
+}
+
+func TestCancelledTaskIsNotRetried(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			id := mustPush(t, queue, Task{
+				TaskExecutable: &flakyExecutable{failures: 5},
+				Retry:          &RetryPolicy{MaxAttempts: 5},
+			})
+
+			// running task is cancelled, but fails with an error of its own rather than the context's
+			popped := queue.Pop()
+			assert.Assert(t, popped != nil)
+			assert.Check(t, queue.Cancel(id))
+			queue.Complete(popped.Id, errFlaky)
+
+			info := queue.Get(id)
+			assert.Equal(t, TaskCancelled, info.State)
+			assert.Equal(t, 1, info.Attempts)
+			assert.Equal(t, errFlaky, info.Error)
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     3,
+	}
+
+	expectedBackoffs := []time.Duration{
+		100 * time.Millisecond,
+		300 * time.Millisecond,
+		900 * time.Millisecond,
+		time.Second,
+	}
+	for index, expected := range expectedBackoffs {
+		assert.Equal(t, expected, policy.backoff(index+1))
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		backoff := policy.backoff(1)
+		assert.Check(t, backoff >= 50*time.Millisecond && backoff <= 100*time.Millisecond, "backoff: %v", backoff)
+	}
+
+	assert.Check(t, policy.shouldRetry(9, errFlaky))
+	assert.Check(t, !policy.shouldRetry(10, errFlaky))
+}
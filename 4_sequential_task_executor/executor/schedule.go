@@ -0,0 +1,216 @@
+package executor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule tells when a recurring task should run next
+type Schedule interface {
+	// Returns the first activation time strictly after given time
+	Next(after time.Time) time.Time
+}
+
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+// Every creates a schedule firing every `interval`, measured from the moment it's registered.
+// Interval has to be positive, `RecurringScheduler.Register` rejects the schedule otherwise.
+func Every(interval time.Duration) Schedule {
+	return &intervalSchedule{interval: interval}
+}
+
+func (s *intervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(s.interval)
+}
+
+func (s *intervalSchedule) String() string {
+	return fmt.Sprintf("every %v", s.interval)
+}
+
+// cronSchedule is a standard 5-field cron expression: `minute hour day-of-month month day-of-week`.
+// Every field is kept as a bit set of allowed values.
+type cronSchedule struct {
+	expression string
+
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+
+	// When both days are restricted, cron runs if EITHER of them matches
+	daysOfMonthRestricted bool
+	daysOfWeekRestricted  bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronMinutes     = cronField{name: "minute", min: 0, max: 59}
+	cronHours       = cronField{name: "hour", min: 0, max: 23}
+	cronDaysOfMonth = cronField{name: "day of month", min: 1, max: 31}
+	cronMonths      = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is accepted as Sunday too, it's folded onto 0 after parsing
+	cronDaysOfWeek = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// ParseCronSchedule parses standard 5-field cron expression, e.g. `0 2 * * *` (every day at 02:00)
+// or `*/15 9-17 * * mon-fri`. Each field accepts `*`, single values, ranges `a-b`, steps `*/n` or `a-b/n`
+// and comma separated lists of those. Months and days of week can be given by their three letter names.
+// Times are evaluated in the location of the time passed to `Next`.
+func ParseCronSchedule(expression string) (Schedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, has %v", expression, len(fields))
+	}
+
+	schedule := &cronSchedule{expression: expression}
+	var err error
+
+	if schedule.minutes, err = cronMinutes.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if schedule.hours, err = cronHours.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if schedule.daysOfMonth, err = cronDaysOfMonth.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if schedule.months, err = cronMonths.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if schedule.daysOfWeek, err = cronDaysOfWeek.parse(fields[4]); err != nil {
+		return nil, err
+	}
+
+	if schedule.daysOfWeek&(1<<7) != 0 {
+		schedule.daysOfWeek = schedule.daysOfWeek&^(1<<7) | 1
+	}
+	schedule.daysOfMonthRestricted = !strings.HasPrefix(fields[2], "*")
+	schedule.daysOfWeekRestricted = !strings.HasPrefix(fields[4], "*")
+
+	return schedule, nil
+}
+
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		partBits, err := f.parsePart(part)
+		if err != nil {
+			return 0, err
+		}
+		bits |= partBits
+	}
+	return bits, nil
+}
+
+func (f cronField) parsePart(part string) (uint64, error) {
+	rangePart, step := part, 1
+	if slash := strings.Index(part, "/"); slash >= 0 {
+		parsedStep, err := strconv.Atoi(part[slash+1:])
+		if err != nil || parsedStep <= 0 {
+			return 0, fmt.Errorf("invalid step in %v field: %q", f.name, part)
+		}
+		rangePart, step = part[:slash], parsedStep
+	}
+
+	from, to := f.min, f.max
+	if rangePart != "*" {
+		bounds := strings.SplitN(rangePart, "-", 2)
+		var err error
+		if from, err = f.parseValue(bounds[0]); err != nil {
+			return 0, err
+		}
+		to = from
+		if len(bounds) == 2 {
+			if to, err = f.parseValue(bounds[1]); err != nil {
+				return 0, err
+			}
+		} else if step > 1 {
+			// `a/n` means from `a` till the end of the range
+			to = f.max
+		}
+		if from > to {
+			return 0, fmt.Errorf("invalid range in %v field: %q", f.name, part)
+		}
+	}
+
+	var bits uint64
+	for value := from; value <= to; value += step {
+		bits |= 1 << uint(value)
+	}
+	return bits, nil
+}
+
+func (f cronField) parseValue(value string) (int, error) {
+	if named, found := f.names[strings.ToLower(value)]; found {
+		return named, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < f.min || parsed > f.max {
+		return 0, fmt.Errorf("invalid %v: %q, expected value between %v and %v", f.name, value, f.min, f.max)
+	}
+	return parsed, nil
+}
+
+func (s *cronSchedule) Next(after time.Time) time.Time {
+	// cron has a minute resolution, the next candidate is the beginning of the following minute
+	next := after.Truncate(time.Minute).Add(time.Minute)
+
+	// there is always a matching time within few years (e.g. 29th of February), unless expression is
+	// something impossible like `0 0 31 2 *` - then we give up and return zero time
+	limit := next.AddDate(5, 0, 0)
+	for next.Before(limit) {
+		if !s.matches(s.months, int(next.Month())) {
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location())
+			continue
+		}
+		if !s.matchesDay(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+			continue
+		}
+		if !s.matches(s.hours, next.Hour()) {
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+			continue
+		}
+		if !s.matches(s.minutes, next.Minute()) {
+			next = next.Add(time.Minute)
+			continue
+		}
+		return next
+	}
+
+	return time.Time{}
+}
+
+func (s *cronSchedule) matches(bits uint64, value int) bool {
+	return bits&(1<<uint(value)) != 0
+}
+
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := s.matches(s.daysOfMonth, t.Day())
+	dayOfWeek := s.matches(s.daysOfWeek, int(t.Weekday()))
+
+	if s.daysOfMonthRestricted && s.daysOfWeekRestricted {
+		return dayOfMonth || dayOfWeek
+	}
+	return dayOfMonth && dayOfWeek
+}
+
+func (s *cronSchedule) String() string {
+	return s.expression
+}
@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"gotest.tools/assert"
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// Wednesday
+	start := time.Date(2022, time.August, 31, 10, 17, 30, 0, time.UTC)
+
+	tests := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{expression: "* * * * *", expected: time.Date(2022, time.August, 31, 10, 18, 0, 0, time.UTC)},
+		{expression: "*/15 * * * *", expected: time.Date(2022, time.August, 31, 10, 30, 0, 0, time.UTC)},
+		{expression: "0 2 * * *", expected: time.Date(2022, time.September, 1, 2, 0, 0, 0, time.UTC)},
+		{expression: "30 9-17/4 * * *", expected: time.Date(2022, time.August, 31, 13, 30, 0, 0, time.UTC)},
+		{expression: "0 0 1 jan *", expected: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{expression: "0 12 * * mon-fri", expected: time.Date(2022, time.August, 31, 12, 0, 0, 0, time.UTC)},
+		{expression: "0 12 * * sat,sun", expected: time.Date(2022, time.September, 3, 12, 0, 0, 0, time.UTC)},
+		{expression: "0 12 * * 7", expected: time.Date(2022, time.September, 4, 12, 0, 0, 0, time.UTC)},
+		{expression: "0 0 29 2 *", expected: time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		// both days restricted - either of them matches
+		{expression: "0 0 15 * fri", expected: time.Date(2022, time.September, 2, 0, 0, 0, 0, time.UTC)},
+		{expression: "0 0 31 2 *", expected: time.Time{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			schedule, err := ParseCronSchedule(test.expression)
+			assert.NilError(t, err)
+			assert.Equal(t, test.expected, schedule.Next(start))
+		})
+	}
+}
+
+func TestCronScheduleInvalid(t *testing.T) {
+	expressions := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+	}
+
+	for _, expression := range expressions {
+		t.Run(expression, func(t *testing.T) {
+			_, err := ParseCronSchedule(expression)
+			assert.Check(t, err != nil)
+		})
+	}
+}
+
+func TestEverySchedule(t *testing.T) {
+	start := time.Date(2022, time.August, 31, 10, 17, 30, 0, time.UTC)
+	assert.Equal(t, start.Add(90*time.Second), Every(90*time.Second).Next(start))
+}
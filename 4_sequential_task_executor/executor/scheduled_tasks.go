@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"container/heap"
+	"time"
+)
+
+// scheduledTasks keeps tasks which are not due yet, the one due the soonest on top. Not thread safe.
+type scheduledTasks struct {
+	items    scheduledHeap
+	itemsMap map[*taskRecord]*scheduledItem
+	sequence uint64
+}
+
+type scheduledItem struct {
+	record   *taskRecord
+	dueAt    time.Time
+	sequence uint64
+	index    int
+}
+
+func newScheduledTasks() *scheduledTasks {
+	return &scheduledTasks{
+		items:    make(scheduledHeap, 0),
+		itemsMap: make(map[*taskRecord]*scheduledItem),
+	}
+}
+
+func (s *scheduledTasks) push(record *taskRecord, dueAt time.Time) {
+	item := &scheduledItem{
+		record:   record,
+		dueAt:    dueAt,
+		sequence: s.sequence,
+	}
+	s.sequence++
+
+	heap.Push(&s.items, item)
+	s.itemsMap[record] = item
+}
+
+// Removes and returns the task due the soonest, if it's due at `now` or earlier
+func (s *scheduledTasks) popDue(now time.Time) *taskRecord {
+	if len(s.items) == 0 || s.items[0].dueAt.After(now) {
+		return nil
+	}
+
+	item := heap.Pop(&s.items).(*scheduledItem)
+	delete(s.itemsMap, item.record)
+	return item.record
+}
+
+// Returns when the next task is due, false if nothing is scheduled
+func (s *scheduledTasks) nextDue() (time.Time, bool) {
+	if len(s.items) == 0 {
+		return time.Time{}, false
+	}
+	return s.items[0].dueAt, true
+}
+
+func (s *scheduledTasks) remove(record *taskRecord) bool {
+	item, found := s.itemsMap[record]
+	if !found {
+		return false
+	}
+
+	heap.Remove(&s.items, item.index)
+	delete(s.itemsMap, record)
+	return true
+}
+
+func (s *scheduledTasks) clear() []*taskRecord {
+	cleared := make([]*taskRecord, 0, len(s.items))
+	for _, item := range s.items {
+		cleared = append(cleared, item.record)
+	}
+
+	s.items = make(scheduledHeap, 0)
+	s.itemsMap = make(map[*taskRecord]*scheduledItem)
+	return cleared
+}
+
+func (s *scheduledTasks) len() int {
+	return len(s.items)
+}
+
+// scheduledHeap implements `heap.Interface`, the item due the soonest is on top
+type scheduledHeap []*scheduledItem
+
+func (h scheduledHeap) Len() int { return len(h) }
+
+func (h scheduledHeap) Less(i, j int) bool {
+	if !h[i].dueAt.Equal(h[j].dueAt) {
+		return h[i].dueAt.Before(h[j].dueAt)
+	}
+	return h[i].sequence < h[j].sequence
+}
+
+func (h scheduledHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *scheduledHeap) Push(x interface{}) {
+	item := x.(*scheduledItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *scheduledHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
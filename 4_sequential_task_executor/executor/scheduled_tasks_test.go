@@ -0,0 +1,101 @@
+package executor
+
+import (
+	"context"
+	"gotest.tools/assert"
+	"testing"
+	"time"
+)
+
+func TestPushAfter(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			pushedAt := time.Now()
+			id, err := queue.PushAfter(NewExecutableQuickie(), 50*time.Millisecond)
+			assert.NilError(t, err)
+
+			info := queue.Get(id)
+			assert.Equal(t, TaskScheduled, info.State)
+			assert.Check(t, !info.DueAt.Before(pushedAt.Add(50*time.Millisecond)))
+			assert.Equal(t, TaskScheduled, queue.List()[0].State)
+
+			// not due yet
+			assert.Check(t, queue.Pop() == nil)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			popped, err := queue.PopContext(ctx)
+			assert.NilError(t, err)
+			assert.Equal(t, id, popped.Id)
+			assert.Check(t, time.Since(pushedAt) >= 50*time.Millisecond)
+			assert.Equal(t, TaskRunning, queue.Get(id).State)
+		})
+	}
+}
+
+func TestPushAtOrdering(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			now := time.Now()
+			latestId, err := queue.PushAt(NewExecutableQuickie(), now.Add(60*time.Millisecond))
+			assert.NilError(t, err)
+			laterId, err := queue.PushAt(NewExecutableQuickie(), now.Add(30*time.Millisecond))
+			assert.NilError(t, err)
+			// scheduling in the past is the same as pushing
+			pastId, err := queue.PushAt(NewExecutableQuickie(), now.Add(-time.Hour))
+			assert.NilError(t, err)
+			assert.Equal(t, TaskQueued, queue.Get(pastId).State)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			for _, expectedId := range []string{pastId, laterId, latestId} {
+				popped, err := queue.PopContext(ctx)
+				assert.NilError(t, err)
+				assert.Equal(t, expectedId, popped.Id)
+			}
+		})
+	}
+}
+
+func TestCancelScheduledTask(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			id, err := queue.PushAfter(NewExecutableQuickie(), 20*time.Millisecond)
+			assert.NilError(t, err)
+			assert.Check(t, queue.Cancel(id))
+			assert.Equal(t, TaskCancelled, queue.Get(id).State)
+
+			// cancelled task never fires
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			popped, err := queue.PopContext(ctx)
+			assert.Check(t, popped == nil)
+			assert.Equal(t, context.DeadlineExceeded, err)
+		})
+	}
+}
+
+func TestExecutorRunsScheduledTask(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor()
+			defer executor.Stop()
+
+			id, err := queue.PushAfter(NewExecutableQuickie(), 30*time.Millisecond)
+			assert.NilError(t, err)
+
+			info := waitForFinishedTask(queue, id, 5*time.Second)
+			assert.Equal(t, TaskSucceeded, info.State)
+			assert.Check(t, !info.StartedAt.Before(info.DueAt))
+		})
+	}
+}
@@ -1,34 +1,142 @@
 package executor
 
 import (
+	"AwesomePresentation/logging"
+	"context"
+	"encoding/json"
 	"fmt"
+	"go.opentelemetry.io/otel/trace"
 	"math/rand"
 	"time"
 )
 
 type TaskInfo struct {
-	Id string
+	Id             string
+	State          TaskState
+	Priority       int
+	PartitionKey   string
+	IdempotencyKey string
+
+	// Zero value if the task didn't reach given stage yet
+	EnqueuedAt time.Time
+	// When scheduled task (or the next attempt of a failed one) is going to be queued
+	DueAt      time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	// Error returned by `Executable.Execute()`, nil unless the task has failed. `*PanicError` if it panicked
+	Error error
+	// Stack trace of the panic, empty unless the executable panicked
+	Stack string
+	// Failed task is kept in the dead letter queue, until it's requeued or forgotten
+	DeadLettered bool
+
+	// How many times the task has been started and the error of the latest failed attempt, see `RetryPolicy`
+	Attempts  int
+	LastError error
+
+	// Ids of the tasks this one waits for, see `Task.DependsOn`. Blocked task lists those which haven't
+	// finished yet, empty once it's released
+	DependsOn []string
+	BlockedOn []string
+
+	// Executor worker which runs (or has run) the latest attempt, zero if the task wasn't popped by one
+	Worker int
+
+	// Value returned by `ExecutableWithResult`, nil unless such task has succeeded
+	Result interface{}
 	// FIXME: could also have more data copied from Task
-	//		We usually keep lots more information, such as:
-	//			- the result
-	//			- wait group (to be able to wait for the task to finish and block),
-	//			- etc.
 }
 
 type Task struct {
 	Id             string
 	TaskExecutable Executable
+
+	// Only used by priority queues - tasks with higher priority are popped first
+	Priority int
+
+	// Failed task is retried according to the policy, nil means it's never retried
+	Retry *RetryPolicy
+
+	// Only used by keyed executors - tasks with the same key run one by one, in the order they were pushed
+	// (retries and abandoned executions aside, see `KeyedExecutor`)
+	PartitionKey string
+
+	// Pushing a task with the key of a task which the queue still knows returns that task instead of adding
+	// a new one, so pushes can be safely retried. See `WithIdempotencyWindow`
+	IdempotencyKey string
+
+	// Ids of tasks in the same queue which have to succeed before this one is queued. Task is blocked until
+	// then, and fails (or is skipped) once any of them doesn't succeed. See also `SubmitGraph`
+	DependsOn           []string
+	OnDependencyFailure DependencyFailurePolicy
+
+	// Execution running longer has its context cancelled and the task ends up `TaskTimedOut` (unless it's
+	// retried, see `RetryPolicy` and `TimeoutPolicy`). Zero means the executor's default is used,
+	// see `WithTaskTimeout`
+	Timeout time.Duration
+
+	// Span the task is part of, see `WithTraceContext`. Waiting in the queue and executions of the task are
+	// traced as its children. Zero value means the task starts a trace of its own
+	TraceContext trace.SpanContext
+
+	// Context of the current execution, set by the queue when the task is popped. Cancelled by `Cancel`
+	ctx context.Context
+}
+
+// WithTraceContext returns the task as part of the span active in the context, e.g. the request which
+// pushes the task. See `WithTracerProvider`
+func (t Task) WithTraceContext(ctx context.Context) Task {
+	t.TraceContext = trace.SpanContextFromContext(ctx)
+	return t
+}
+
+// Returns the context the task should be executed with, never nil
+func (t *Task) context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
 }
 
 type Executable interface {
 	Execute() error
 }
 
+// ContextExecutable is an executable that can be stopped. Implementations should return as soon as possible
+// once the context is done, preferably with `ctx.Err()`.
+type ContextExecutable interface {
+	ExecuteContext(ctx context.Context) error
+}
+
+// AdaptExecutable makes any executable usable where cancellation is expected. Executables that already
+// implement `ContextExecutable` are returned as they are, others simply ignore the context.
+func AdaptExecutable(executable Executable) ContextExecutable {
+	if contextExecutable, ok := executable.(ContextExecutable); ok {
+		return contextExecutable
+	}
+	return &contextIgnoringExecutable{executable: executable}
+}
+
+type contextIgnoringExecutable struct {
+	executable Executable
+}
+
+func (e *contextIgnoringExecutable) ExecuteContext(ctx context.Context) error {
+	return e.executable.Execute()
+}
+
 type ExecutableCounterWithSleep struct {
 	CountLimit  int
 	CountPeriod time.Duration
 }
 
+// JSON form of the counter, period is a duration string like "500ms"
+type counterParams struct {
+	CountLimit  int    `json:"countLimit"`
+	CountPeriod string `json:"countPeriod"`
+}
+
 func NewExecutableCounterWithSleep(countLimit int, countPeriod time.Duration) Task {
 	return Task{
 		TaskExecutable: &ExecutableCounterWithSleep{
@@ -38,35 +146,76 @@ func NewExecutableCounterWithSleep(countLimit int, countPeriod time.Duration) Ta
 	}
 }
 
+func (e *ExecutableCounterWithSleep) MarshalJSON() ([]byte, error) {
+	return json.Marshal(counterParams{CountLimit: e.CountLimit, CountPeriod: e.CountPeriod.String()})
+}
+
+func (e *ExecutableCounterWithSleep) UnmarshalJSON(data []byte) error {
+	// missing params keep their current values
+	params := counterParams{CountLimit: e.CountLimit, CountPeriod: e.CountPeriod.String()}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return err
+	}
+
+	countPeriod, err := time.ParseDuration(params.CountPeriod)
+	if err != nil {
+		return fmt.Errorf("invalid countPeriod: %w", err)
+	}
+
+	e.CountLimit = params.CountLimit
+	e.CountPeriod = countPeriod
+	return nil
+}
+
 func (e *ExecutableCounterWithSleep) Execute() error {
-	fmt.Println(fmt.Sprintf("Counting starting"))
+	return e.ExecuteContext(context.Background())
+}
+
+func (e *ExecutableCounterWithSleep) ExecuteContext(ctx context.Context) error {
+	logger := logging.FromContext(ctx)
+	logger.Info("Counting starting")
 	for i := 0; i < e.CountLimit; i++ {
-		time.Sleep(e.CountPeriod)
-		fmt.Println(fmt.Sprintf("Counting: %v", i))
+		select {
+		case <-time.After(e.CountPeriod):
+		case <-ctx.Done():
+			logger.Info("Counting interrupted", logging.Value(i))
+			return ctx.Err()
+		}
+		logger.Info("Counting", logging.Value(i))
 	}
-	fmt.Println(fmt.Sprintf("Counting finished"))
+	logger.Info("Counting finished")
 	return nil
 }
 
 type ExecutableAnnoyingKid struct {
-	Task
-	RandomSentences []string
+	Task            `json:"-"`
+	RandomSentences []string `json:"randomSentences,omitempty"`
 }
 
 func (e *ExecutableAnnoyingKid) Execute() error {
-	fmt.Println(fmt.Sprintf("Annoying kid stating"))
+	return e.ExecuteContext(context.Background())
+}
+
+func (e *ExecutableAnnoyingKid) ExecuteContext(ctx context.Context) error {
+	logger := logging.FromContext(ctx)
+	logger.Info("Annoying kid stating")
 	for _, sentence := range e.RandomSentences {
-		fmt.Println(sentence)
+		logger.Info(sentence)
 
-		// Sleep random amount of time
-		time.Sleep(time.Duration(rand.Float64()*10.0) * time.Second)
+		// Sleep random amount of time, unless somebody finally had enough
+		select {
+		case <-time.After(time.Duration(rand.Float64()*10.0) * time.Second):
+		case <-ctx.Done():
+			logger.Info("Annoying kid was told to stop")
+			return ctx.Err()
+		}
 
 		// Fail randomly with 10% chance
 		if rand.Float64() < 0.1 {
 			return fmt.Errorf("I am not sorry, I failed :) ")
 		}
 	}
-	fmt.Println(fmt.Sprintf("Annoying kid finished"))
+	logger.Info("Annoying kid finished")
 	return nil
 }
 
@@ -99,15 +248,23 @@ func NewExecutableAnnoyingKid() Task {
 }
 
 type ExecutableQuickie struct {
-	Task
+	Task `json:"-"`
 }
 
 func (e *ExecutableQuickie) Execute() error {
-	fmt.Println(fmt.Sprintf("Quickie stating"))
+	return e.ExecuteContext(context.Background())
+}
+
+func (e *ExecutableQuickie) ExecuteContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	logger := logging.FromContext(ctx)
+	logger.Info("Quickie stating")
 
-	fmt.Println(fmt.Sprintf(":))"))
+	logger.Info(":))")
 
-	fmt.Println(fmt.Sprintf("Quickie finished"))
+	logger.Info("Quickie finished")
 	return nil
 }
 
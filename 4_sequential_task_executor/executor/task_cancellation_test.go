@@ -0,0 +1,71 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"gotest.tools/assert"
+	"testing"
+	"time"
+)
+
+func TestCancelQueuedTask(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			cancelledId := mustPush(t, queue, NewExecutableQuickie())
+			remainingId := mustPush(t, queue, NewExecutableQuickie())
+
+			assert.Check(t, queue.Cancel(cancelledId))
+			// cancelling twice has no effect
+			assert.Check(t, !queue.Cancel(cancelledId))
+			assert.Check(t, !queue.Cancel("unknown"))
+
+			info := queue.Get(cancelledId)
+			assert.Equal(t, TaskCancelled, info.State)
+			assert.Check(t, errors.Is(info.Error, context.Canceled))
+
+			popped := queue.Pop()
+			assert.Assert(t, popped != nil)
+			assert.Equal(t, remainingId, popped.Id)
+		})
+	}
+}
+
+func TestCancelRunningTask(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor()
+			defer executor.Stop()
+
+			// would count for almost two minutes if not cancelled
+			id := mustPush(t, queue, NewExecutableCounterWithSleep(1000, 100*time.Millisecond))
+
+			waitForTaskState(t, queue, id, TaskRunning)
+
+			assert.Check(t, queue.Cancel(id))
+
+			info := waitForFinishedTask(queue, id, 5*time.Second)
+			assert.Equal(t, TaskCancelled, info.State)
+			assert.Check(t, errors.Is(info.Error, context.Canceled))
+		})
+	}
+}
+
+func TestAdaptExecutable(t *testing.T) {
+	collection := make([]int, 0)
+	plain := NewTestSliceCollectingExecutable(1, &collection).TaskExecutable
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// plain executables know nothing about cancellation, they simply run
+	assert.NilError(t, AdaptExecutable(plain).ExecuteContext(ctx))
+	assert.DeepEqual(t, []int{1}, collection)
+
+	// context aware executables are returned as they are
+	quickie := NewExecutableQuickie().TaskExecutable
+	assert.Equal(t, quickie, AdaptExecutable(quickie))
+	assert.Check(t, errors.Is(AdaptExecutable(quickie).ExecuteContext(ctx), context.Canceled))
+}
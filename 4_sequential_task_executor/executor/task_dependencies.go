@@ -0,0 +1,146 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DependencyFailurePolicy decides what happens to a task whose dependency didn't succeed, see `Task.DependsOn`
+type DependencyFailurePolicy int
+
+const (
+	// Dependent task fails with `*DependencyError` and ends up in the dead letter queue
+	DependencyFail DependencyFailurePolicy = iota
+	// Dependent task is skipped - it finishes as `TaskSkipped`, with `*DependencyError` as its error
+	DependencySkip
+)
+
+func (p DependencyFailurePolicy) String() string {
+	switch p {
+	case DependencyFail:
+		return "fail"
+	case DependencySkip:
+		return "skip"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText makes the policy readable in `TaskSpec`, e.g. {"onDependencyFailure": "skip"}
+func (p DependencyFailurePolicy) MarshalText() ([]byte, error) {
+	switch p {
+	case DependencyFail, DependencySkip:
+		return []byte(p.String()), nil
+	default:
+		return nil, fmt.Errorf("unknown dependency failure policy %d", int(p))
+	}
+}
+
+func (p *DependencyFailurePolicy) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "", "fail":
+		*p = DependencyFail
+	case "skip":
+		*p = DependencySkip
+	default:
+		return fmt.Errorf("unknown dependency failure policy %q", string(text))
+	}
+	return nil
+}
+
+// SubmitGraph submits tasks which depend on each other. `DependsOn` of a graph task may name other tasks
+// of the graph by their keys, as well as ids of tasks which are already in the queue. Tasks are pushed
+// in dependency order and the returned handles are keyed like the graph.
+//
+// Cycles are detected before anything is pushed, the error wraps `ErrDependencyCycle`. When a push fails
+// midway, tasks pushed so far are cancelled. Queues only know their own tasks, so with a keyed executor
+// all tasks of the graph need the same partition key.
+func SubmitGraph(queue TaskQueue, graph map[string]Task) (map[string]*TaskHandle, error) {
+	keys, err := dependencyOrder(graph)
+	if err != nil {
+		return nil, err
+	}
+
+	handles := make(map[string]*TaskHandle, len(graph))
+	ids := make(map[string]string, len(graph))
+	for _, key := range keys {
+		task := graph[key]
+		task.DependsOn = make([]string, 0, len(graph[key].DependsOn))
+		for _, dependency := range graph[key].DependsOn {
+			if id, found := ids[dependency]; found {
+				dependency = id
+			}
+			task.DependsOn = append(task.DependsOn, dependency)
+		}
+
+		handle, err := queue.Submit(task)
+		if err != nil {
+			for _, id := range ids {
+				queue.Cancel(id)
+			}
+			return nil, fmt.Errorf("failed to submit task %v of the graph: %w", key, err)
+		}
+		handles[key] = handle
+		ids[key] = handle.Id()
+	}
+
+	return handles, nil
+}
+
+// Sorts keys of the graph so that every task comes after the graph tasks it depends on. Dependencies which
+// are not part of the graph are left to the queue. Keys are visited in sorted order, the result is stable.
+func dependencyOrder(graph map[string]Task) ([]string, error) {
+	keys := make([]string, 0, len(graph))
+	for key := range graph {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[string]int, len(graph))
+	ordered := make([]string, 0, len(graph))
+	// keys being visited, to report the cycle once one is found
+	path := make([]string, 0)
+
+	var visit func(key string) error
+	visit = func(key string) error {
+		switch marks[key] {
+		case visited:
+			return nil
+		case visiting:
+			start := 0
+			for path[start] != key {
+				start++
+			}
+			cycle := append(append([]string{}, path[start:]...), key)
+			return fmt.Errorf("%w: %v", ErrDependencyCycle, strings.Join(cycle, " -> "))
+		}
+
+		marks[key] = visiting
+		path = append(path, key)
+		for _, dependency := range graph[key].DependsOn {
+			if _, inGraph := graph[dependency]; !inGraph {
+				continue
+			}
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		marks[key] = visited
+		ordered = append(ordered, key)
+		return nil
+	}
+
+	for _, key := range keys {
+		if err := visit(key); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
@@ -0,0 +1,309 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gotest.tools/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTaskWaitsForDependencies(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			firstId := mustPush(t, queue, NewExecutableQuickie())
+			secondId := mustPush(t, queue, NewExecutableQuickie())
+			dependentId := mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, DependsOn: []string{firstId, secondId}})
+
+			info := queue.Get(dependentId)
+			assert.Equal(t, TaskBlocked, info.State)
+			assert.DeepEqual(t, []string{firstId, secondId}, info.BlockedOn)
+			assert.DeepEqual(t, []string{firstId, secondId}, info.DependsOn)
+			assert.Equal(t, 3, queue.Stats().Waiting)
+
+			assert.Equal(t, firstId, queue.Pop().Id)
+			queue.Complete(firstId, nil)
+			info = queue.Get(dependentId)
+			assert.Equal(t, TaskBlocked, info.State)
+			assert.DeepEqual(t, []string{secondId}, info.BlockedOn)
+
+			// blocked task is never popped
+			assert.Equal(t, secondId, queue.Pop().Id)
+			assert.Check(t, queue.Pop() == nil)
+
+			queue.Complete(secondId, nil)
+			info = queue.Get(dependentId)
+			assert.Equal(t, TaskQueued, info.State)
+			assert.Equal(t, 0, len(info.BlockedOn))
+			assert.Equal(t, dependentId, queue.Pop().Id)
+
+			// dependencies which already succeeded don't block
+			id := mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, DependsOn: []string{firstId}})
+			assert.Equal(t, TaskQueued, queue.Get(id).State)
+		})
+	}
+}
+
+func TestTaskDependencyFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy DependencyFailurePolicy
+		// dependent task, and the task depending on it
+		expectedState      TaskState
+		expectedDeadLetter bool
+	}{
+		{name: "fail", policy: DependencyFail, expectedState: TaskFailed, expectedDeadLetter: true},
+		{name: "skip", policy: DependencySkip, expectedState: TaskSkipped},
+	}
+
+	for _, test := range tests {
+		for _, constructor := range queueConstructors {
+			t.Run(test.name+" "+constructor.name, func(t *testing.T) {
+				queue := constructor.newQueue()
+				defer queue.Stop()
+
+				failedId := mustPush(t, queue, NewExecutableQuickie())
+				dependentId := mustPush(t, queue, Task{
+					TaskExecutable:      &ExecutableQuickie{},
+					DependsOn:           []string{failedId},
+					OnDependencyFailure: test.policy,
+				})
+				transitiveId := mustPush(t, queue, Task{
+					TaskExecutable:      &ExecutableQuickie{},
+					DependsOn:           []string{dependentId},
+					OnDependencyFailure: test.policy,
+				})
+
+				queue.Pop()
+				queue.Complete(failedId, errors.New("failed on purpose"))
+
+				for _, id := range []string{dependentId, transitiveId} {
+					info := queue.Get(id)
+					assert.Equal(t, test.expectedState, info.State)
+					assert.Equal(t, test.expectedDeadLetter, info.DeadLettered)
+					assert.Equal(t, 0, len(info.BlockedOn))
+
+					var dependencyErr *DependencyError
+					assert.Check(t, errors.As(info.Error, &dependencyErr))
+				}
+				var dependencyErr *DependencyError
+				assert.Check(t, errors.As(queue.Get(dependentId).Error, &dependencyErr))
+				assert.Equal(t, failedId, dependencyErr.Id)
+				assert.Equal(t, TaskFailed, dependencyErr.State)
+
+				// pushing a task depending on the failed one finishes it right away
+				lateId := mustPush(t, queue, Task{
+					TaskExecutable:      &ExecutableQuickie{},
+					DependsOn:           []string{failedId},
+					OnDependencyFailure: test.policy,
+				})
+				assert.Equal(t, test.expectedState, queue.Get(lateId).State)
+				assert.Check(t, queue.Pop() == nil)
+				assert.Equal(t, 0, queue.Stats().Waiting)
+			})
+		}
+	}
+}
+
+func TestCancelledDependencyFailsDependents(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			dependencyId := mustPush(t, queue, NewExecutableQuickie())
+			dependentId := mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, DependsOn: []string{dependencyId}})
+			cancelledId := mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, DependsOn: []string{dependencyId}})
+
+			// blocked task can be cancelled on its own
+			assert.Check(t, queue.Cancel(cancelledId))
+			assert.Equal(t, TaskCancelled, queue.Get(cancelledId).State)
+
+			assert.Check(t, queue.Cancel(dependencyId))
+			info := queue.Get(dependentId)
+			assert.Equal(t, TaskFailed, info.State)
+			var dependencyErr *DependencyError
+			assert.Check(t, errors.As(info.Error, &dependencyErr))
+			assert.Equal(t, TaskCancelled, dependencyErr.State)
+		})
+	}
+}
+
+func TestUnknownDependency(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			_, err := queue.Push(Task{TaskExecutable: &ExecutableQuickie{}, DependsOn: []string{"unknown"}})
+			assert.Check(t, errors.Is(err, ErrUnknownDependency))
+			assert.Equal(t, 0, len(queue.List()))
+		})
+	}
+}
+
+func TestRequeueDependencyAndDependent(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			dependencyId := mustPush(t, queue, NewExecutableQuickie())
+			dependentId := mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, DependsOn: []string{dependencyId}})
+			queue.Pop()
+			queue.Complete(dependencyId, errors.New("failed on purpose"))
+			assert.Equal(t, 2, len(queue.DeadLetters()))
+
+			// requeued dependent waits for the requeued dependency again
+			assert.NilError(t, queue.Requeue(dependencyId))
+			assert.NilError(t, queue.Requeue(dependentId))
+			assert.Equal(t, TaskBlocked, queue.Get(dependentId).State)
+
+			assert.Equal(t, dependencyId, queue.Pop().Id)
+			queue.Complete(dependencyId, nil)
+			assert.Equal(t, dependentId, queue.Pop().Id)
+		})
+	}
+}
+
+func TestShutdownWithBlockedTasks(t *testing.T) {
+	tests := []struct {
+		name          string
+		drain         bool
+		expectedState TaskState
+	}{
+		{name: "drain", drain: true, expectedState: TaskSucceeded},
+		{name: "no drain", drain: false, expectedState: TaskCancelled},
+	}
+
+	for _, test := range tests {
+		for _, constructor := range executorConstructors {
+			t.Run(test.name+" "+constructor.name, func(t *testing.T) {
+				queue, executor := constructor.newExecutor(WithDrainOnShutdown(test.drain))
+
+				release := make(chan struct{})
+				blockingId := mustPush(t, queue, Task{TaskExecutable: &blockingExecutable{release: release}})
+				dependentId := mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, DependsOn: []string{blockingId}})
+				waitForTaskState(t, queue, blockingId, TaskRunning)
+
+				shutdownErr := make(chan error)
+				go func() {
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+					shutdownErr <- executor.Shutdown(ctx)
+				}()
+				time.Sleep(10 * time.Millisecond)
+				close(release)
+
+				assert.NilError(t, <-shutdownErr)
+				assert.Equal(t, test.expectedState, queue.Get(dependentId).State)
+			})
+		}
+	}
+}
+
+func TestSubmitGraph(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor(WithWorkers(3))
+			defer executor.Stop()
+
+			finished := make(chan int, 4)
+			existingId := mustPush(t, queue, Task{TaskExecutable: &workerReportingExecutable{workers: finished}})
+
+			handles, err := SubmitGraph(queue, map[string]Task{
+				"report": {TaskExecutable: &ExecutableQuickie{}, DependsOn: []string{"build", "test"}},
+				"test":   {TaskExecutable: &ExecutableQuickie{}, DependsOn: []string{"build"}},
+				"build":  {TaskExecutable: &ExecutableQuickie{}, DependsOn: []string{existingId}},
+			})
+			assert.NilError(t, err)
+			assert.Equal(t, 3, len(handles))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			assert.NilError(t, handles["report"].Wait(ctx))
+
+			report := handles["report"].Info()
+			test := queue.Get(handles["test"].Id())
+			build := queue.Get(handles["build"].Id())
+			assert.DeepEqual(t, []string{build.Id, test.Id}, report.DependsOn)
+			assert.DeepEqual(t, []string{existingId}, build.DependsOn)
+			assert.Check(t, !report.StartedAt.Before(test.FinishedAt))
+			assert.Check(t, !test.StartedAt.Before(build.FinishedAt))
+		})
+	}
+}
+
+func TestSubmitGraphDetectsCycles(t *testing.T) {
+	queue := NewLockingTaskQueue()
+	defer queue.Stop()
+
+	_, err := SubmitGraph(queue, map[string]Task{
+		"a": {TaskExecutable: &ExecutableQuickie{}, DependsOn: []string{"b"}},
+		"b": {TaskExecutable: &ExecutableQuickie{}, DependsOn: []string{"c"}},
+		"c": {TaskExecutable: &ExecutableQuickie{}, DependsOn: []string{"a"}},
+		"d": {TaskExecutable: &ExecutableQuickie{}},
+	})
+	assert.Check(t, errors.Is(err, ErrDependencyCycle))
+	assert.ErrorContains(t, err, "a -> b -> c -> a")
+	// nothing has been pushed
+	assert.Equal(t, 0, len(queue.List()))
+
+	// failed push cancels the tasks pushed before
+	_, err = SubmitGraph(queue, map[string]Task{
+		"a": {TaskExecutable: &ExecutableQuickie{}},
+		"b": {TaskExecutable: &ExecutableQuickie{}, DependsOn: []string{"a", "unknown"}},
+	})
+	assert.Check(t, errors.Is(err, ErrUnknownDependency))
+	tasks := queue.List()
+	assert.Equal(t, 1, len(tasks))
+	assert.Equal(t, TaskCancelled, tasks[0].State)
+}
+
+func TestPersistentTaskQueueRestoresBlockedTasks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.log")
+	queue := openPersistentQueue(t, path)
+
+	succeededId := mustPush(t, queue, Task{TaskExecutable: &storedExecutable{Name: "succeeded"}})
+	runningId := mustPush(t, queue, Task{TaskExecutable: &storedExecutable{Name: "running"}})
+	dependentId := mustPush(t, queue, Task{
+		TaskExecutable:      &storedExecutable{Name: "dependent"},
+		DependsOn:           []string{succeededId, runningId},
+		OnDependencyFailure: DependencySkip,
+	})
+
+	assert.Equal(t, succeededId, queue.Pop().Id)
+	queue.Complete(succeededId, nil)
+	assert.Equal(t, runningId, queue.Pop().Id)
+	queue.Stop()
+
+	restored := openPersistentQueue(t, path)
+	defer restored.Stop()
+
+	// succeeded dependency is gone, the dependent still waits for the other one
+	info := restored.Get(dependentId)
+	assert.Equal(t, TaskBlocked, info.State)
+	assert.DeepEqual(t, []string{runningId}, info.BlockedOn)
+
+	assert.Equal(t, runningId, restored.Pop().Id)
+	restored.Complete(runningId, errors.New("failed on purpose"))
+	assert.Equal(t, TaskSkipped, restored.Get(dependentId).State)
+}
+
+func TestDependencyFailurePolicyJSON(t *testing.T) {
+	spec := TaskSpec{Type: "stored", DependsOn: []string{"a"}, OnDependencyFailure: DependencySkip}
+	data, err := json.Marshal(spec)
+	assert.NilError(t, err)
+	assert.Equal(t, `{"type":"stored","dependsOn":["a"],"onDependencyFailure":"skip"}`, string(data))
+
+	parsed := TaskSpec{}
+	assert.NilError(t, json.Unmarshal(data, &parsed))
+	assert.DeepEqual(t, spec, parsed)
+
+	assert.Check(t, json.Unmarshal([]byte(`{"onDependencyFailure":"retry"}`), &parsed) != nil)
+}
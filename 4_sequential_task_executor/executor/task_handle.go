@@ -0,0 +1,61 @@
+package executor
+
+import "context"
+
+// TaskHandle is returned by `Submit` and lets the caller wait for a specific task to finish. It's finished
+// once the task reaches a final state - succeeded, failed (after all retries) or cancelled.
+type TaskHandle struct {
+	id   string
+	done chan struct{}
+
+	// Snapshot of the task taken when it finished, written before `done` is closed
+	info TaskInfo
+}
+
+func newTaskHandle(id string) *TaskHandle {
+	return &TaskHandle{id: id, done: make(chan struct{})}
+}
+
+// NewTaskHandle creates handle of a task tracked outside of this package's queues, e.g. by a remote one.
+// Returned function finishes the handle, it must be called exactly once.
+func NewTaskHandle(id string) (*TaskHandle, func(info TaskInfo)) {
+	handle := newTaskHandle(id)
+	return handle, handle.finish
+}
+
+func (h *TaskHandle) Id() string {
+	return h.id
+}
+
+// Done returns a channel which is closed once the task has finished
+func (h *TaskHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Wait blocks until the task finishes and returns its error (nil if it succeeded), or the context error if
+// the context is done first. Waiting doesn't affect the task in any way, use `Cancel` to stop it.
+func (h *TaskHandle) Wait(ctx context.Context) error {
+	select {
+	case <-h.done:
+		return h.info.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Info returns the task as it was when it finished. Unlike `Get`, it's available even after the queue
+// forgets the task. Zero value until `Done` is closed
+func (h *TaskHandle) Info() TaskInfo {
+	select {
+	case <-h.done:
+		return h.info
+	default:
+		return TaskInfo{}
+	}
+}
+
+// Called by the ledger exactly once, when the task reaches a final state
+func (h *TaskHandle) finish(info TaskInfo) {
+	h.info = info
+	close(h.done)
+}
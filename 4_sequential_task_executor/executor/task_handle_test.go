@@ -0,0 +1,81 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"gotest.tools/assert"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTaskHandle(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor(WithFinishedTaskRetention(0), WithDeadLetterRetention(0))
+			defer executor.Stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			succeeding, err := queue.Submit(Task{TaskExecutable: &flakyExecutable{}})
+			assert.NilError(t, err)
+			assert.NilError(t, succeeding.Wait(ctx))
+			assert.Equal(t, TaskSucceeded, succeeding.Info().State)
+			assert.Equal(t, succeeding.Id(), succeeding.Info().Id)
+			// handle outlives the queue's memory of the task
+			assert.Check(t, queue.Get(succeeding.Id()) == nil)
+
+			// handle is finished only after the last attempt
+			retried, err := queue.Submit(Task{
+				TaskExecutable: &flakyExecutable{failures: 5},
+				Retry:          &RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond},
+			})
+			assert.NilError(t, err)
+			assert.Check(t, errors.Is(retried.Wait(ctx), errFlaky))
+			assert.Equal(t, TaskFailed, retried.Info().State)
+			assert.Equal(t, 3, retried.Info().Attempts)
+		})
+	}
+}
+
+func TestTaskHandleOfCancelledTask(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			handle, err := queue.Submit(Task{TaskExecutable: &flakyExecutable{}})
+			assert.NilError(t, err)
+
+			// nobody pops the task, waiting gives up with the context
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			assert.Equal(t, context.DeadlineExceeded, handle.Wait(ctx))
+			assert.Check(t, reflect.DeepEqual(TaskInfo{}, handle.Info()))
+
+			assert.Check(t, queue.Cancel(handle.Id()))
+
+			select {
+			case <-handle.Done():
+			case <-time.After(5 * time.Second):
+				t.Fatal("handle of the cancelled task is not done")
+			}
+			assert.Equal(t, TaskCancelled, handle.Info().State)
+			assert.Equal(t, context.Canceled, handle.Wait(context.Background()))
+		})
+	}
+}
+
+func TestSubmitToClosedQueue(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			queue.Stop()
+
+			handle, err := queue.Submit(Task{TaskExecutable: &flakyExecutable{}})
+			assert.Equal(t, ErrQueueClosed, err)
+			assert.Check(t, handle == nil)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package executor
+
+import (
+	"errors"
+	"gotest.tools/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIdempotentPush(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			id := mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, IdempotencyKey: "order-42"})
+			otherId := mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, IdempotencyKey: "order-43"})
+			assert.Check(t, id != otherId)
+			assert.Equal(t, "order-42", queue.Get(id).IdempotencyKey)
+
+			// queued and running tasks are found by the key
+			assert.Equal(t, id, mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, IdempotencyKey: "order-42"}))
+			assert.Equal(t, id, queue.Pop().Id)
+			handle, err := queue.Submit(Task{TaskExecutable: &ExecutableQuickie{}, IdempotencyKey: "order-42"})
+			assert.NilError(t, err)
+			assert.Equal(t, id, handle.Id())
+			assert.Equal(t, 2, len(queue.List()))
+
+			// without a window the key is forgotten once the task finishes
+			queue.Complete(id, nil)
+			assert.Check(t, mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, IdempotencyKey: "order-42"}) != id)
+			assert.Equal(t, 3, len(queue.List()))
+		})
+	}
+}
+
+func TestIdempotencyWindow(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			window := 50 * time.Millisecond
+			queue := constructor.newQueue(WithIdempotencyWindow(window))
+			defer queue.Stop()
+
+			id := mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, IdempotencyKey: "order-42"})
+			queue.Pop()
+			queue.Complete(id, nil)
+
+			// finished task is remembered within the window
+			handle, err := queue.Submit(Task{TaskExecutable: &ExecutableQuickie{}, IdempotencyKey: "order-42"})
+			assert.NilError(t, err)
+			assert.Equal(t, id, handle.Id())
+			assert.Equal(t, TaskSucceeded, handle.Info().State)
+
+			time.Sleep(2 * window)
+			assert.Check(t, mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, IdempotencyKey: "order-42"}) != id)
+		})
+	}
+}
+
+func TestIdempotentPushToFullQueue(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy OverflowPolicy
+	}{
+		{name: "block", policy: OverflowBlock},
+		{name: "reject", policy: OverflowReject},
+		{name: "drop newest", policy: OverflowDropNewest},
+	}
+
+	for _, test := range tests {
+		for _, constructor := range queueConstructors {
+			t.Run(test.name+" "+constructor.name, func(t *testing.T) {
+				queue := constructor.newQueue(WithCapacity(1, test.policy))
+				defer queue.Stop()
+
+				// duplicate doesn't need any space, it's neither blocked nor rejected
+				id := mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, IdempotencyKey: "order-42"})
+				assert.Equal(t, id, mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, IdempotencyKey: "order-42"}))
+				assert.Equal(t, TaskQueued, queue.Get(id).State)
+				assert.Equal(t, uint64(0), queue.Stats().Rejected)
+			})
+		}
+	}
+}
+
+func TestRequeuedTaskKeepsIdempotencyKey(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			id := mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, IdempotencyKey: "order-42"})
+			queue.Pop()
+			queue.Complete(id, errors.New("failed on purpose"))
+
+			assert.NilError(t, queue.Requeue(id))
+			assert.Equal(t, id, mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, IdempotencyKey: "order-42"}))
+		})
+	}
+}
+
+func TestKeyedExecutorIdempotentPush(t *testing.T) {
+	for _, constructor := range keyedExecutorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor(WithLanes(4), WithIdempotencyWindow(time.Minute))
+			defer executor.Stop()
+
+			// tasks without partition key still end up in the same lane
+			id := mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, IdempotencyKey: "order-42"})
+			for i := 0; i < 4; i++ {
+				assert.Equal(t, id, mustPush(t, queue, Task{TaskExecutable: &ExecutableQuickie{}, IdempotencyKey: "order-42"}))
+			}
+			assert.Equal(t, 1, len(queue.List()))
+		})
+	}
+}
+
+func TestPersistentTaskQueueRestoresIdempotencyKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.log")
+	queue := openPersistentQueue(t, path)
+	id := mustPush(t, queue, Task{TaskExecutable: &storedExecutable{Name: "queued"}, IdempotencyKey: "order-42"})
+	queue.Stop()
+
+	restored := openPersistentQueue(t, path)
+	defer restored.Stop()
+	assert.Equal(t, id, mustPush(t, restored, Task{TaskExecutable: &storedExecutable{Name: "queued"}, IdempotencyKey: "order-42"}))
+	assert.Equal(t, 1, len(restored.List()))
+}
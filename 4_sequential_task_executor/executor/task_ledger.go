@@ -0,0 +1,866 @@
+package executor
+
+import (
+	"AwesomePresentation/logging"
+	"AwesomePresentation/metrics"
+	"AwesomePresentation/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"runtime/debug"
+	"time"
+)
+
+// taskRecord is everything the queue knows about a single task - the task itself (so it can be handed
+// over to the executor) and the information exposed through `Get` and `List`.
+type taskRecord struct {
+	task Task
+	info TaskInfo
+
+	// Finished once the task reaches a final state
+	handle *TaskHandle
+
+	// Cancels context of the running task. Nil unless the task is running
+	cancel          context.CancelFunc
+	cancelRequested bool
+
+	// When the blocked task is going to be due, applied once its dependencies succeed
+	dueAt time.Time
+
+	// Traces the time the task waits to run (queued, scheduled or blocked), nil unless it's waiting
+	waitSpan trace.Span
+}
+
+// taskLedger holds the bookkeeping shared by both queue implementations. It is NOT thread safe on purpose:
+// `taskQueue` only touches it from its receiver goroutine and `lockingTaskQueue` only while holding its lock.
+type taskLedger struct {
+	// Tasks awaiting execution, decides in which order they are going to be popped
+	pending pendingTasks
+
+	// Tasks pushed with a due time, moved to `pending` once they are due
+	scheduled *scheduledTasks
+
+	// Every task the queue still knows about (queued, running and retained finished ones)
+	records map[string]*taskRecord
+
+	// Ids of all tracked tasks in order of submission, keeps `List()` stable
+	order []string
+
+	// Ids of finished tasks, the oldest first. Bounded by `finishedRetention`
+	finished          []string
+	finishedRetention int
+
+	// Ids of failed and timed out tasks, the oldest first. Bounded by `deadLetterRetention`
+	deadLetters         []string
+	deadLetterRetention int
+
+	// Number of popped tasks that didn't complete yet
+	running int
+
+	// Task which holds the idempotency key, as long as it's unfinished or finished within `idempotencyWindow`
+	idempotencyKeys   map[string]*taskRecord
+	idempotencyWindow time.Duration
+	// Finished tasks with idempotency key, the earliest finished first. Their keys expire in this order
+	idempotencyExpiry []*taskRecord
+
+	// Blocked tasks waiting for the task with given id, see `Task.DependsOn`
+	dependents map[string][]*taskRecord
+	// Number of blocked tasks
+	blocked int
+
+	// Limits number of waiting (queued, scheduled and blocked) tasks, zero means unbounded. See `OverflowPolicy`
+	capacity       int
+	overflowPolicy OverflowPolicy
+	// Closed once a waiting task leaves the queue (or the queue closes), blocked pushes wait for it
+	spaceFreed chan struct{}
+	rejected   uint64
+	dropped    uint64
+
+	// Closed ledger doesn't accept new tasks, `drained` is closed once it has no queued nor running tasks
+	closed  bool
+	drained chan struct{}
+
+	// Records changes which have to survive a restart, see `writeAheadLog`
+	journal taskJournal
+
+	// Receive every change of task state, see `TaskQueue.Subscribe`
+	subscriptions       map[*taskSubscription]struct{}
+	subscriptionsClosed bool
+	// Closed together with the subscriptions, before any of them. Safe to read without the queue's protection
+	publishingDone chan struct{}
+
+	logger logging.Logger
+	// How long tasks waited before they started, see `WithMetrics`
+	waitTime *metrics.Histogram
+	// Set by the queue owning the ledger, called once it stops. See `registerQueueMetrics`
+	unregisterMetrics func()
+	tracer            trace.Tracer
+	// Only `Hooks.OnEnqueue` is called by the ledger, executors call the others
+	hooks []Hooks
+}
+
+// taskJournal is told about every change of the ledger which a restarted queue needs to know about.
+// Tasks cancelled because the queue closes are not reported - they are still pending for the next process.
+type taskJournal interface {
+	// Called before the task is accepted (pushed or requeued), error rejects it
+	enqueued(task Task, dueAt time.Time) error
+
+	started(id string)
+
+	// Called once the task finishes, or when it's scheduled for a retry
+	completed(info TaskInfo)
+}
+
+// nopJournal is used by queues which keep everything in memory
+type nopJournal struct{}
+
+func (nopJournal) enqueued(task Task, dueAt time.Time) error { return nil }
+func (nopJournal) started(id string)                         {}
+func (nopJournal) completed(info TaskInfo)                   {}
+
+func newTaskLedger(cfg config) *taskLedger {
+	var pending pendingTasks = newFifoPendingTasks()
+	if cfg.priorityOrdering {
+		pending = newPriorityPendingTasks(cfg.priorityAgingInterval)
+	}
+
+	return &taskLedger{
+		pending:             pending,
+		scheduled:           newScheduledTasks(),
+		records:             make(map[string]*taskRecord),
+		order:               make([]string, 0),
+		finished:            make([]string, 0),
+		finishedRetention:   cfg.finishedTaskRetention,
+		deadLetters:         make([]string, 0),
+		deadLetterRetention: cfg.deadLetterRetention,
+		dependents:          make(map[string][]*taskRecord),
+		idempotencyKeys:     make(map[string]*taskRecord),
+		idempotencyWindow:   cfg.idempotencyWindow,
+		capacity:            cfg.capacity,
+		overflowPolicy:      cfg.overflowPolicy,
+		journal:             nopJournal{},
+		subscriptions:       make(map[*taskSubscription]struct{}),
+		publishingDone:      make(chan struct{}),
+		logger:              cfg.logger,
+		waitTime:            newTaskWaitHistogram(cfg.metrics),
+		tracer:              cfg.tracerProvider.Tracer(tracerName),
+		hooks:               cfg.hooks,
+	}
+}
+
+// Adds the task to the queue. Task with `dueAt` in the future is kept aside until it's due.
+// Full queue applies its overflow policy, except for `OverflowBlock` - callers wait for space, see `waitForSpace`.
+// Task with the idempotency key of a known task is not added, handle of the known one is returned instead.
+func (l *taskLedger) enqueue(task Task, dueAt time.Time) (*TaskHandle, error) {
+	if l.closed {
+		return nil, ErrQueueClosed
+	}
+	if handle := l.duplicate(task); handle != nil {
+		return handle, nil
+	}
+	for _, dependencyId := range task.DependsOn {
+		if _, found := l.records[dependencyId]; !found {
+			return nil, fmt.Errorf("%w %v", ErrUnknownDependency, dependencyId)
+		}
+	}
+
+	dropped := false
+	if l.full() {
+		switch l.overflowPolicy {
+		case OverflowDropOldest:
+			l.dropOldest()
+		case OverflowDropNewest:
+			dropped = true
+		default:
+			l.rejected++
+			return nil, ErrQueueFull
+		}
+	}
+
+	generatedTaskId, err := uuid.NewRandom()
+	if err != nil {
+		l.logger.Error("failed to generate uuid for task", logging.Err(err))
+	}
+
+	copiedTask := task
+	copiedTask.Id = generatedTaskId.String()
+	// the caller might reuse the slice
+	copiedTask.DependsOn = append([]string(nil), task.DependsOn...)
+
+	if dropped {
+		// never going to run, there is nothing to journal
+		record := l.track(copiedTask)
+		l.dropped++
+		l.finish(record, TaskDropped, ErrTaskDropped)
+		return record.handle, nil
+	}
+
+	if err := l.journal.enqueued(copiedTask, dueAt); err != nil {
+		return nil, err
+	}
+
+	record := l.add(copiedTask, dueAt)
+	l.enqueued(record.task)
+	return record.handle, nil
+}
+
+// Calls `Hooks.OnEnqueue`, panicking hook is logged - the task has been accepted anyway
+func (l *taskLedger) enqueued(task Task) {
+	for _, hooks := range l.hooks {
+		if hooks.OnEnqueue == nil {
+			continue
+		}
+
+		func() {
+			defer (func() {
+				if panic := recover(); panic != nil {
+					l.logger.Error("task hook panicked", logging.TaskId(task.Id), logging.Any("panic", panic),
+						logging.Any("stack", string(debug.Stack())))
+				}
+			})()
+			hooks.OnEnqueue(task)
+		}()
+	}
+}
+
+// Adds task which was accepted before the queue restarted, under its original id
+func (l *taskLedger) restore(task Task, dueAt time.Time, attempts int) {
+	record := l.add(task, dueAt)
+	record.info.Attempts = attempts
+}
+
+func (l *taskLedger) add(task Task, dueAt time.Time) *taskRecord {
+	record := l.track(task)
+	record.dueAt = dueAt
+	l.startWaiting(record)
+	l.awaitDependencies(record)
+
+	return record
+}
+
+// Blocks the task until its dependencies succeed, or releases it right away when they already did. Dependencies
+// which are not known anymore are considered succeeded - pushes reject unknown ones, and a restored task
+// only outlives its dependencies if they succeeded (dependents of a failed one fail together with it).
+func (l *taskLedger) awaitDependencies(record *taskRecord) {
+	blockedOn := make([]string, 0)
+	for _, dependencyId := range record.task.DependsOn {
+		dependency, found := l.records[dependencyId]
+		if !found || dependency.info.State == TaskSucceeded {
+			continue
+		}
+		if dependency.info.State.IsFinished() {
+			l.failDependent(record, dependency.info)
+			return
+		}
+
+		blockedOn = append(blockedOn, dependencyId)
+		l.dependents[dependencyId] = append(l.dependents[dependencyId], record)
+	}
+
+	if len(blockedOn) > 0 {
+		record.info.State = TaskBlocked
+		record.info.BlockedOn = blockedOn
+		l.blocked++
+		l.publish(record.info)
+		return
+	}
+	l.release(record)
+}
+
+// Makes the task queued, or scheduled if it isn't due yet
+func (l *taskLedger) release(record *taskRecord) {
+	record.info.BlockedOn = nil
+	if record.dueAt.After(time.Now()) {
+		record.info.State = TaskScheduled
+		record.info.DueAt = record.dueAt
+		l.scheduled.push(record, record.dueAt)
+	} else {
+		record.info.State = TaskQueued
+		l.pending.push(record)
+	}
+	l.publish(record.info)
+}
+
+// Updates tasks blocked by the finished one - they are released once it was their last unfinished dependency,
+// or fail (or are skipped) right away when it didn't succeed
+func (l *taskLedger) resolveDependents(dependency TaskInfo) {
+	dependents := l.dependents[dependency.Id]
+	delete(l.dependents, dependency.Id)
+
+	for _, record := range dependents {
+		if record.info.State != TaskBlocked {
+			// cancelled, dropped or failed because of another dependency meanwhile
+			continue
+		}
+		if dependency.State != TaskSucceeded {
+			l.failDependent(record, dependency)
+			continue
+		}
+
+		// info might have been handed out already, so the slice is never modified in place
+		blockedOn := make([]string, 0, len(record.info.BlockedOn))
+		for _, id := range record.info.BlockedOn {
+			if id != dependency.Id {
+				blockedOn = append(blockedOn, id)
+			}
+		}
+		if len(blockedOn) > 0 {
+			record.info.BlockedOn = blockedOn
+			l.publish(record.info)
+			continue
+		}
+		l.blocked--
+		l.release(record)
+	}
+}
+
+// Finishes the task whose dependency didn't succeed, according to its `DependencyFailurePolicy`
+func (l *taskLedger) failDependent(record *taskRecord, dependency TaskInfo) {
+	if record.info.State == TaskBlocked {
+		l.blocked--
+	}
+	record.info.BlockedOn = nil
+
+	state := TaskFailed
+	if record.task.OnDependencyFailure == DependencySkip {
+		state = TaskSkipped
+	}
+	l.finish(record, state, &DependencyError{Id: dependency.Id, State: dependency.State})
+
+	// dependency cancelled by closing the queue is not journaled either, it's restored with its dependents
+	if !l.closed || dependency.State != TaskCancelled {
+		l.journal.completed(record.info)
+	}
+}
+
+// Starts tracking the task as queued, it's up to the caller to make it pending (or scheduled)
+func (l *taskLedger) track(task Task) *taskRecord {
+	record := &taskRecord{
+		task: task,
+		info: TaskInfo{
+			Id:             task.Id,
+			State:          TaskQueued,
+			Priority:       task.Priority,
+			PartitionKey:   task.PartitionKey,
+			IdempotencyKey: task.IdempotencyKey,
+			EnqueuedAt:     time.Now(),
+			DependsOn:      task.DependsOn,
+		},
+		handle: newTaskHandle(task.Id),
+	}
+
+	l.records[task.Id] = record
+	l.order = append(l.order, task.Id)
+	if task.IdempotencyKey != "" {
+		l.idempotencyKeys[task.IdempotencyKey] = record
+	}
+	return record
+}
+
+// Returns handle of the task holding the idempotency key of the pushed one, nil if there is none
+func (l *taskLedger) duplicate(task Task) *TaskHandle {
+	if task.IdempotencyKey == "" {
+		return nil
+	}
+
+	l.expireIdempotencyKeys()
+	if record, found := l.idempotencyKeys[task.IdempotencyKey]; found {
+		return record.handle
+	}
+	return nil
+}
+
+// Forgets keys of tasks which finished more than `idempotencyWindow` ago
+func (l *taskLedger) expireIdempotencyKeys() {
+	expiredBefore := time.Now().Add(-l.idempotencyWindow)
+	for len(l.idempotencyExpiry) > 0 {
+		record := l.idempotencyExpiry[0]
+		if record.info.State.IsFinished() && record.info.FinishedAt.After(expiredBefore) {
+			return
+		}
+
+		l.idempotencyExpiry = l.idempotencyExpiry[1:]
+		// requeued task holds the key again, it's going to expire once it finishes
+		if record.info.State.IsFinished() && l.idempotencyKeys[record.task.IdempotencyKey] == record {
+			delete(l.idempotencyKeys, record.task.IdempotencyKey)
+		}
+	}
+}
+
+// Starts tracing the time until the task runs (or finishes without running)
+func (l *taskLedger) startWaiting(record *taskRecord) {
+	ctx := trace.ContextWithSpanContext(context.Background(), record.task.TraceContext)
+	_, record.waitSpan = l.tracer.Start(ctx, "executor.wait", trace.WithAttributes(
+		tracing.TaskId(record.info.Id),
+		attribute.Int("task.attempt", record.info.Attempts+1),
+		attribute.Int("task.priority", record.info.Priority),
+	))
+}
+
+// Ends the wait of the task, err is set when it finished without running
+func (l *taskLedger) stopWaiting(record *taskRecord, err error) {
+	if record.waitSpan == nil {
+		return
+	}
+
+	if err != nil {
+		record.waitSpan.SetAttributes(attribute.String("task.state", record.info.State.String()))
+		record.waitSpan.SetStatus(codes.Error, err.Error())
+	}
+	record.waitSpan.End()
+	record.waitSpan = nil
+}
+
+// Moves scheduled tasks which are due to the pending ones
+func (l *taskLedger) promoteDue() {
+	now := time.Now()
+	for record := l.scheduled.popDue(now); record != nil; record = l.scheduled.popDue(now) {
+		record.info.State = TaskQueued
+		l.pending.push(record)
+		l.publish(record.info)
+	}
+}
+
+// Returns when the next scheduled task is due, false if there is none
+func (l *taskLedger) nextDue() (time.Time, bool) {
+	return l.scheduled.nextDue()
+}
+
+// Takes the next pending task and marks it as running on the worker (zero if it wasn't popped by an executor).
+// Returns nil if there is nothing to run.
+func (l *taskLedger) pop(workerId int) *Task {
+	l.promoteDue()
+
+	record := l.pending.pop()
+	if record == nil {
+		return nil
+	}
+
+	record.info.State = TaskRunning
+	record.info.StartedAt = time.Now()
+	// retried and scheduled tasks wait since they were due, not since they were pushed
+	readyAt := record.info.EnqueuedAt
+	if record.info.DueAt.After(readyAt) {
+		readyAt = record.info.DueAt
+	}
+	l.waitTime.Observe(record.info.StartedAt.Sub(readyAt).Seconds())
+	l.stopWaiting(record, nil)
+	record.info.Attempts++
+	record.info.Worker = workerId
+	l.running++
+	l.notifySpaceFreed()
+	l.journal.started(record.info.Id)
+	l.publish(record.info)
+
+	poppedTask := record.task
+	ctx := withTaskId(context.Background(), record.info.Id)
+	// executable logs with the task attached, see `WithLogger`
+	ctx = logging.NewContext(ctx, l.logger.With(logging.TaskId(record.info.Id)))
+	// executor traces the execution as part of the task's trace
+	ctx = trace.ContextWithSpanContext(ctx, record.task.TraceContext)
+	poppedTask.ctx, record.cancel = context.WithCancel(withWorker(ctx, workerId))
+	return &poppedTask
+}
+
+// Moves running task to its final state, based on the error returned by the executable.
+func (l *taskLedger) complete(id string, err error) {
+	record, found := l.records[id]
+	if !found || record.info.State != TaskRunning {
+		l.logger.Warn("completed task is not running, ignoring", logging.TaskId(id))
+		return
+	}
+
+	// release resources of the task's context
+	record.cancel()
+	record.cancel = nil
+	l.running--
+
+	if err != nil {
+		record.info.LastError = err
+	}
+
+	// panic means there is something seriously wrong with the task, it's not retried
+	var panicErr *PanicError
+	panicked := errors.As(err, &panicErr)
+	if panicked {
+		record.info.Stack = panicErr.Stack
+	}
+
+	switch {
+	case record.cancelRequested && err != nil && !panicked:
+		// executable might fail its own way once cancelled (plain executables never see the context)
+		l.finish(record, TaskCancelled, err)
+	case errors.Is(err, errExecutionAbandoned):
+		// abandoned execution might still be running, retry would run the executable twice at once
+		l.finish(record, TaskTimedOut, err)
+	case err != nil && !panicked && !l.closed && !record.cancelRequested &&
+		record.task.Retry.shouldRetry(record.info.Attempts, err):
+		l.retry(record)
+	case errors.Is(err, ErrTaskTimedOut):
+		l.finish(record, TaskTimedOut, err)
+	case err != nil:
+		l.finish(record, TaskFailed, err)
+	default:
+		if provider, ok := record.task.TaskExecutable.(resultProvider); ok {
+			record.info.Result = provider.taskResult()
+		}
+		l.finish(record, TaskSucceeded, nil)
+	}
+	l.journal.completed(record.info)
+}
+
+// Schedules another attempt of the failed task, once its backoff passes
+func (l *taskLedger) retry(record *taskRecord) {
+	dueAt := time.Now().Add(record.task.Retry.backoff(record.info.Attempts))
+	l.logger.Info("task failed, retrying", logging.TaskId(record.info.Id),
+		logging.Any("attempt", record.info.Attempts), logging.Any("dueAt", dueAt), logging.Err(record.info.LastError))
+
+	record.cancelRequested = false
+	record.info.State = TaskScheduled
+	record.info.DueAt = dueAt
+	l.scheduled.push(record, dueAt)
+	l.startWaiting(record)
+	l.publish(record.info)
+
+	// the task isn't running anymore
+	l.checkDrained()
+}
+
+// Removes queued task from the queue, or asks the running one to stop. Returns false if the task
+// is unknown or already finished. Running task stays running until the executor completes it.
+func (l *taskLedger) cancelTask(id string) bool {
+	l.promoteDue()
+
+	record, found := l.records[id]
+	if !found {
+		return false
+	}
+
+	switch record.info.State {
+	case TaskScheduled:
+		l.scheduled.remove(record)
+		l.finish(record, TaskCancelled, context.Canceled)
+		l.journal.completed(record.info)
+		return true
+	case TaskQueued:
+		l.pending.remove(record)
+		l.finish(record, TaskCancelled, context.Canceled)
+		l.journal.completed(record.info)
+		return true
+	case TaskBlocked:
+		l.blocked--
+		record.info.BlockedOn = nil
+		l.finish(record, TaskCancelled, context.Canceled)
+		l.journal.completed(record.info)
+		return true
+	case TaskRunning:
+		record.cancelRequested = true
+		record.cancel()
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *taskLedger) finish(record *taskRecord, state TaskState, err error) {
+	switch record.info.State {
+	case TaskQueued, TaskScheduled, TaskBlocked:
+		l.notifySpaceFreed()
+	}
+
+	record.info.State = state
+	record.info.FinishedAt = time.Now()
+	record.info.Error = err
+	// task which finished without running, e.g. cancelled one
+	l.stopWaiting(record, err)
+
+	if key := record.task.IdempotencyKey; key != "" {
+		if l.idempotencyWindow > 0 {
+			l.idempotencyExpiry = append(l.idempotencyExpiry, record)
+		} else if l.idempotencyKeys[key] == record {
+			delete(l.idempotencyKeys, key)
+		}
+	}
+
+	if state == TaskFailed || state == TaskTimedOut {
+		// failed tasks are kept aside, so they can be inspected and requeued
+		record.info.DeadLettered = true
+		l.deadLetters = append(l.deadLetters, record.info.Id)
+		l.evictDeadLetters()
+	} else {
+		l.finished = append(l.finished, record.info.Id)
+		l.evictFinished()
+	}
+	record.handle.finish(record.info)
+	l.publish(record.info)
+	l.resolveDependents(record.info)
+	l.checkDrained()
+}
+
+// Moves dead-lettered task back to the queue. Task gets a fresh set of attempts, its last error is kept.
+// Handles returned before stay finished - they describe the failed run.
+func (l *taskLedger) requeue(id string) error {
+	if l.closed {
+		return ErrQueueClosed
+	}
+
+	record, found := l.records[id]
+	if !found || !record.info.DeadLettered {
+		return ErrNotDeadLettered
+	}
+	if l.full() {
+		l.rejected++
+		return ErrQueueFull
+	}
+	if err := l.journal.enqueued(record.task, time.Time{}); err != nil {
+		return err
+	}
+
+	removeId(&l.deadLetters, id)
+	record.info = TaskInfo{
+		Id:             record.info.Id,
+		State:          TaskQueued,
+		Priority:       record.info.Priority,
+		PartitionKey:   record.info.PartitionKey,
+		IdempotencyKey: record.info.IdempotencyKey,
+		EnqueuedAt:     time.Now(),
+		LastError:      record.info.LastError,
+		DependsOn:      record.info.DependsOn,
+	}
+	if key := record.task.IdempotencyKey; key != "" {
+		// unless a newer task has taken the key meanwhile
+		if holder, found := l.idempotencyKeys[key]; !found || holder.info.State.IsFinished() {
+			l.idempotencyKeys[key] = record
+		}
+	}
+	record.cancelRequested = false
+	record.dueAt = time.Time{}
+	record.handle = newTaskHandle(id)
+	l.startWaiting(record)
+	// dependencies which have been requeued as well block the task again
+	l.awaitDependencies(record)
+
+	return nil
+}
+
+func (l *taskLedger) listDeadLetters() []TaskInfo {
+	deadLettersCopy := make([]TaskInfo, 0, len(l.deadLetters))
+	for _, id := range l.deadLetters {
+		deadLettersCopy = append(deadLettersCopy, l.records[id].info)
+	}
+
+	return deadLettersCopy
+}
+
+// Stops accepting new tasks. Without `drain` queued (and blocked) tasks are cancelled, otherwise they remain.
+// Scheduled tasks which are not due yet are always cancelled, shutdown would have to wait for them otherwise.
+// Returned channel is closed once there are no queued, blocked nor running tasks left.
+func (l *taskLedger) close(drain bool) <-chan struct{} {
+	l.promoteDue()
+	if !l.closed {
+		l.closed = true
+		l.drained = make(chan struct{})
+		// blocked pushes are going to fail now
+		l.notifySpaceFreed()
+	}
+
+	for _, record := range l.scheduled.clear() {
+		l.finish(record, TaskCancelled, context.Canceled)
+	}
+
+	if !drain {
+		l.cancelPending()
+	}
+	l.checkDrained()
+
+	return l.drained
+}
+
+// Closes the ledger and gives up on every task - queued ones are cancelled, running ones have their context
+// cancelled and are marked as cancelled right away, since nobody is going to wait for them to complete.
+func (l *taskLedger) abort() {
+	l.close(false)
+
+	// finishing tasks might evict old ones from `order`, so running tasks are collected first
+	running := make([]*taskRecord, 0, l.running)
+	for _, id := range l.order {
+		if record := l.records[id]; record.info.State == TaskRunning {
+			running = append(running, record)
+		}
+	}
+
+	for _, record := range running {
+		record.cancelRequested = true
+		record.cancel()
+		record.cancel = nil
+		l.running--
+		l.finish(record, TaskCancelled, context.Canceled)
+	}
+}
+
+func (l *taskLedger) cancelPending() {
+	for _, record := range l.pending.clear() {
+		l.finish(record, TaskCancelled, context.Canceled)
+	}
+
+	// finishing tasks might evict old ones from `order`, so blocked tasks are collected first
+	blocked := make([]*taskRecord, 0, l.blocked)
+	for _, id := range l.order {
+		if record := l.records[id]; record.info.State == TaskBlocked {
+			blocked = append(blocked, record)
+		}
+	}
+	for _, record := range blocked {
+		if record.info.State != TaskBlocked {
+			// failed because of a dependency cancelled just now
+			continue
+		}
+		l.blocked--
+		record.info.BlockedOn = nil
+		l.finish(record, TaskCancelled, context.Canceled)
+	}
+}
+
+func (l *taskLedger) checkDrained() {
+	if !l.closed || l.pending.len() > 0 || l.running > 0 || l.blocked > 0 {
+		return
+	}
+
+	select {
+	case <-l.drained:
+		// already closed
+	default:
+		close(l.drained)
+		l.stopped()
+	}
+}
+
+// Forgets the oldest finished tasks, so that at most `finishedRetention` of them are kept.
+func (l *taskLedger) evictFinished() {
+	for len(l.finished) > l.finishedRetention {
+		evictedId := l.finished[0]
+		l.finished = l.finished[1:]
+		l.forget(evictedId)
+	}
+}
+
+// Forgets the oldest dead letters, so that at most `deadLetterRetention` of them are kept.
+func (l *taskLedger) evictDeadLetters() {
+	for len(l.deadLetters) > l.deadLetterRetention {
+		evictedId := l.deadLetters[0]
+		l.deadLetters = l.deadLetters[1:]
+		l.forget(evictedId)
+	}
+}
+
+func (l *taskLedger) forget(id string) {
+	delete(l.records, id)
+	removeId(&l.order, id)
+}
+
+// Removes the first occurrence of the id from the slice
+func removeId(ids *[]string, id string) {
+	for index, candidate := range *ids {
+		if candidate == id {
+			*ids = append((*ids)[:index], (*ids)[index+1:]...)
+			return
+		}
+	}
+}
+
+func (l *taskLedger) list() []TaskInfo {
+	l.promoteDue()
+
+	tasksCopy := make([]TaskInfo, 0, len(l.order))
+	for _, id := range l.order {
+		tasksCopy = append(tasksCopy, l.records[id].info)
+	}
+
+	return tasksCopy
+}
+
+func (l *taskLedger) get(id string) *TaskInfo {
+	l.promoteDue()
+
+	record, found := l.records[id]
+	if !found {
+		return nil
+	}
+
+	infoCopy := record.info
+	return &infoCopy
+}
+
+// Number of tasks waiting in the queue - queued, scheduled and blocked ones
+func (l *taskLedger) waiting() int {
+	return l.pending.len() + l.scheduled.len() + l.blocked
+}
+
+func (l *taskLedger) full() bool {
+	return l.capacity > 0 && l.waiting() >= l.capacity
+}
+
+// Drops the waiting task which was pushed first, to make space for a new one
+func (l *taskLedger) dropOldest() {
+	for _, id := range l.order {
+		record := l.records[id]
+		switch record.info.State {
+		case TaskQueued:
+			l.pending.remove(record)
+		case TaskScheduled:
+			l.scheduled.remove(record)
+		case TaskBlocked:
+			l.blocked--
+			record.info.BlockedOn = nil
+		default:
+			continue
+		}
+
+		l.dropped++
+		l.finish(record, TaskDropped, ErrTaskDropped)
+		l.journal.completed(record.info)
+		return
+	}
+}
+
+// Returns channel which is closed once a push might succeed, true if the push has to wait for it - that is
+// when the queue is full and its policy is `OverflowBlock`. The caller should check again after waiting,
+// other pushes might have taken the space first. Duplicates of known tasks never wait, see `duplicate`.
+func (l *taskLedger) waitForSpace(task Task) (<-chan struct{}, bool) {
+	if l.closed || l.overflowPolicy != OverflowBlock || !l.full() || l.duplicate(task) != nil {
+		return nil, false
+	}
+
+	if l.spaceFreed == nil {
+		l.spaceFreed = make(chan struct{})
+	}
+	return l.spaceFreed, true
+}
+
+func (l *taskLedger) notifySpaceFreed() {
+	if l.spaceFreed != nil {
+		close(l.spaceFreed)
+		l.spaceFreed = nil
+	}
+}
+
+func (l *taskLedger) stats() QueueStats {
+	return QueueStats{
+		Waiting:  l.waiting(),
+		Running:  l.running,
+		Capacity: l.capacity,
+		Rejected: l.rejected,
+		Dropped:  l.dropped,
+	}
+}
+
+// Number of tasks awaiting execution (including scheduled ones which are already due)
+func (l *taskLedger) length() int {
+	l.promoteDue()
+
+	return l.pending.len()
+}
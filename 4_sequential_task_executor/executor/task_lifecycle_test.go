@@ -0,0 +1,126 @@
+package executor
+
+import (
+	"fmt"
+	"gotest.tools/assert"
+	"testing"
+	"time"
+)
+
+var queueConstructors = []struct {
+	name     string
+	newQueue func(options ...Option) TaskQueue
+}{
+	{name: "channel queue", newQueue: NewTaskQueue},
+	{name: "locking queue", newQueue: NewLockingTaskQueue},
+	{name: "priority channel queue", newQueue: NewPriorityTaskQueue},
+	{name: "priority locking queue", newQueue: NewLockingPriorityTaskQueue},
+}
+
+func TestTaskLifecycle(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			succeedingId := mustPush(t, queue, NewExecutableQuickie())
+			failingId := mustPush(t, queue, NewExecutableQuickie())
+
+			info := queue.Get(succeedingId)
+			assert.Assert(t, info != nil)
+			assert.Equal(t, TaskQueued, info.State)
+			assert.Check(t, !info.EnqueuedAt.IsZero())
+			assert.Check(t, info.StartedAt.IsZero())
+
+			popped := queue.Pop()
+			assert.Assert(t, popped != nil)
+			assert.Equal(t, succeedingId, popped.Id)
+
+			info = queue.Get(succeedingId)
+			assert.Equal(t, TaskRunning, info.State)
+			assert.Check(t, !info.StartedAt.IsZero())
+
+			queue.Complete(succeedingId, nil)
+
+			popped = queue.Pop()
+			assert.Assert(t, popped != nil)
+			assert.Equal(t, failingId, popped.Id)
+
+			executionError := fmt.Errorf("failed on purpose")
+			queue.Complete(failingId, executionError)
+
+			info = queue.Get(succeedingId)
+			assert.Equal(t, TaskSucceeded, info.State)
+			assert.Check(t, !info.FinishedAt.Before(info.StartedAt))
+			assert.NilError(t, info.Error)
+
+			info = queue.Get(failingId)
+			assert.Equal(t, TaskFailed, info.State)
+			assert.Equal(t, executionError, info.Error)
+
+			tasks := queue.List()
+			assert.Equal(t, 2, len(tasks))
+			assert.Equal(t, succeedingId, tasks[0].Id)
+			assert.Equal(t, failingId, tasks[1].Id)
+		})
+	}
+}
+
+func TestFinishedTaskRetention(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue(WithFinishedTaskRetention(2))
+			defer queue.Stop()
+
+			ids := make([]string, 0)
+			for i := 0; i < 4; i++ {
+				ids = append(ids, mustPush(t, queue, NewExecutableQuickie()))
+			}
+
+			for i := 0; i < 3; i++ {
+				popped := queue.Pop()
+				assert.Assert(t, popped != nil)
+				queue.Complete(popped.Id, nil)
+			}
+
+			// the first finished task has been forgotten, the last one was never started
+			assert.Check(t, queue.Get(ids[0]) == nil)
+			assert.Equal(t, TaskSucceeded, queue.Get(ids[1]).State)
+			assert.Equal(t, TaskSucceeded, queue.Get(ids[2]).State)
+			assert.Equal(t, TaskQueued, queue.Get(ids[3]).State)
+			assert.Equal(t, 3, len(queue.List()))
+		})
+	}
+}
+
+func TestExecutorReportsTaskOutcome(t *testing.T) {
+	queue, executor := NewLockingQueueExecutor()
+	defer executor.Stop()
+
+	id := mustPush(t, queue, NewExecutableQuickie())
+
+	info := waitForFinishedTask(queue, id, 5*time.Second)
+	assert.Assert(t, info != nil)
+	assert.Equal(t, TaskSucceeded, info.State)
+}
+
+func mustPush(t *testing.T, queue TaskQueue, task Task) string {
+	t.Helper()
+
+	id, err := queue.Push(task)
+	assert.NilError(t, err)
+	return id
+}
+
+// Polls the queue until the task finishes or timeout passes. Returns the latest known task information
+func waitForFinishedTask(queue TaskQueue, id string, timeout time.Duration) *TaskInfo {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if info := queue.Get(id); info != nil && info.State.IsFinished() {
+			return info
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return queue.Get(id)
+}
@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+)
+
+// ExecutableWithResult is an executable which hands back a value, like `ProcessingNode.Calculate`
+// in the worker pool. Wrap it with `NewTaskWithResult` to push it to a queue.
+type ExecutableWithResult[T any] interface {
+	ExecuteWithResult(ctx context.Context) (T, error)
+}
+
+// resultProvider is implemented by executables which produce a value. Ledger copies the value of the
+// successful attempt to `TaskInfo.Result`
+type resultProvider interface {
+	taskResult() interface{}
+}
+
+// resultExecutable adapts `ExecutableWithResult` to `Executable`, remembering the value of the latest attempt
+type resultExecutable[T any] struct {
+	executable ExecutableWithResult[T]
+	result     T
+}
+
+// NewTaskWithResult creates task whose value ends up in `TaskInfo.Result` once it succeeds. The result is
+// kept by the task's executable, so the returned task should be pushed only once.
+func NewTaskWithResult[T any](executable ExecutableWithResult[T]) Task {
+	return Task{
+		TaskExecutable: &resultExecutable[T]{executable: executable},
+	}
+}
+
+func (e *resultExecutable[T]) Execute() error {
+	return e.ExecuteContext(context.Background())
+}
+
+func (e *resultExecutable[T]) ExecuteContext(ctx context.Context) error {
+	result, err := e.executable.ExecuteWithResult(ctx)
+	e.result = result
+	return err
+}
+
+func (e *resultExecutable[T]) taskResult() interface{} {
+	return e.result
+}
+
+// ResultHandle is `TaskHandle` of a task created with `NewTaskWithResult`, which also returns its value
+type ResultHandle[T any] struct {
+	*TaskHandle
+}
+
+// SubmitWithResult pushes task created by `NewTaskWithResult` and returns a handle to wait for its value.
+// Fails if the task doesn't produce values of type `T`.
+func SubmitWithResult[T any](queue TaskQueue, task Task) (*ResultHandle[T], error) {
+	if _, ok := task.TaskExecutable.(*resultExecutable[T]); !ok {
+		return nil, fmt.Errorf("task executable %T doesn't produce result of type %T", task.TaskExecutable, *new(T))
+	}
+
+	handle, err := queue.Submit(task)
+	if err != nil {
+		return nil, err
+	}
+	return &ResultHandle[T]{TaskHandle: handle}, nil
+}
+
+// Result blocks until the task finishes and returns its value. Returns the task error (and zero value)
+// if it didn't succeed, or the context error if the context is done first.
+func (h *ResultHandle[T]) Result(ctx context.Context) (T, error) {
+	var result T
+	if err := h.Wait(ctx); err != nil {
+		return result, err
+	}
+
+	result, _ = h.Info().Result.(T)
+	return result, nil
+}
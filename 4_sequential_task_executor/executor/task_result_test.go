@@ -0,0 +1,108 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"gotest.tools/assert"
+	"testing"
+	"time"
+)
+
+// Executable multiplying its input, failing given number of times first
+type multiplyingExecutable struct {
+	input    float64
+	factor   float64
+	failures int
+	calls    int
+}
+
+func (e *multiplyingExecutable) ExecuteWithResult(ctx context.Context) (float64, error) {
+	e.calls++
+	if e.calls <= e.failures {
+		return 0, errFlaky
+	}
+	return e.input * e.factor, nil
+}
+
+func TestTaskWithResult(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          float64
+		factor         float64
+		failures       int
+		retry          *RetryPolicy
+		expectedResult float64
+		expectedErr    error
+	}{
+		{
+			name:           "returns value",
+			input:          2,
+			factor:         3,
+			expectedResult: 6,
+		},
+		{
+			name:           "returns value of successful retry",
+			input:          2,
+			factor:         4,
+			failures:       1,
+			retry:          &RetryPolicy{MaxAttempts: 2, InitialBackoff: 10 * time.Millisecond},
+			expectedResult: 8,
+		},
+		{
+			name:        "returns error of failed task",
+			input:       2,
+			factor:      4,
+			failures:    1,
+			expectedErr: errFlaky,
+		},
+	}
+
+	for _, constructor := range executorConstructors {
+		for _, test := range tests {
+			t.Run(constructor.name+": "+test.name, func(t *testing.T) {
+				queue, executor := constructor.newExecutor()
+				defer executor.Stop()
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				executable := &multiplyingExecutable{input: test.input, factor: test.factor, failures: test.failures}
+				task := NewTaskWithResult[float64](executable)
+				task.Retry = test.retry
+
+				handle, err := SubmitWithResult[float64](queue, task)
+				assert.NilError(t, err)
+
+				result, err := handle.Result(ctx)
+				assert.Check(t, errors.Is(err, test.expectedErr))
+				assert.Equal(t, test.expectedResult, result)
+
+				// value is also available through the task id
+				info := queue.Get(handle.Id())
+				if test.expectedErr == nil {
+					assert.Equal(t, test.expectedResult, info.Result)
+				} else {
+					assert.Equal(t, nil, info.Result)
+				}
+			})
+		}
+	}
+}
+
+func TestSubmitWithResultOfDifferentType(t *testing.T) {
+	queue := NewTaskQueue()
+	defer queue.Stop()
+
+	task := NewTaskWithResult[float64](&multiplyingExecutable{input: 1, factor: 1})
+	_, err := SubmitWithResult[int](queue, task)
+	assert.ErrorContains(t, err, "doesn't produce result of type int")
+
+	_, err = SubmitWithResult[int](queue, NewExecutableQuickie())
+	assert.ErrorContains(t, err, "doesn't produce result of type int")
+
+	// plain executables have no result
+	id := mustPush(t, queue, NewExecutableQuickie())
+	queue.Pop()
+	queue.Complete(id, nil)
+	assert.Equal(t, nil, queue.Get(id).Result)
+}
@@ -0,0 +1,59 @@
+package executor
+
+// TaskState describes where in its lifecycle a task currently is.
+//
+//	(Scheduled ->) Queued -> Running -> Succeeded
+//	                                 -> Failed
+//	                                 -> Cancelled
+//	                                 -> TimedOut (ran longer than `Task.Timeout`)
+//	                                 -> Scheduled (failed, but going to be retried)
+//	(Scheduled ->) Queued -> Dropped (queue was full, see `OverflowPolicy`)
+//	Blocked -> Queued (or Scheduled) once all dependencies succeeded, see `Task.DependsOn`
+//	        -> Failed or Skipped when a dependency didn't succeed, see `DependencyFailurePolicy`
+type TaskState int
+
+const (
+	TaskQueued TaskState = iota
+	TaskRunning
+	TaskSucceeded
+	TaskFailed
+	TaskCancelled
+	TaskScheduled
+	TaskDropped
+	TaskBlocked
+	TaskSkipped
+	TaskTimedOut
+)
+
+func (s TaskState) String() string {
+	switch s {
+	case TaskQueued:
+		return "Queued"
+	case TaskRunning:
+		return "Running"
+	case TaskSucceeded:
+		return "Succeeded"
+	case TaskFailed:
+		return "Failed"
+	case TaskCancelled:
+		return "Cancelled"
+	case TaskScheduled:
+		return "Scheduled"
+	case TaskDropped:
+		return "Dropped"
+	case TaskBlocked:
+		return "Blocked"
+	case TaskSkipped:
+		return "Skipped"
+	case TaskTimedOut:
+		return "TimedOut"
+	default:
+		return "Unknown"
+	}
+}
+
+// IsFinished returns true for states a task is never going to leave.
+func (s TaskState) IsFinished() bool {
+	return s == TaskSucceeded || s == TaskFailed || s == TaskCancelled || s == TaskDropped ||
+		s == TaskSkipped || s == TaskTimedOut
+}
@@ -0,0 +1,88 @@
+package executor
+
+import "AwesomePresentation/logging"
+
+// How many changes are buffered for each subscriber. Subscriber which falls behind is unsubscribed,
+// the queue never waits for it and the subscriber never misses a change without noticing
+const subscriptionBufferSize = 256
+
+// taskSubscription receives a copy of the task info on every change of its state
+type taskSubscription struct {
+	events chan TaskInfo
+	// Closed together with `events`, lets the goroutine watching subscriber's context exit
+	done chan struct{}
+}
+
+// Like the rest of the ledger, subscriptions are not thread safe - queues call these under their protection.
+func (l *taskLedger) subscribe() *taskSubscription {
+	subscription := &taskSubscription{
+		events: make(chan TaskInfo, subscriptionBufferSize),
+		done:   make(chan struct{}),
+	}
+
+	if l.subscriptionsClosed {
+		// nothing is going to change anymore
+		close(subscription.events)
+		close(subscription.done)
+		return subscription
+	}
+
+	l.subscriptions[subscription] = struct{}{}
+	return subscription
+}
+
+func (l *taskLedger) unsubscribe(subscription *taskSubscription) {
+	if _, found := l.subscriptions[subscription]; !found {
+		return
+	}
+
+	delete(l.subscriptions, subscription)
+	close(subscription.events)
+	close(subscription.done)
+}
+
+// publishingQueue is implemented by queues of this package. Subscription closed by the queue means either
+// the queue has stopped, or the subscriber has fallen behind - the channel tells them apart.
+type publishingQueue interface {
+	// Closed once the queue has stopped publishing changes, before it closes the subscriptions
+	publishingDone() <-chan struct{}
+}
+
+func (q *taskQueue) publishingDone() <-chan struct{} {
+	return q.tasks.publishingDone
+}
+
+func (q *lockingTaskQueue) publishingDone() <-chan struct{} {
+	return q.tasks.publishingDone
+}
+
+func (l *taskLedger) publish(info TaskInfo) {
+	for subscription := range l.subscriptions {
+		select {
+		case subscription.events <- info:
+		default:
+			l.logger.Warn("subscriber is not keeping up, unsubscribing it", logging.TaskId(info.Id),
+				logging.Any("state", info.State.String()))
+			l.unsubscribe(subscription)
+		}
+	}
+}
+
+// Called once the ledger is closed and drained (or its queue is gone) - nothing is going to change anymore
+func (l *taskLedger) stopped() {
+	l.closeSubscriptions()
+	if l.unregisterMetrics != nil {
+		l.unregisterMetrics()
+	}
+}
+
+// Ends all subscriptions, see `stopped`
+func (l *taskLedger) closeSubscriptions() {
+	if !l.subscriptionsClosed {
+		close(l.publishingDone)
+	}
+	l.subscriptionsClosed = true
+	for subscription := range l.subscriptions {
+		l.unsubscribe(subscription)
+	}
+}
@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"context"
+	"gotest.tools/assert"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			events := queue.Subscribe(context.Background())
+
+			id := mustPush(t, queue, Task{TaskExecutable: &flakyExecutable{}})
+			queue.Pop()
+			queue.Complete(id, nil)
+
+			for _, expectedState := range []TaskState{TaskQueued, TaskRunning, TaskSucceeded} {
+				info := receiveEvent(t, events)
+				assert.Equal(t, id, info.Id)
+				assert.Equal(t, expectedState, info.State)
+			}
+
+			// stopped queue ends the stream
+			queue.Stop()
+			_, open := <-events
+			assert.Check(t, !open)
+
+			_, open = <-queue.Subscribe(context.Background())
+			assert.Check(t, !open)
+		})
+	}
+}
+
+func TestSubscribeUntilContextIsDone(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			events := queue.Subscribe(ctx)
+			cancel()
+
+			// events published in the meantime might still be buffered
+			deadline := time.After(5 * time.Second)
+			for {
+				select {
+				case _, open := <-events:
+					if !open {
+						return
+					}
+				case <-deadline:
+					t.Fatal("subscription did not end with its context")
+				}
+			}
+		})
+	}
+}
+
+func TestSlowSubscriberIsUnsubscribed(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue := constructor.newQueue()
+			defer queue.Stop()
+
+			slow := queue.Subscribe(context.Background())
+			for i := 0; i <= subscriptionBufferSize; i++ {
+				mustPush(t, queue, NewExecutableQuickie())
+			}
+
+			// buffered changes are delivered, then the channel is closed instead of changes going missing
+			received := 0
+			for range slow {
+				received++
+			}
+			assert.Equal(t, subscriptionBufferSize, received)
+
+			// other subscribers are not affected
+			events := queue.Subscribe(context.Background())
+			id := mustPush(t, queue, NewExecutableQuickie())
+			assert.Equal(t, id, receiveEvent(t, events).Id)
+		})
+	}
+}
+
+func receiveEvent(t *testing.T, events <-chan TaskInfo) TaskInfo {
+	t.Helper()
+
+	select {
+	case info, open := <-events:
+		assert.Check(t, open)
+		return info
+	case <-time.After(5 * time.Second):
+		t.Fatal("no task change received")
+		return TaskInfo{}
+	}
+}
@@ -0,0 +1,100 @@
+package executor
+
+import (
+	"AwesomePresentation/logging"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// TimeoutPolicy says what executors do with `ContextExecutable`s which keep running after their task has
+// timed out. Context of the task is cancelled as soon as it times out, well-behaved executables return right
+// away and both policies behave the same. Only executables ignoring cancellation tell them apart.
+//
+// Plain `Executable`s (without `ExecuteContext`) never see the cancellation, so they're always abandoned,
+// whatever the policy. Waiting for a hung one would hold the worker forever.
+type TimeoutPolicy int
+
+const (
+	// Worker waits for the executable to return, so executions never overlap and the order of tasks is kept.
+	// `ContextExecutable` ignoring cancellation and never returning blocks the worker forever, the task is
+	// marked timed out once it returns.
+	TimeoutWait TimeoutPolicy = iota
+	// Worker marks the task timed out and moves on to the next task right away, the executable is left running
+	// in a goroutine of its own. It's never waited for (not even by `Executor.Stop`), may overlap with tasks
+	// executed after it and its result is thrown away. Executable which never returns leaks the goroutine.
+	// Abandoned task is not retried, as the retry would run the same executable next to the abandoned one.
+	TimeoutAbandon
+)
+
+// Error of execution left running after its task timed out, the ledger doesn't retry it
+var errExecutionAbandoned = errors.New("execution abandoned")
+
+func (p TimeoutPolicy) String() string {
+	switch p {
+	case TimeoutWait:
+		return "Wait"
+	case TimeoutAbandon:
+		return "Abandon"
+	default:
+		return fmt.Sprintf("TimeoutPolicy(%d)", int(p))
+	}
+}
+
+// Runs the task with its context cancelled once its timeout expires, see `Task.Timeout` and `TimeoutPolicy`.
+// Execution which has run out of time fails with `ErrTaskTimedOut`, whatever the executable returned.
+func (e *Executor) runWithTimeout(ctx context.Context, task *Task) error {
+	timeout := task.Timeout
+	if timeout <= 0 {
+		timeout = e.taskTimeout
+	}
+	if timeout <= 0 {
+		return e.run(ctx, task)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	timedOut := func() bool {
+		// cancellation of the task itself (e.g. `TaskQueue.Cancel`) is not a timeout
+		return timeoutCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil
+	}
+	timeoutErr := fmt.Errorf("%w after %v", ErrTaskTimedOut, timeout)
+
+	// plain executable can't be told to stop, waiting for it might hold the worker forever
+	_, contextAware := task.TaskExecutable.(ContextExecutable)
+	if e.timeoutPolicy != TimeoutAbandon && contextAware {
+		err := e.run(timeoutCtx, task)
+		if timedOut() {
+			return timeoutErr
+		}
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- e.run(timeoutCtx, task)
+	}()
+
+	select {
+	case err := <-done:
+		if timedOut() {
+			return timeoutErr
+		}
+		return err
+	case <-timeoutCtx.Done():
+		if !timedOut() {
+			// task has been cancelled, it's waited for like without timeout
+			return <-done
+		}
+	}
+
+	select {
+	case <-done:
+		// executable has returned just in time to be waited for
+	default:
+		e.logger.Warn("task timed out, abandoning its execution", logging.TaskId(task.Id),
+			logging.Any("timeout", timeout))
+		return fmt.Errorf("%w, %w", timeoutErr, errExecutionAbandoned)
+	}
+	return timeoutErr
+}
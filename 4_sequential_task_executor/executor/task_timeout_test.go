@@ -0,0 +1,235 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"gotest.tools/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Returns once its context is cancelled or after the duration
+func sleepingExecutable(duration time.Duration) Executable {
+	return ExecutableFunc(func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(duration):
+			return nil
+		}
+	})
+}
+
+func TestTaskTimeout(t *testing.T) {
+	tests := []struct {
+		name          string
+		task          Task
+		options       []Option
+		expectedState TaskState
+	}{
+		{
+			name:          "task timeout",
+			task:          Task{TaskExecutable: sleepingExecutable(time.Minute), Timeout: 20 * time.Millisecond},
+			expectedState: TaskTimedOut,
+		},
+		{
+			name:          "executor timeout",
+			task:          Task{TaskExecutable: sleepingExecutable(time.Minute)},
+			options:       []Option{WithTaskTimeout(20 * time.Millisecond)},
+			expectedState: TaskTimedOut,
+		},
+		{
+			name:          "task timeout overrides executor timeout",
+			task:          Task{TaskExecutable: sleepingExecutable(50 * time.Millisecond), Timeout: time.Minute},
+			options:       []Option{WithTaskTimeout(20 * time.Millisecond)},
+			expectedState: TaskSucceeded,
+		},
+		{
+			name:          "abandoned in time",
+			task:          Task{TaskExecutable: sleepingExecutable(10 * time.Millisecond), Timeout: time.Minute},
+			options:       []Option{WithTimeoutPolicy(TimeoutAbandon)},
+			expectedState: TaskSucceeded,
+		},
+	}
+
+	for _, constructor := range executorConstructors {
+		for _, test := range tests {
+			t.Run(constructor.name+": "+test.name, func(t *testing.T) {
+				queue, executor := constructor.newExecutor(test.options...)
+				defer executor.Stop()
+
+				id := mustPush(t, queue, test.task)
+				info := waitForFinishedTask(queue, id, 5*time.Second)
+				assert.Equal(t, test.expectedState, info.State)
+				if test.expectedState == TaskTimedOut {
+					assert.Check(t, errors.Is(info.Error, ErrTaskTimedOut))
+					assert.Check(t, info.DeadLettered)
+				}
+			})
+		}
+	}
+}
+
+func TestTimeoutPolicy(t *testing.T) {
+	tests := []struct {
+		policy TimeoutPolicy
+		// Whether the next task runs while the timed out executable is still running
+		nextRunsMeanwhile bool
+	}{
+		{policy: TimeoutWait, nextRunsMeanwhile: false},
+		{policy: TimeoutAbandon, nextRunsMeanwhile: true},
+	}
+
+	for _, constructor := range executorConstructors {
+		for _, test := range tests {
+			t.Run(constructor.name+": "+test.policy.String(), func(t *testing.T) {
+				queue, executor := constructor.newExecutor(WithTimeoutPolicy(test.policy))
+				defer executor.Stop()
+
+				// ignores cancellation, runs until it's released
+				release := make(chan struct{})
+				var returned int32
+				stuckId := mustPush(t, queue, Task{
+					TaskExecutable: ExecutableFunc(func(ctx context.Context) error {
+						<-release
+						atomic.StoreInt32(&returned, 1)
+						return nil
+					}),
+					Timeout: 20 * time.Millisecond,
+				})
+				nextId := mustPush(t, queue, NewExecutableQuickie())
+
+				next := waitForFinishedTask(queue, nextId, 200*time.Millisecond)
+				assert.Equal(t, test.nextRunsMeanwhile, next.State.IsFinished())
+				assert.Equal(t, int32(0), atomic.LoadInt32(&returned))
+
+				close(release)
+				stuck := waitForFinishedTask(queue, stuckId, 5*time.Second)
+				assert.Equal(t, TaskTimedOut, stuck.State)
+				assert.Check(t, errors.Is(stuck.Error, ErrTaskTimedOut))
+				assert.Equal(t, TaskSucceeded, waitForFinishedTask(queue, nextId, 5*time.Second).State)
+			})
+		}
+	}
+}
+
+func TestTimedOutTaskIsRetried(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor()
+			defer executor.Stop()
+
+			// hangs on the first attempt only
+			var attempts int32
+			id := mustPush(t, queue, Task{
+				TaskExecutable: ExecutableFunc(func(ctx context.Context) error {
+					if atomic.AddInt32(&attempts, 1) == 1 {
+						<-ctx.Done()
+						return ctx.Err()
+					}
+					return nil
+				}),
+				Timeout: 20 * time.Millisecond,
+				Retry:   &RetryPolicy{MaxAttempts: 2},
+			})
+
+			info := waitForFinishedTask(queue, id, 5*time.Second)
+			assert.Equal(t, TaskSucceeded, info.State)
+			assert.Equal(t, 2, info.Attempts)
+		})
+	}
+}
+
+func TestCancelledTaskDoesNotTimeOut(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor(WithTimeoutPolicy(TimeoutAbandon))
+			defer executor.Stop()
+
+			started := make(chan struct{})
+			id := mustPush(t, queue, Task{
+				TaskExecutable: ExecutableFunc(func(ctx context.Context) error {
+					close(started)
+					<-ctx.Done()
+					return ctx.Err()
+				}),
+				Timeout: time.Minute,
+			})
+
+			<-started
+			assert.Check(t, queue.Cancel(id))
+			assert.Equal(t, TaskCancelled, waitForFinishedTask(queue, id, 5*time.Second).State)
+		})
+	}
+}
+
+// Ignores cancellation, returns its value once it's released
+type stuckExecutableWithResult struct {
+	release chan struct{}
+}
+
+func (e *stuckExecutableWithResult) ExecuteWithResult(ctx context.Context) (int, error) {
+	<-e.release
+	return 42, nil
+}
+
+func TestAbandonedTaskIsNotRetried(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			queue, executor := constructor.newExecutor(WithTaskTimeout(10*time.Millisecond),
+				WithTimeoutPolicy(TimeoutAbandon))
+			defer executor.Stop()
+
+			executable := &stuckExecutableWithResult{release: make(chan struct{})}
+			task := NewTaskWithResult[int](executable)
+			task.Retry = &RetryPolicy{MaxAttempts: 3}
+			id := mustPush(t, queue, task)
+
+			info := waitForFinishedTask(queue, id, 5*time.Second)
+			close(executable.release)
+			assert.Equal(t, TaskTimedOut, info.State)
+			assert.Equal(t, 1, info.Attempts)
+			assert.Check(t, errors.Is(info.Error, ErrTaskTimedOut))
+			assert.Check(t, info.Result == nil)
+
+			// abandoned execution returning later doesn't touch the task
+			time.Sleep(20 * time.Millisecond)
+			latest := queue.Get(id)
+			assert.Equal(t, TaskTimedOut, latest.State)
+			assert.Equal(t, 1, latest.Attempts)
+			assert.Check(t, latest.Result == nil)
+		})
+	}
+}
+
+// Plain executable without context, blocks until it's released
+type stuckExecutable struct {
+	release chan struct{}
+}
+
+func (e *stuckExecutable) Execute() error {
+	<-e.release
+	return nil
+}
+
+func TestContextUnawareTaskIsAbandoned(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			// default policy waits only for executables which can be cancelled
+			queue, executor := constructor.newExecutor(WithTaskTimeout(20 * time.Millisecond))
+			defer executor.Stop()
+
+			stuck := &stuckExecutable{release: make(chan struct{})}
+			defer close(stuck.release)
+			stuckId := mustPush(t, queue, Task{TaskExecutable: stuck})
+			nextId := mustPush(t, queue, NewExecutableQuickie())
+
+			// worker is freed while the executable is still blocked
+			assert.Equal(t, TaskSucceeded, waitForFinishedTask(queue, nextId, 5*time.Second).State)
+			info := queue.Get(stuckId)
+			assert.Equal(t, TaskTimedOut, info.State)
+			assert.Check(t, errors.Is(info.Error, ErrTaskTimedOut))
+		})
+	}
+}
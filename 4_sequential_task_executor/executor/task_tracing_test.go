@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"AwesomePresentation/tracing"
+	"AwesomePresentation/tracing/tracingtest"
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gotest.tools/assert"
+	"testing"
+	"time"
+)
+
+func TestTaskTracing(t *testing.T) {
+	for _, constructor := range executorConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			provider, exporter := tracingtest.NewInMemoryProvider()
+			queue, executor := constructor.newExecutor(WithTracerProvider(provider))
+			defer executor.Stop()
+
+			ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+			// children may outlive their parent, ending it first keeps the order of the spans stable
+			parent.End()
+			task := Task{TaskExecutable: &flakyExecutable{failures: 1}, Retry: &RetryPolicy{MaxAttempts: 2}}
+			handle, err := queue.Submit(task.WithTraceContext(ctx))
+			assert.NilError(t, err)
+
+			waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			assert.NilError(t, handle.Wait(waitCtx))
+
+			// every attempt waits and executes, all as children of the request
+			spans := exporter.GetSpans()
+			names := make([]string, 0)
+			for _, span := range spans {
+				names = append(names, span.Name)
+				assert.Equal(t, parent.SpanContext().TraceID(), span.SpanContext.TraceID())
+				if span.Name != "request" {
+					assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+					assert.Check(t, hasAttribute(span, tracing.TaskId(handle.Id())))
+				}
+			}
+			assert.DeepEqual(t, []string{"request", "executor.wait", "executor.execute", "executor.wait", "executor.execute"}, names)
+
+			assert.Equal(t, codes.Error, spans[2].Status.Code)
+			assert.Check(t, hasAttribute(spans[2], tracing.Worker(1)))
+			assert.Check(t, hasAttribute(spans[3], attribute.Int("task.attempt", 2)))
+			assert.Equal(t, codes.Unset, spans[4].Status.Code)
+		})
+	}
+}
+
+func TestCancelledTaskWaitIsTraced(t *testing.T) {
+	for _, constructor := range queueConstructors {
+		t.Run(constructor.name, func(t *testing.T) {
+			provider, exporter := tracingtest.NewInMemoryProvider()
+			queue := constructor.newQueue(WithTracerProvider(provider))
+			defer queue.Stop()
+
+			id := mustPush(t, queue, NewExecutableQuickie())
+			assert.Check(t, queue.Cancel(id))
+
+			spans := exporter.GetSpans()
+			assert.Equal(t, 1, len(spans))
+			assert.Equal(t, "executor.wait", spans[0].Name)
+			// task without trace context starts a trace of its own
+			assert.Check(t, !spans[0].Parent.IsValid())
+			assert.Equal(t, codes.Error, spans[0].Status.Code)
+			assert.Check(t, hasAttribute(spans[0], attribute.String("task.state", TaskCancelled.String())))
+		})
+	}
+}
+
+func hasAttribute(span tracetest.SpanStub, expected attribute.KeyValue) bool {
+	for _, attribute := range span.Attributes {
+		if attribute == expected {
+			return true
+		}
+	}
+	return false
+}
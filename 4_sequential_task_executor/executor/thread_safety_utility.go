@@ -1,7 +1,6 @@
 package executor
 
 import (
-	"fmt"
 	"gotest.tools/assert"
 	"runtime"
 	"sync"
@@ -67,11 +66,11 @@ func executeParallelOperations(t *testing.T, numberOfParallelRoutines int, teste
 
 			// scp.Log().Infof("executing operation index: %v", currentIdx)
 			err := currentOperation()
-			fmt.Println(fmt.Sprintf("finished executing operation index: %v", currentIdx))
+			t.Logf("finished executing operation index: %v", currentIdx)
 
 			// Error while executing operation
 			if err != nil {
-				fmt.Println(fmt.Sprintf("execution error: %v operation: %v", err, currentIdx))
+				t.Logf("execution error: %v operation: %v", err, currentIdx)
 			}
 
 			// Utility itself should be thread safe
@@ -89,13 +88,13 @@ func executeParallelOperations(t *testing.T, numberOfParallelRoutines int, teste
 	// We wait for a second to make sure all routines had time to reach locking point
 	startupLock.Wait()
 
-	fmt.Println("Waiting for routines finished, releasing parallelReleaseLock...")
+	t.Log("Waiting for routines finished, releasing parallelReleaseLock...")
 	// Releasing lock should fire all routines to continue
 	parallelReleaseLock.Done()
 
 	// We wait for all routines to complete
 	completionLock.Wait()
-	fmt.Println("All routines completed...")
+	t.Log("All routines completed...")
 
 	// This check actually verifies that while executing we've locked in the middle and/or we didn't have race condition
 	assert.Equal(t, expectedTestedConcurrentOperationsCount, counter)
@@ -107,25 +106,19 @@ func executeParallelOperations(t *testing.T, numberOfParallelRoutines int, teste
 type testSliceCollectingExecutable struct {
 	value      int
 	collection *[]int
-	wg         *sync.WaitGroup
 }
 
 func (e *testSliceCollectingExecutable) Execute() error {
-	if e.wg != nil {
-		// we need to wait for each task to finish, this way we can be sure test can terminate once all executable tasks are done, not before
-		defer e.wg.Done()
-	}
 	// If there is a race condition, this statement will detect it.
 	*e.collection = append(*e.collection, e.value)
 	return nil
 }
 
-func NewTestSliceCollectingExecutable(value int, channelledCollectSlice *[]int, waitGroup *sync.WaitGroup) Task {
+func NewTestSliceCollectingExecutable(value int, channelledCollectSlice *[]int) Task {
 	return Task{
 		TaskExecutable: &testSliceCollectingExecutable{
 			value:      value,
 			collection: channelledCollectSlice,
-			wg:         waitGroup,
 		},
 	}
 }
@@ -0,0 +1,397 @@
+package executor
+
+import (
+	"AwesomePresentation/logging"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// SyncPolicy decides when the persistent queue flushes (fsyncs) its log to the disk
+type SyncPolicy int
+
+const (
+	// Flush after every record - nothing acknowledged is lost, but every change waits for the disk
+	SyncEveryWrite SyncPolicy = iota
+	// Flush every `WithSyncInterval`, a crash might lose changes made since the last flush
+	SyncPeriodically
+	// Leave flushing to the operating system, only closing the queue flushes the log
+	SyncNever
+)
+
+func (p SyncPolicy) String() string {
+	switch p {
+	case SyncEveryWrite:
+		return "EveryWrite"
+	case SyncPeriodically:
+		return "Periodically"
+	case SyncNever:
+		return "Never"
+	default:
+		return "Unknown"
+	}
+}
+
+const (
+	walEnqueue  = "enqueue"
+	walDequeue  = "dequeue"
+	walComplete = "complete"
+)
+
+// walEntry is a single line of the log (JSON). Enqueue entries carry the whole task, the others only
+// the change of its state.
+type walEntry struct {
+	Op string `json:"op"`
+	Id string `json:"id"`
+
+	// Only set in enqueue entries
+	*TaskSpec
+
+	// Only written by compaction, so restored tasks keep counting their attempts
+	Attempts int `json:"attempts,omitempty"`
+
+	State TaskState `json:"state,omitempty"`
+	DueAt time.Time `json:"dueAt"`
+}
+
+// writeAheadLog is the journal of the persistent queue. Every change of the ledger is appended to the file
+// before it's acknowledged, so the tasks which haven't finished can be restored after a restart.
+// The log keeps growing, so it's periodically compacted - rewritten to contain only the unfinished tasks.
+type writeAheadLog struct {
+	path     string
+	registry *ExecutableRegistry
+
+	syncPolicy         SyncPolicy
+	syncInterval       time.Duration
+	compactionInterval time.Duration
+
+	lock sync.Mutex
+	file *os.File
+
+	// Enqueue entries of unfinished tasks in order of submission - everything compaction has to keep
+	live  map[string]*walEntry
+	order []string
+
+	// Entries appended since the last compaction and since the last flush
+	appended int
+	dirty    bool
+	closed   bool
+
+	quit chan struct{}
+	done chan struct{}
+
+	logger logging.Logger
+}
+
+// Opens (or creates) the log and replays it. The log is compacted right away, so it doesn't grow across restarts.
+func openWriteAheadLog(path string, registry *ExecutableRegistry, cfg config) (*writeAheadLog, error) {
+	wal := &writeAheadLog{
+		path:               path,
+		registry:           registry,
+		syncPolicy:         cfg.syncPolicy,
+		syncInterval:       cfg.syncInterval,
+		compactionInterval: cfg.compactionInterval,
+		live:               make(map[string]*walEntry),
+		order:              make([]string, 0),
+		quit:               make(chan struct{}),
+		done:               make(chan struct{}),
+		logger:             cfg.logger.With(logging.Any("path", path)),
+	}
+
+	if err := wal.replay(); err != nil {
+		return nil, err
+	}
+	if err := wal.compact(); err != nil {
+		return nil, err
+	}
+
+	go wal.run()
+	return wal, nil
+}
+
+func (w *writeAheadLog) replay() error {
+	file, err := os.Open(w.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open task log: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return fmt.Errorf("failed to read task log: %w", readErr)
+		}
+		if len(line) == 0 {
+			return nil
+		}
+
+		entry := &walEntry{}
+		if err := json.Unmarshal(line, entry); err != nil {
+			if errors.Is(readErr, io.EOF) {
+				// the last line was being written when the process died, the change was never acknowledged
+				w.logger.Warn("ignoring torn record at the end of task log")
+				return nil
+			}
+			return fmt.Errorf("corrupted task log %v at line %v: %w", w.path, lineNumber, err)
+		}
+		w.apply(entry)
+
+		if errors.Is(readErr, io.EOF) {
+			return nil
+		}
+	}
+}
+
+// Updates the unfinished tasks according to the entry. Must hold the lock (or be replaying)
+func (w *writeAheadLog) apply(entry *walEntry) {
+	switch entry.Op {
+	case walEnqueue:
+		if _, found := w.live[entry.Id]; !found {
+			w.order = append(w.order, entry.Id)
+		}
+		w.live[entry.Id] = entry
+	case walDequeue:
+		if live, found := w.live[entry.Id]; found {
+			live.Attempts++
+		}
+	case walComplete:
+		live, found := w.live[entry.Id]
+		if !found {
+			return
+		}
+		if entry.State.IsFinished() {
+			delete(w.live, entry.Id)
+			removeId(&w.order, entry.Id)
+		} else {
+			// failed attempt is going to be retried
+			live.DueAt = entry.DueAt
+		}
+	}
+}
+
+// Adds tasks which haven't finished to the ledger, in order of submission. Tasks which were running when
+// the process stopped are queued again - they might run twice, but they are never lost.
+func (w *writeAheadLog) restoreInto(tasks *taskLedger) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, id := range w.order {
+		entry := w.live[id]
+		if entry.TaskSpec == nil {
+			return fmt.Errorf("failed to restore task %v: enqueue record has no task", id)
+		}
+
+		task, err := w.registry.NewTask(*entry.TaskSpec)
+		if err != nil {
+			return fmt.Errorf("failed to restore task %v: %w", id, err)
+		}
+		task.Id = id
+		tasks.restore(task, entry.DueAt, entry.Attempts)
+	}
+
+	return nil
+}
+
+func (w *writeAheadLog) enqueued(task Task, dueAt time.Time) error {
+	spec, err := w.registry.Spec(task)
+	if err != nil {
+		return err
+	}
+
+	return w.append(&walEntry{
+		Op:       walEnqueue,
+		Id:       task.Id,
+		TaskSpec: &spec,
+		DueAt:    dueAt,
+	})
+}
+
+func (w *writeAheadLog) started(id string) {
+	if err := w.append(&walEntry{Op: walDequeue, Id: id}); err != nil {
+		w.logger.Error("failed to log start of task", logging.TaskId(id), logging.Err(err))
+	}
+}
+
+func (w *writeAheadLog) completed(info TaskInfo) {
+	if err := w.append(&walEntry{Op: walComplete, Id: info.Id, State: info.State, DueAt: info.DueAt}); err != nil {
+		w.logger.Error("failed to log completion of task", logging.TaskId(info.Id), logging.Err(err))
+	}
+}
+
+func (w *writeAheadLog) append(entry *walEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to serialize task log record: %w", err)
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.closed {
+		return ErrQueueClosed
+	}
+
+	if _, err := w.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write task log: %w", err)
+	}
+	if w.syncPolicy == SyncEveryWrite {
+		if err := w.file.Sync(); err != nil {
+			return fmt.Errorf("failed to flush task log: %w", err)
+		}
+	} else {
+		w.dirty = true
+	}
+
+	w.apply(entry)
+	w.appended++
+	return nil
+}
+
+// Rewrites the log, so it only contains enqueue entries of unfinished tasks. The new log is written next to
+// the old one and renamed over it, so a crash leaves either of them intact.
+func (w *writeAheadLog) compact() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.closed {
+		return ErrQueueClosed
+	}
+
+	compactedPath := w.path + ".compact"
+	compacted, err := os.OpenFile(compactedPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted task log: %w", err)
+	}
+
+	writer := bufio.NewWriter(compacted)
+	for _, id := range w.order {
+		line, err := json.Marshal(w.live[id])
+		if err == nil {
+			_, err = writer.Write(append(line, '\n'))
+		}
+		if err != nil {
+			compacted.Close()
+			return fmt.Errorf("failed to write compacted task log: %w", err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		compacted.Close()
+		return fmt.Errorf("failed to write compacted task log: %w", err)
+	}
+	if err := compacted.Sync(); err != nil {
+		compacted.Close()
+		return fmt.Errorf("failed to flush compacted task log: %w", err)
+	}
+	if err := compacted.Close(); err != nil {
+		return fmt.Errorf("failed to close compacted task log: %w", err)
+	}
+
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	if err := os.Rename(compactedPath, w.path); err != nil {
+		return fmt.Errorf("failed to replace task log: %w", err)
+	}
+
+	w.file, err = os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen task log: %w", err)
+	}
+
+	w.appended = 0
+	w.dirty = false
+	return nil
+}
+
+func (w *writeAheadLog) sync() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.closed || !w.dirty {
+		return
+	}
+	if err := w.file.Sync(); err != nil {
+		w.logger.Error("failed to flush task log", logging.Err(err))
+		return
+	}
+	w.dirty = false
+}
+
+// Compaction is only worth it when the log holds more than the enqueue entries of unfinished tasks
+func (w *writeAheadLog) needsCompaction() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return !w.closed && w.appended > 0
+}
+
+// Periodically flushes and compacts the log, until the log is closed
+func (w *writeAheadLog) run() {
+	defer close(w.done)
+	defer (func() {
+		if panic := recover(); panic != nil {
+			w.logger.Error("task log goroutine panicked", logging.Any("panic", panic), logging.Any("stack", string(debug.Stack())))
+		}
+	})()
+
+	var syncChannel <-chan time.Time
+	if w.syncPolicy == SyncPeriodically {
+		syncTicker := time.NewTicker(w.syncInterval)
+		defer syncTicker.Stop()
+		syncChannel = syncTicker.C
+	}
+
+	compactionTicker := time.NewTicker(w.compactionInterval)
+	defer compactionTicker.Stop()
+
+	for {
+		select {
+		case <-syncChannel:
+			w.sync()
+		case <-compactionTicker.C:
+			if w.needsCompaction() {
+				if err := w.compact(); err != nil {
+					w.logger.Error("failed to compact task log", logging.Err(err))
+				}
+			}
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// Stops the background goroutine, flushes and closes the log. Safe to call more than once.
+func (w *writeAheadLog) close() error {
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.quit)
+
+	var err error
+	if w.file != nil {
+		if syncErr := w.file.Sync(); syncErr != nil {
+			err = fmt.Errorf("failed to flush task log: %w", syncErr)
+		}
+		w.file.Close()
+		w.file = nil
+	}
+	w.lock.Unlock()
+
+	<-w.done
+	return err
+}
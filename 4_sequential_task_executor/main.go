@@ -2,39 +2,150 @@ package main
 
 import (
 	"AwesomePresentation/4_sequential_task_executor/executor"
+	"AwesomePresentation/4_sequential_task_executor/server"
+	"AwesomePresentation/4_sequential_task_executor/taskservice"
+	"AwesomePresentation/logging"
+	"AwesomePresentation/metrics"
+	"AwesomePresentation/tracing"
 	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
 func main() {
+	httpAddress := flag.String("http", "", "address to serve the HTTP API on, e.g. :8080 (disabled by default)")
+	grpcAddress := flag.String("grpc", "", "address to serve the gRPC task service on, e.g. :9090 (disabled by default)")
+	workers := flag.Int("workers", 1, "number of tasks executed at the same time, 1 keeps them sequential")
+	logLevel := flag.String("log-level", "info", "least level of logged messages: debug, info, warn or error")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "OTLP/gRPC collector to export traces to, e.g. localhost:4317 (disabled by default)")
+	flag.Parse()
+
+	level := slog.LevelInfo
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		fmt.Printf("Main: Invalid log level: %v\n", err)
+		return
+	}
+	logger := logging.NewSlogLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
 	// This will allow us to enter numbers until we write -1
 	reader := bufio.NewReader(os.Stdin)
 
+	// Tasks are traced once there is somewhere to export the spans to
+	var tracerProvider trace.TracerProvider
+	if *otlpEndpoint != "" {
+		otlpProvider, err := tracing.NewOTLPProvider(context.Background(), *otlpEndpoint, "sequential-task-executor")
+		if err != nil {
+			fmt.Printf("Main: Cannot export traces: %v\n", err)
+			return
+		}
+		// exports the spans which haven't been exported yet
+		defer func() { _ = otlpProvider.Shutdown(context.Background()) }()
+		tracerProvider = otlpProvider
+	}
+
+	// Served next to the HTTP API, see `metrics.Registry`
+	metricsRegistry := metrics.NewRegistry()
+
 	// Create the queue
-	queue, _ := executor.NewLockingQueueExecutor()
+	queue, taskExecutor := executor.NewLockingQueueExecutor(executor.WithWorkers(*workers), executor.WithLogger(logger),
+		executor.WithMetrics(metricsRegistry), executor.WithTracerProvider(tracerProvider))
+
+	// Knows `count`, `child` and `quickie`
+	registry := executor.NewDefaultExecutableRegistry()
+
+	// Tasks can be pushed over HTTP as well, see `server.Server`
+	var httpServer *http.Server
+	if *httpAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/", server.NewServer(queue, registry, logger))
+		mux.Handle("GET /metrics", metricsRegistry)
+		httpServer = &http.Server{Addr: *httpAddress, Handler: mux}
+		go func() {
+			fmt.Printf("Main: Serving HTTP API on %v\n", *httpAddress)
+			if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Printf("Main: HTTP API failed: %v\n", err)
+			}
+		}()
+	}
+
+	// ... and over gRPC, see `taskservice.Client` for the remote queue
+	var grpcServer *grpc.Server
+	if *grpcAddress != "" {
+		listener, err := net.Listen("tcp", *grpcAddress)
+		if err != nil {
+			fmt.Printf("Main: Cannot serve gRPC task service: %v\n", err)
+			return
+		}
+
+		grpcServer = grpc.NewServer()
+		taskservice.RegisterTaskServiceServer(grpcServer, taskservice.NewServer(queue, registry))
+		go func() {
+			fmt.Printf("Main: Serving gRPC task service on %v\n", *grpcAddress)
+			if err := grpcServer.Serve(listener); err != nil {
+				fmt.Printf("Main: gRPC task service failed: %v\n", err)
+			}
+		}()
+	}
 
 	fmt.Printf("\nMain: Starting 1_channels loop\n")
 	for {
-		fmt.Print("\nMain:Provide text, or 'quit' to finish: \n")
+		fmt.Print("\nMain:Provide task name (count, child, quickie), task JSON like {\"type\":\"count\",\"params\":{\"countLimit\":3}}, or 'quit' to finish: \n")
 		value, err := reader.ReadString('\n')
 		if err != nil {
 			fmt.Print("Main: Something went really wrong...")
 			return
 		}
 
-		// Let's remove last character (which is always end line '\n')
-		trimmedValue := value[:len(value)-2]
+		// Let's remove the line ending, '\n' or '\r\n' depending on the terminal
+		trimmedValue := strings.TrimSpace(value)
 		if trimmedValue == "quit" {
 			break
-		} else if trimmedValue == "count" {
-			queue.Push(executor.NewExecutableCounterWithSleep(10, time.Duration(500)*time.Millisecond))
-		} else if trimmedValue == "child" {
-			queue.Push(executor.NewExecutableAnnoyingKid())
-		} else if trimmedValue == "quickie" {
-			queue.Push(executor.NewExecutableQuickie())
 		}
+
+		var task executor.Task
+		if strings.HasPrefix(trimmedValue, "{") {
+			task, err = registry.ParseTask([]byte(trimmedValue))
+		} else {
+			task, err = registry.NewTask(executor.TaskSpec{Type: trimmedValue})
+		}
+		if err != nil {
+			fmt.Printf("Main: Invalid task: %v\n", err)
+			continue
+		}
+
+		if _, isKid := task.TaskExecutable.(*executor.ExecutableAnnoyingKid); isKid && task.Retry == nil {
+			// kid fails every now and then, let's give it another chance (or two)
+			task.Retry = &executor.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second, Jitter: 0.2}
+		}
+		if _, err := queue.Push(task); err != nil {
+			fmt.Printf("Main: Cannot push task: %v\n", err)
+		}
+	}
+
+	// Let the tasks that are already queued finish, but don't wait forever
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if httpServer != nil {
+		// event streams end once the queue stops, they are not waited for
+		_ = httpServer.Close()
+	}
+	if grpcServer != nil {
+		// watches end once the queue stops, they are not waited for
+		grpcServer.Stop()
+	}
+	if err := taskExecutor.Shutdown(ctx); err != nil {
+		fmt.Printf("Main: Tasks did not finish in time (%v), stopping them\n", err)
+		taskExecutor.Stop()
 	}
 
 	fmt.Print("Thank You and goodbye!") // Graceful finish :))
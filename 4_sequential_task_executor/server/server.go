@@ -0,0 +1,240 @@
+package server
+
+import (
+	"AwesomePresentation/4_sequential_task_executor/executor"
+	"AwesomePresentation/logging"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel/propagation"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Largest accepted task spec
+const maxRequestBodySize = 1 << 20
+
+// Server exposes `TaskQueue` over HTTP/JSON:
+//
+//	POST   /tasks       pushes task described by `executor.TaskSpec`, responds with its id
+//	GET    /tasks       lists all tasks known to the queue
+//	GET    /tasks/{id}  fetches single task
+//	DELETE /tasks/{id}  cancels the task
+//	GET    /events      server-sent events stream of task state changes
+//
+// `Idempotency-Key` header of the push is used as `idempotencyKey` of the task, unless the spec sets one.
+// W3C `traceparent` header of the push becomes trace context of the task, see `executor.Task.TraceContext`.
+type Server struct {
+	queue    executor.TaskQueue
+	registry *executor.ExecutableRegistry
+	mux      *http.ServeMux
+	logger   logging.Logger
+}
+
+// NewServer creates handler serving the queue. Tasks are built by the registry, so only registered
+// executables can be pushed. Nil logger means nothing is logged.
+func NewServer(queue executor.TaskQueue, registry *executor.ExecutableRegistry, logger logging.Logger) *Server {
+	server := &Server{
+		queue:    queue,
+		registry: registry,
+		mux:      http.NewServeMux(),
+		logger:   logging.OrNop(logger),
+	}
+
+	server.mux.HandleFunc("POST /tasks", server.pushTask)
+	server.mux.HandleFunc("GET /tasks", server.listTasks)
+	server.mux.HandleFunc("GET /tasks/{id}", server.getTask)
+	server.mux.HandleFunc("DELETE /tasks/{id}", server.cancelTask)
+	server.mux.HandleFunc("GET /events", server.streamEvents)
+
+	return server
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// taskResponse is JSON form of `executor.TaskInfo` - errors are turned into their messages
+type taskResponse struct {
+	Id             string      `json:"id"`
+	State          string      `json:"state"`
+	Priority       int         `json:"priority"`
+	PartitionKey   string      `json:"partitionKey,omitempty"`
+	IdempotencyKey string      `json:"idempotencyKey,omitempty"`
+	EnqueuedAt     *time.Time  `json:"enqueuedAt,omitempty"`
+	DueAt          *time.Time  `json:"dueAt,omitempty"`
+	StartedAt      *time.Time  `json:"startedAt,omitempty"`
+	FinishedAt     *time.Time  `json:"finishedAt,omitempty"`
+	Error          string      `json:"error,omitempty"`
+	DeadLettered   bool        `json:"deadLettered,omitempty"`
+	Attempts       int         `json:"attempts"`
+	LastError      string      `json:"lastError,omitempty"`
+	DependsOn      []string    `json:"dependsOn,omitempty"`
+	BlockedOn      []string    `json:"blockedOn,omitempty"`
+	Worker         int         `json:"worker,omitempty"`
+	Result         interface{} `json:"result,omitempty"`
+}
+
+type pushResponse struct {
+	Id string `json:"id"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func newTaskResponse(info executor.TaskInfo) taskResponse {
+	return taskResponse{
+		Id:             info.Id,
+		State:          info.State.String(),
+		Priority:       info.Priority,
+		PartitionKey:   info.PartitionKey,
+		IdempotencyKey: info.IdempotencyKey,
+		EnqueuedAt:     optionalTime(info.EnqueuedAt),
+		DueAt:          optionalTime(info.DueAt),
+		StartedAt:      optionalTime(info.StartedAt),
+		FinishedAt:     optionalTime(info.FinishedAt),
+		Error:          errorMessage(info.Error),
+		DeadLettered:   info.DeadLettered,
+		Attempts:       info.Attempts,
+		LastError:      errorMessage(info.LastError),
+		DependsOn:      info.DependsOn,
+		BlockedOn:      info.BlockedOn,
+		Worker:         info.Worker,
+		Result:         info.Result,
+	}
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func (s *Server) pushTask(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("failed to read request: %w", err))
+		return
+	}
+
+	task, err := s.registry.ParseTask(body)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if task.IdempotencyKey == "" {
+		task.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	}
+	// task joins the trace of the request, if it has `traceparent` header
+	task = task.WithTraceContext(propagation.TraceContext{}.Extract(r.Context(), propagation.HeaderCarrier(r.Header)))
+
+	id, err := s.queue.Push(task)
+	if errors.Is(err, executor.ErrQueueClosed) {
+		s.writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	if errors.Is(err, executor.ErrQueueFull) {
+		s.writeError(w, http.StatusTooManyRequests, err)
+		return
+	}
+	if errors.Is(err, executor.ErrUnknownDependency) {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Location", "/tasks/"+id)
+	s.writeJSON(w, http.StatusCreated, pushResponse{Id: id})
+}
+
+func (s *Server) listTasks(w http.ResponseWriter, r *http.Request) {
+	tasks := s.queue.List()
+
+	response := make([]taskResponse, 0, len(tasks))
+	for _, info := range tasks {
+		response = append(response, newTaskResponse(info))
+	}
+
+	s.writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) getTask(w http.ResponseWriter, r *http.Request) {
+	info := s.queue.Get(r.PathValue("id"))
+	if info == nil {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("task %v not found", r.PathValue("id")))
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, newTaskResponse(*info))
+}
+
+func (s *Server) cancelTask(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if s.queue.Cancel(id) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// queue doesn't tell why the task couldn't be cancelled
+	if info := s.queue.Get(id); info != nil {
+		s.writeError(w, http.StatusConflict, fmt.Errorf("task %v has already finished", id))
+		return
+	}
+	s.writeError(w, http.StatusNotFound, fmt.Errorf("task %v not found", id))
+}
+
+// Streams every change of task state as `task` event, until the client goes away or the queue stops
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	events := s.queue.Subscribe(r.Context())
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for info := range events {
+		data, err := json.Marshal(newTaskResponse(info))
+		if err != nil {
+			s.logger.Error("failed to serialize task", logging.TaskId(info.Id), logging.Err(err))
+			continue
+		}
+
+		if _, err := fmt.Fprintf(w, "event: task\ndata: %s\n\n", data); err != nil {
+			// client has gone away, its context is done as well
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.logger.Warn("failed to write response", logging.Err(err))
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	s.writeJSON(w, status, errorResponse{Error: err.Error()})
+}
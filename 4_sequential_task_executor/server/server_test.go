@@ -0,0 +1,241 @@
+package server
+
+import (
+	"AwesomePresentation/4_sequential_task_executor/executor"
+	"AwesomePresentation/tracing/tracingtest"
+	"bufio"
+	"encoding/json"
+	"gotest.tools/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T) (executor.TaskQueue, *httptest.Server) {
+	queue := executor.NewLockingTaskQueue()
+	httpServer := httptest.NewServer(NewServer(queue, executor.NewDefaultExecutableRegistry(), nil))
+
+	t.Cleanup(func() {
+		queue.Stop()
+		httpServer.Close()
+	})
+	return queue, httpServer
+}
+
+func pushTask(t *testing.T, httpServer *httptest.Server, spec string) (int, string) {
+	t.Helper()
+
+	response, err := http.Post(httpServer.URL+"/tasks", "application/json", strings.NewReader(spec))
+	assert.NilError(t, err)
+	defer response.Body.Close()
+
+	pushed := pushResponse{}
+	_ = json.NewDecoder(response.Body).Decode(&pushed)
+	return response.StatusCode, pushed.Id
+}
+
+func TestPushTask(t *testing.T) {
+	tests := []struct {
+		name           string
+		spec           string
+		expectedStatus int
+	}{
+		{name: "valid task", spec: `{"type":"count","params":{"countLimit":3}}`, expectedStatus: http.StatusCreated},
+		{name: "unknown type", spec: `{"type":"nap"}`, expectedStatus: http.StatusBadRequest},
+		{name: "invalid json", spec: `{"type":`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queue, httpServer := newTestServer(t)
+
+			status, id := pushTask(t, httpServer, test.spec)
+			assert.Equal(t, test.expectedStatus, status)
+			if status == http.StatusCreated {
+				assert.Equal(t, executor.TaskQueued, queue.Get(id).State)
+			}
+		})
+	}
+}
+
+func TestPushTaskToClosedQueue(t *testing.T) {
+	queue, httpServer := newTestServer(t)
+	queue.Stop()
+
+	status, _ := pushTask(t, httpServer, `{"type":"quickie"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+}
+
+func TestPushTaskWithIdempotencyKey(t *testing.T) {
+	queue, httpServer := newTestServer(t)
+
+	push := func(spec string) string {
+		request, err := http.NewRequest(http.MethodPost, httpServer.URL+"/tasks", strings.NewReader(spec))
+		assert.NilError(t, err)
+		request.Header.Set("Idempotency-Key", "order-42")
+
+		response, err := http.DefaultClient.Do(request)
+		assert.NilError(t, err)
+		defer response.Body.Close()
+		assert.Equal(t, http.StatusCreated, response.StatusCode)
+
+		pushed := pushResponse{}
+		assert.NilError(t, json.NewDecoder(response.Body).Decode(&pushed))
+		return pushed.Id
+	}
+
+	id := push(`{"type":"quickie"}`)
+	assert.Equal(t, id, push(`{"type":"quickie"}`))
+	assert.Equal(t, "order-42", queue.Get(id).IdempotencyKey)
+
+	// key in the spec wins over the header
+	assert.Check(t, push(`{"type":"quickie","idempotencyKey":"order-43"}`) != id)
+	assert.Equal(t, 2, len(queue.List()))
+}
+
+func TestPushTaskWithTraceContext(t *testing.T) {
+	provider, exporter := tracingtest.NewInMemoryProvider()
+	queue := executor.NewLockingTaskQueue(executor.WithTracerProvider(provider))
+	httpServer := httptest.NewServer(NewServer(queue, executor.NewDefaultExecutableRegistry(), nil))
+	defer httpServer.Close()
+	defer queue.Stop()
+
+	request, err := http.NewRequest(http.MethodPost, httpServer.URL+"/tasks", strings.NewReader(`{"type":"quickie"}`))
+	assert.NilError(t, err)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	response, err := http.DefaultClient.Do(request)
+	assert.NilError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	queue.Pop()
+
+	// waiting in the queue is part of the caller's trace
+	spans := exporter.GetSpans()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent.SpanID().String())
+}
+
+func TestGetAndListTasks(t *testing.T) {
+	queue, httpServer := newTestServer(t)
+
+	_, firstId := pushTask(t, httpServer, `{"type":"quickie"}`)
+	_, secondId := pushTask(t, httpServer, `{"type":"quickie","priority":3}`)
+	queue.Pop()
+	queue.Complete(firstId, nil)
+
+	response, err := http.Get(httpServer.URL + "/tasks")
+	assert.NilError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	tasks := make([]taskResponse, 0)
+	assert.NilError(t, json.NewDecoder(response.Body).Decode(&tasks))
+	assert.Equal(t, 2, len(tasks))
+	assert.Equal(t, firstId, tasks[0].Id)
+	assert.Equal(t, "Succeeded", tasks[0].State)
+	assert.Equal(t, secondId, tasks[1].Id)
+	assert.Equal(t, "Queued", tasks[1].State)
+	assert.Equal(t, 3, tasks[1].Priority)
+
+	response, err = http.Get(httpServer.URL + "/tasks/" + secondId)
+	assert.NilError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	task := taskResponse{}
+	assert.NilError(t, json.NewDecoder(response.Body).Decode(&task))
+	assert.Equal(t, secondId, task.Id)
+
+	response, err = http.Get(httpServer.URL + "/tasks/unknown")
+	assert.NilError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestCancelTask(t *testing.T) {
+	queue, httpServer := newTestServer(t)
+
+	_, finishedId := pushTask(t, httpServer, `{"type":"quickie"}`)
+	queue.Pop()
+	queue.Complete(finishedId, nil)
+	_, queuedId := pushTask(t, httpServer, `{"type":"quickie"}`)
+
+	tests := []struct {
+		name           string
+		id             string
+		expectedStatus int
+	}{
+		{name: "queued task", id: queuedId, expectedStatus: http.StatusNoContent},
+		{name: "finished task", id: finishedId, expectedStatus: http.StatusConflict},
+		{name: "unknown task", id: "unknown", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodDelete, httpServer.URL+"/tasks/"+test.id, nil)
+			assert.NilError(t, err)
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NilError(t, err)
+			defer response.Body.Close()
+			assert.Equal(t, test.expectedStatus, response.StatusCode)
+		})
+	}
+
+	assert.Equal(t, executor.TaskCancelled, queue.Get(queuedId).State)
+}
+
+func TestStreamEvents(t *testing.T) {
+	queue, httpServer := newTestServer(t)
+
+	response, err := http.Get(httpServer.URL + "/events")
+	assert.NilError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	_, id := pushTask(t, httpServer, `{"type":"quickie"}`)
+	queue.Pop()
+	queue.Complete(id, nil)
+
+	events := make(chan taskResponse)
+	go func() {
+		defer close(events)
+
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			data, found := strings.CutPrefix(scanner.Text(), "data: ")
+			if !found {
+				continue
+			}
+
+			event := taskResponse{}
+			if json.Unmarshal([]byte(data), &event) == nil {
+				events <- event
+			}
+		}
+	}()
+
+	for _, expectedState := range []string{"Queued", "Running", "Succeeded"} {
+		select {
+		case event := <-events:
+			assert.Equal(t, id, event.Id)
+			assert.Equal(t, expectedState, event.State)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %v event received", expectedState)
+		}
+	}
+
+	// stream ends once the queue stops
+	queue.Stop()
+	select {
+	case _, open := <-events:
+		assert.Check(t, !open)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not end")
+	}
+}
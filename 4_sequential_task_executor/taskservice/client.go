@@ -0,0 +1,353 @@
+package taskservice
+
+import (
+	"AwesomePresentation/4_sequential_task_executor/executor"
+	"AwesomePresentation/logging"
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Returned by `Client.PopContext` - tasks pushed to remote queue are executed by the server
+var ErrNotSupported = errors.New("operation is not supported by remote task queue")
+
+// Client is `executor.TaskQueue` backed by a remote `TaskService`, so it can be used wherever local queue
+// is. Tasks are sent as specs, only executables known to the registry (on both sides) can be pushed.
+//
+// Tasks are executed by the server, thus `Pop` never returns a task and `Complete` does nothing.
+// Errors of remote tasks keep only their messages and results are decoded from JSON into generic values.
+type Client struct {
+	service  TaskServiceClient
+	conn     *grpc.ClientConn
+	registry *executor.ExecutableRegistry
+
+	closed atomic.Bool
+	// Watches of submitted tasks, `Shutdown` waits for them
+	watches sync.WaitGroup
+
+	logger logging.Logger
+}
+
+// NewClient creates queue talking to the service over the connection. Client owns the connection, it's closed
+// by `Shutdown` or `Stop` - remote queue keeps running. Failed calls which can't return an error are logged,
+// nil logger means they are not.
+func NewClient(conn *grpc.ClientConn, registry *executor.ExecutableRegistry, logger logging.Logger) *Client {
+	return &Client{
+		service:  NewTaskServiceClient(conn),
+		conn:     conn,
+		registry: registry,
+		logger:   logging.OrNop(logger),
+	}
+}
+
+// Pop always returns nil, see `Client`
+func (c *Client) Pop() *executor.Task {
+	return nil
+}
+
+// PopContext always returns `ErrNotSupported`, see `Client`
+func (c *Client) PopContext(ctx context.Context) (*executor.Task, error) {
+	return nil, ErrNotSupported
+}
+
+func (c *Client) Push(task executor.Task) (string, error) {
+	return c.push(task, nil)
+}
+
+func (c *Client) PushAt(task executor.Task, dueAt time.Time) (string, error) {
+	return c.push(task, timestamppb.New(dueAt))
+}
+
+func (c *Client) PushAfter(task executor.Task, delay time.Duration) (string, error) {
+	return c.PushAt(task, time.Now().Add(delay))
+}
+
+func (c *Client) push(task executor.Task, dueAt *timestamppb.Timestamp) (string, error) {
+	ctx, request, err := c.pushRequest(task, dueAt)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := c.service.Push(ctx, request)
+	if err != nil {
+		return "", queueError(err)
+	}
+
+	return response.GetId(), nil
+}
+
+// Returns request pushing the task, with context carrying the task's trace context
+func (c *Client) pushRequest(task executor.Task, dueAt *timestamppb.Timestamp) (context.Context, *PushRequest, error) {
+	if c.closed.Load() {
+		return nil, nil, executor.ErrQueueClosed
+	}
+
+	spec, err := c.registry.Spec(task)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// server joins the trace of the task, see `executor.Task.TraceContext`
+	ctx := withTraceContext(context.Background(), task.TraceContext)
+	return ctx, &PushRequest{Spec: newProtoSpec(spec), DueAt: dueAt}, nil
+}
+
+// Submit pushes the task and watches it until it finishes. The server subscribes before pushing, so the final
+// state arrives even if the remote queue forgets the task right away. If the task can't be watched anymore
+// (e.g. the connection is lost), the handle is finished with that error and the last known state of the task.
+func (c *Client) Submit(task executor.Task) (*executor.TaskHandle, error) {
+	ctx, request, err := c.pushRequest(task, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := c.service.Submit(ctx, request)
+	if err != nil {
+		return nil, queueError(err)
+	}
+
+	// the first change carries id of the pushed task, or the push has failed
+	message, err := stream.Recv()
+	if err != nil {
+		return nil, queueError(err)
+	}
+	first, err := newTaskInfo(message)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, finish := executor.NewTaskHandle(first.Id)
+	c.watches.Add(1)
+	go func() {
+		defer c.watches.Done()
+		if first.State.IsFinished() {
+			finish(first)
+			return
+		}
+		finish(c.watchTask(first, stream))
+	}()
+
+	return handle, nil
+}
+
+// Receives changes of the task until it finishes, returns its final state. Stream which ends early is
+// followed by fetching the task once more, in case it has finished in the meantime.
+func (c *Client) watchTask(last executor.TaskInfo, stream grpc.ServerStreamingClient[TaskInfo]) executor.TaskInfo {
+	id := last.Id
+	for {
+		message, err := stream.Recv()
+		if err != nil {
+			if info := c.Get(id); info != nil && info.State.IsFinished() {
+				return *info
+			}
+
+			if err == io.EOF {
+				err = fmt.Errorf("watch has ended before the task has finished")
+			}
+			last.Error = fmt.Errorf("lost track of task %v: %w", id, queueError(err))
+			return last
+		}
+
+		info, err := newTaskInfo(message)
+		if err != nil {
+			last.Error = err
+			return last
+		}
+
+		last = info
+		if last.State.IsFinished() {
+			return last
+		}
+	}
+}
+
+// Complete does nothing, tasks are completed by the server, see `Client`
+func (c *Client) Complete(id string, err error) {
+}
+
+func (c *Client) Cancel(id string) bool {
+	response, err := c.service.Cancel(context.Background(), &CancelRequest{Id: id})
+	if err != nil {
+		c.logger.Error("failed to cancel task", logging.TaskId(id), logging.Err(err))
+		return false
+	}
+
+	return response.GetCancelled()
+}
+
+// List returns nil if the service can't be reached
+func (c *Client) List() []executor.TaskInfo {
+	response, err := c.service.List(context.Background(), &ListRequest{})
+	if err != nil {
+		c.logger.Error("failed to list tasks", logging.Err(err))
+		return nil
+	}
+
+	return c.taskInfos(response)
+}
+
+// Get returns nil if the task wasn't found, or the service can't be reached
+func (c *Client) Get(id string) *executor.TaskInfo {
+	message, err := c.service.Get(context.Background(), &GetRequest{Id: id})
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	if err != nil {
+		c.logger.Error("failed to get task", logging.TaskId(id), logging.Err(err))
+		return nil
+	}
+
+	info, err := newTaskInfo(message)
+	if err != nil {
+		c.logger.Error("invalid task info", logging.Err(err))
+		return nil
+	}
+	return &info
+}
+
+// DeadLetters returns nil if the service can't be reached
+func (c *Client) DeadLetters() []executor.TaskInfo {
+	response, err := c.service.DeadLetters(context.Background(), &ListRequest{})
+	if err != nil {
+		c.logger.Error("failed to list dead letters", logging.Err(err))
+		return nil
+	}
+
+	return c.taskInfos(response)
+}
+
+func (c *Client) taskInfos(response *ListResponse) []executor.TaskInfo {
+	tasks := make([]executor.TaskInfo, 0, len(response.GetTasks()))
+	for _, message := range response.GetTasks() {
+		info, err := newTaskInfo(message)
+		if err != nil {
+			c.logger.Error("invalid task info", logging.Err(err))
+			continue
+		}
+		tasks = append(tasks, info)
+	}
+
+	return tasks
+}
+
+func (c *Client) Requeue(id string) error {
+	if _, err := c.service.Requeue(context.Background(), &RequeueRequest{Id: id}); err != nil {
+		return queueError(err)
+	}
+	return nil
+}
+
+// Subscribe streams changes of all tasks of the remote queue. Channel is closed once the context is done,
+// the remote queue stops or the connection is lost.
+func (c *Client) Subscribe(ctx context.Context) <-chan executor.TaskInfo {
+	events := make(chan executor.TaskInfo)
+
+	stream, err := c.service.Watch(ctx, &WatchRequest{})
+	if err == nil {
+		// server sends headers once it has subscribed, changes made after `Subscribe` returns are not missed
+		_, err = stream.Header()
+	}
+	if err != nil {
+		c.logger.Error("failed to watch tasks", logging.Err(err))
+		close(events)
+		return events
+	}
+
+	go func() {
+		defer close(events)
+
+		for {
+			message, err := stream.Recv()
+			if err != nil {
+				return
+			}
+
+			info, err := newTaskInfo(message)
+			if err != nil {
+				c.logger.Error("invalid task info", logging.Err(err))
+				continue
+			}
+
+			select {
+			case events <- info:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events
+}
+
+// Stats returns zero value if the service can't be reached
+func (c *Client) Stats() executor.QueueStats {
+	stats, err := c.service.Stats(context.Background(), &StatsRequest{})
+	if err != nil {
+		c.logger.Error("failed to get queue stats", logging.Err(err))
+		return executor.QueueStats{}
+	}
+
+	return executor.QueueStats{
+		Waiting:  int(stats.GetWaiting()),
+		Running:  int(stats.GetRunning()),
+		Capacity: int(stats.GetCapacity()),
+		Rejected: stats.GetRejected(),
+		Dropped:  stats.GetDropped(),
+	}
+}
+
+// Shutdown waits until submitted tasks finish and closes the connection. Remote queue keeps running.
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.closed.Store(true)
+
+	watched := make(chan struct{})
+	go func() {
+		c.watches.Wait()
+		close(watched)
+	}()
+
+	select {
+	case <-watched:
+		return c.conn.Close()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop closes the connection right away, handles of submitted tasks which haven't finished yet are finished
+// with the connection error. Remote queue keeps running.
+func (c *Client) Stop() {
+	c.closed.Store(true)
+	_ = c.conn.Close()
+}
+
+// Maps status codes of `Server` back to errors of the queue
+func queueError(err error) error {
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	switch {
+	case s.Code() == codes.Unavailable && s.Message() == executor.ErrQueueClosed.Error():
+		return executor.ErrQueueClosed
+	case s.Code() == codes.ResourceExhausted && s.Message() == executor.ErrQueueFull.Error():
+		return executor.ErrQueueFull
+	case s.Code() == codes.FailedPrecondition && s.Message() == executor.ErrNotDeadLettered.Error():
+		return executor.ErrNotDeadLettered
+	case s.Code() == codes.InvalidArgument && strings.HasPrefix(s.Message(), executor.ErrUnknownDependency.Error()):
+		// message names the dependency as well
+		return fmt.Errorf("%w%v", executor.ErrUnknownDependency, strings.TrimPrefix(s.Message(), executor.ErrUnknownDependency.Error()))
+	default:
+		return err
+	}
+}
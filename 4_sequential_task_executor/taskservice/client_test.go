@@ -0,0 +1,279 @@
+package taskservice
+
+import (
+	"AwesomePresentation/4_sequential_task_executor/executor"
+	"AwesomePresentation/tracing/tracingtest"
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"gotest.tools/assert"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Appends its value to `collected`, the slice lives in the test process just like the server
+type collectingExecutable struct {
+	Value int
+}
+
+var (
+	collected     = make([]int, 0)
+	collectedLock = sync.Mutex{}
+)
+
+func (e *collectingExecutable) Execute() error {
+	collectedLock.Lock()
+	defer collectedLock.Unlock()
+
+	collected = append(collected, e.Value)
+	return nil
+}
+
+type failingExecutable struct{}
+
+func (e *failingExecutable) Execute() error {
+	return errors.New("failed on purpose")
+}
+
+func newTestRegistry(t *testing.T) *executor.ExecutableRegistry {
+	registry := executor.NewDefaultExecutableRegistry()
+	assert.NilError(t, registry.Register("collect", func() executor.Executable { return &collectingExecutable{} }))
+	assert.NilError(t, registry.Register("fail", func() executor.Executable { return &failingExecutable{} }))
+	return registry
+}
+
+// Serves the queue over in-memory connection, returns client of the served queue
+func newTestClient(t *testing.T, queue executor.TaskQueue) *Client {
+	registry := newTestRegistry(t)
+
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	RegisterTaskServiceServer(grpcServer, NewServer(queue, registry))
+	go func() {
+		_ = grpcServer.Serve(listener)
+	}()
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NilError(t, err)
+
+	client := NewClient(conn, registry, nil)
+	t.Cleanup(func() {
+		client.Stop()
+		grpcServer.Stop()
+	})
+	return client
+}
+
+func TestThreadSafetyClient(t *testing.T) {
+	queue, taskExecutor := executor.NewLockingQueueExecutor()
+	defer taskExecutor.Stop()
+
+	client := newTestClient(t, queue)
+	collected = make([]int, 0)
+
+	iterations := 100
+	handles := make([]*executor.TaskHandle, 0, iterations)
+	handlesLock := sync.Mutex{}
+
+	operations := make([]executor.ExecutableTestOperation, 0, iterations)
+	for i := 0; i < iterations; i++ {
+		value := i
+		operations = append(operations, func() error {
+			handle, err := client.Submit(executor.Task{TaskExecutable: &collectingExecutable{Value: value}})
+			if err != nil {
+				return err
+			}
+
+			client.List()
+			client.Get(handle.Id())
+
+			handlesLock.Lock()
+			handles = append(handles, handle)
+			handlesLock.Unlock()
+			return nil
+		})
+	}
+
+	for _, err := range executor.ParallelOperationsExecutor(t, 3, operations) {
+		assert.NilError(t, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.Equal(t, iterations, len(handles))
+	for _, handle := range handles {
+		assert.NilError(t, handle.Wait(ctx))
+		assert.Equal(t, executor.TaskSucceeded, handle.Info().State)
+	}
+	assert.Equal(t, iterations, len(collected))
+}
+
+func TestClientTaskLifecycle(t *testing.T) {
+	queue := executor.NewLockingTaskQueue()
+	defer queue.Stop()
+	client := newTestClient(t, queue)
+
+	firstId, err := client.Push(executor.Task{TaskExecutable: &collectingExecutable{Value: 1}, Priority: 2})
+	assert.NilError(t, err)
+	dueAt := time.Now().Add(time.Hour)
+	secondId, err := client.PushAt(executor.Task{TaskExecutable: &collectingExecutable{Value: 2}}, dueAt)
+	assert.NilError(t, err)
+
+	first := client.Get(firstId)
+	assert.Assert(t, first != nil)
+	assert.Equal(t, executor.TaskQueued, first.State)
+	assert.Equal(t, 2, first.Priority)
+
+	second := client.Get(secondId)
+	assert.Assert(t, second != nil)
+	assert.Equal(t, executor.TaskScheduled, second.State)
+	assert.Check(t, second.DueAt.Equal(dueAt))
+
+	assert.Assert(t, client.Get("unknown") == nil)
+	assert.Equal(t, 2, client.Stats().Waiting)
+
+	queue.Pop()
+	queue.Complete(firstId, errors.New("failed on purpose"))
+
+	tasks := client.List()
+	assert.Equal(t, 2, len(tasks))
+	assert.Equal(t, executor.TaskFailed, tasks[0].State)
+	assert.Error(t, tasks[0].Error, "failed on purpose")
+
+	assert.Check(t, client.Cancel(secondId))
+	assert.Check(t, !client.Cancel(secondId))
+	assert.Equal(t, executor.TaskCancelled, client.Get(secondId).State)
+}
+
+func TestClientPushErrors(t *testing.T) {
+	queue := executor.NewLockingTaskQueue()
+	client := newTestClient(t, queue)
+
+	_, err := client.Push(executor.Task{TaskExecutable: &executor.ExecutableQuickie{}})
+	assert.NilError(t, err)
+
+	// registry of the server doesn't know it
+	_, err = client.service.Push(context.Background(), &PushRequest{Spec: &TaskSpec{Type: "nap"}})
+	assert.ErrorContains(t, err, "executable type is not registered")
+
+	queue.Stop()
+	_, err = client.Push(executor.Task{TaskExecutable: &executor.ExecutableQuickie{}})
+	assert.Check(t, errors.Is(err, executor.ErrQueueClosed))
+
+	client.Stop()
+	_, err = client.Push(executor.Task{TaskExecutable: &executor.ExecutableQuickie{}})
+	assert.Check(t, errors.Is(err, executor.ErrQueueClosed))
+}
+
+func TestClientSubmitForgottenTask(t *testing.T) {
+	// finished tasks are forgotten right away, the handle learns how they ended anyway
+	queue, taskExecutor := executor.NewLockingQueueExecutor(executor.WithFinishedTaskRetention(0),
+		executor.WithDeadLetterRetention(0))
+	defer taskExecutor.Stop()
+	client := newTestClient(t, queue)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	succeeding, err := client.Submit(executor.Task{TaskExecutable: &collectingExecutable{Value: 3}})
+	assert.NilError(t, err)
+	assert.NilError(t, succeeding.Wait(ctx))
+	assert.Equal(t, executor.TaskSucceeded, succeeding.Info().State)
+
+	failing, err := client.Submit(executor.Task{TaskExecutable: &failingExecutable{}})
+	assert.NilError(t, err)
+	assert.Error(t, failing.Wait(ctx), "failed on purpose")
+	assert.Equal(t, executor.TaskFailed, failing.Info().State)
+	assert.Assert(t, client.Get(failing.Id()) == nil)
+
+	queue.Stop()
+	_, err = client.Submit(executor.Task{TaskExecutable: &collectingExecutable{Value: 4}})
+	assert.Check(t, errors.Is(err, executor.ErrQueueClosed))
+}
+
+func TestClientDeadLetters(t *testing.T) {
+	queue, taskExecutor := executor.NewLockingQueueExecutor()
+	defer taskExecutor.Stop()
+	client := newTestClient(t, queue)
+
+	handle, err := client.Submit(executor.Task{TaskExecutable: &failingExecutable{}})
+	assert.NilError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Error(t, handle.Wait(ctx), "failed on purpose")
+
+	deadLetters := client.DeadLetters()
+	assert.Equal(t, 1, len(deadLetters))
+	assert.Equal(t, handle.Id(), deadLetters[0].Id)
+	assert.Check(t, deadLetters[0].DeadLettered)
+
+	assert.NilError(t, client.Requeue(handle.Id()))
+	assert.Check(t, errors.Is(client.Requeue("unknown"), executor.ErrNotDeadLettered))
+}
+
+func TestClientSubscribe(t *testing.T) {
+	queue := executor.NewLockingTaskQueue()
+	defer queue.Stop()
+	client := newTestClient(t, queue)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := client.Subscribe(ctx)
+
+	id, err := client.Push(executor.Task{TaskExecutable: &executor.ExecutableQuickie{}})
+	assert.NilError(t, err)
+	queue.Pop()
+	queue.Complete(id, nil)
+
+	for _, expectedState := range []executor.TaskState{executor.TaskQueued, executor.TaskRunning, executor.TaskSucceeded} {
+		select {
+		case info := <-events:
+			assert.Equal(t, id, info.Id)
+			assert.Equal(t, expectedState, info.State)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %v event received", expectedState)
+		}
+	}
+
+	// stream ends once the queue stops
+	queue.Stop()
+	select {
+	case _, open := <-events:
+		assert.Check(t, !open)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not end")
+	}
+}
+
+func TestClientPushPropagatesTraceContext(t *testing.T) {
+	provider, exporter := tracingtest.NewInMemoryProvider()
+	queue := executor.NewLockingTaskQueue(executor.WithTracerProvider(provider))
+	defer queue.Stop()
+	client := newTestClient(t, queue)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	parent.End()
+	_, err := client.Push(executor.Task{TaskExecutable: &executor.ExecutableQuickie{}}.WithTraceContext(ctx))
+	assert.NilError(t, err)
+	queue.Pop()
+
+	// waiting in the remote queue is part of the trace
+	spans := exporter.GetSpans()
+	assert.Equal(t, 2, len(spans))
+	assert.Equal(t, "executor.wait", spans[1].Name)
+	assert.Equal(t, parent.SpanContext().TraceID(), spans[1].SpanContext.TraceID())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[1].Parent.SpanID())
+	assert.Check(t, spans[1].Parent.IsRemote())
+}
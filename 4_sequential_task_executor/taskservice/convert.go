@@ -0,0 +1,168 @@
+package taskservice
+
+import (
+	"AwesomePresentation/4_sequential_task_executor/executor"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+func newProtoSpec(spec executor.TaskSpec) *TaskSpec {
+	result := &TaskSpec{
+		Type:     spec.Type,
+		Params:   string(spec.Params),
+		Priority: int32(spec.Priority),
+		Retry:    newProtoRetryPolicy(spec.Retry),
+
+		PartitionKey:        spec.PartitionKey,
+		IdempotencyKey:      spec.IdempotencyKey,
+		DependsOn:           spec.DependsOn,
+		OnDependencyFailure: DependencyFailurePolicy(spec.OnDependencyFailure),
+	}
+	if spec.Timeout > 0 {
+		result.Timeout = durationpb.New(time.Duration(spec.Timeout))
+	}
+	return result
+}
+
+func newSpec(spec *TaskSpec) executor.TaskSpec {
+	result := executor.TaskSpec{
+		Type:     spec.GetType(),
+		Priority: int(spec.GetPriority()),
+		Retry:    newRetryPolicy(spec.GetRetry()),
+
+		PartitionKey:        spec.GetPartitionKey(),
+		IdempotencyKey:      spec.GetIdempotencyKey(),
+		DependsOn:           spec.GetDependsOn(),
+		OnDependencyFailure: executor.DependencyFailurePolicy(spec.GetOnDependencyFailure()),
+		Timeout:             executor.Duration(spec.GetTimeout().AsDuration()),
+	}
+	if spec.GetParams() != "" {
+		result.Params = json.RawMessage(spec.GetParams())
+	}
+	return result
+}
+
+func newProtoRetryPolicy(policy *executor.RetryPolicy) *RetryPolicy {
+	if policy == nil {
+		return nil
+	}
+
+	return &RetryPolicy{
+		MaxAttempts:    int32(policy.MaxAttempts),
+		InitialBackoff: durationpb.New(policy.InitialBackoff),
+		MaxBackoff:     durationpb.New(policy.MaxBackoff),
+		Multiplier:     policy.Multiplier,
+		Jitter:         policy.Jitter,
+	}
+}
+
+func newRetryPolicy(policy *RetryPolicy) *executor.RetryPolicy {
+	if policy == nil {
+		return nil
+	}
+
+	return &executor.RetryPolicy{
+		MaxAttempts:    int(policy.GetMaxAttempts()),
+		InitialBackoff: policy.GetInitialBackoff().AsDuration(),
+		MaxBackoff:     policy.GetMaxBackoff().AsDuration(),
+		Multiplier:     policy.GetMultiplier(),
+		Jitter:         policy.GetJitter(),
+	}
+}
+
+// Errors are sent as their messages and the result as JSON
+func newProtoTaskInfo(info executor.TaskInfo) (*TaskInfo, error) {
+	result := ""
+	if info.Result != nil {
+		data, err := json.Marshal(info.Result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize result of task %v: %w", info.Id, err)
+		}
+		result = string(data)
+	}
+
+	return &TaskInfo{
+		Id:             info.Id,
+		State:          TaskState(info.State),
+		Priority:       int32(info.Priority),
+		EnqueuedAt:     optionalTimestamp(info.EnqueuedAt),
+		DueAt:          optionalTimestamp(info.DueAt),
+		StartedAt:      optionalTimestamp(info.StartedAt),
+		FinishedAt:     optionalTimestamp(info.FinishedAt),
+		Error:          errorMessage(info.Error),
+		Stack:          info.Stack,
+		DeadLettered:   info.DeadLettered,
+		Attempts:       int32(info.Attempts),
+		LastError:      errorMessage(info.LastError),
+		Result:         result,
+		Worker:         int32(info.Worker),
+		PartitionKey:   info.PartitionKey,
+		IdempotencyKey: info.IdempotencyKey,
+		DependsOn:      info.DependsOn,
+		BlockedOn:      info.BlockedOn,
+	}, nil
+}
+
+// Errors can't be restored to their original types, only their messages are kept. Result is decoded from JSON
+// into generic values, so `SubmitWithResult` can't be used with remote queues.
+func newTaskInfo(info *TaskInfo) (executor.TaskInfo, error) {
+	var result interface{}
+	if info.GetResult() != "" {
+		if err := json.Unmarshal([]byte(info.GetResult()), &result); err != nil {
+			return executor.TaskInfo{}, fmt.Errorf("invalid result of task %v: %w", info.GetId(), err)
+		}
+	}
+
+	return executor.TaskInfo{
+		Id:             info.GetId(),
+		State:          executor.TaskState(info.GetState()),
+		Priority:       int(info.GetPriority()),
+		EnqueuedAt:     optionalTime(info.GetEnqueuedAt()),
+		DueAt:          optionalTime(info.GetDueAt()),
+		StartedAt:      optionalTime(info.GetStartedAt()),
+		FinishedAt:     optionalTime(info.GetFinishedAt()),
+		Error:          optionalError(info.GetError()),
+		Stack:          info.GetStack(),
+		DeadLettered:   info.GetDeadLettered(),
+		Attempts:       int(info.GetAttempts()),
+		LastError:      optionalError(info.GetLastError()),
+		Result:         result,
+		Worker:         int(info.GetWorker()),
+		PartitionKey:   info.GetPartitionKey(),
+		IdempotencyKey: info.GetIdempotencyKey(),
+		DependsOn:      info.GetDependsOn(),
+		BlockedOn:      info.GetBlockedOn(),
+	}, nil
+}
+
+func optionalTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func optionalTime(timestamp *timestamppb.Timestamp) time.Time {
+	if timestamp == nil {
+		return time.Time{}
+	}
+	return timestamp.AsTime()
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func optionalError(message string) error {
+	if message == "" {
+		return nil
+	}
+	return errors.New(message)
+}
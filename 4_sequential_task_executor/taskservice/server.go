@@ -0,0 +1,215 @@
+package taskservice
+
+import (
+	"AwesomePresentation/4_sequential_task_executor/executor"
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements `TaskServiceServer` on top of a local queue. Tasks are built by the registry, so only
+// registered executables can be pushed.
+type Server struct {
+	UnimplementedTaskServiceServer
+
+	queue    executor.TaskQueue
+	registry *executor.ExecutableRegistry
+}
+
+func NewServer(queue executor.TaskQueue, registry *executor.ExecutableRegistry) *Server {
+	return &Server{
+		queue:    queue,
+		registry: registry,
+	}
+}
+
+func (s *Server) Push(ctx context.Context, request *PushRequest) (*PushResponse, error) {
+	id, err := s.push(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PushResponse{Id: id}, nil
+}
+
+// Pushes task described by the request, returned error is already a status
+func (s *Server) push(ctx context.Context, request *PushRequest) (string, error) {
+	if request.GetSpec().GetType() == "" {
+		return "", status.Error(codes.InvalidArgument, "invalid task spec: missing type")
+	}
+
+	task, err := s.registry.NewTask(newSpec(request.GetSpec()))
+	if err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	task.TraceContext = traceContextOf(ctx)
+
+	var id string
+	if request.GetDueAt() != nil {
+		id, err = s.queue.PushAt(task, request.GetDueAt().AsTime())
+	} else {
+		id, err = s.queue.Push(task)
+	}
+	if err != nil {
+		return "", statusError(err)
+	}
+	return id, nil
+}
+
+func (s *Server) List(ctx context.Context, request *ListRequest) (*ListResponse, error) {
+	return newListResponse(s.queue.List())
+}
+
+func (s *Server) Get(ctx context.Context, request *GetRequest) (*TaskInfo, error) {
+	info := s.queue.Get(request.GetId())
+	if info == nil {
+		return nil, status.Errorf(codes.NotFound, "task %v not found", request.GetId())
+	}
+
+	return protoTaskInfo(*info)
+}
+
+func (s *Server) Cancel(ctx context.Context, request *CancelRequest) (*CancelResponse, error) {
+	return &CancelResponse{Cancelled: s.queue.Cancel(request.GetId())}, nil
+}
+
+func (s *Server) DeadLetters(ctx context.Context, request *ListRequest) (*ListResponse, error) {
+	return newListResponse(s.queue.DeadLetters())
+}
+
+func (s *Server) Requeue(ctx context.Context, request *RequeueRequest) (*RequeueResponse, error) {
+	if err := s.queue.Requeue(request.GetId()); err != nil {
+		return nil, statusError(err)
+	}
+
+	return &RequeueResponse{}, nil
+}
+
+func (s *Server) Stats(ctx context.Context, request *StatsRequest) (*QueueStats, error) {
+	stats := s.queue.Stats()
+	return &QueueStats{
+		Waiting:  int32(stats.Waiting),
+		Running:  int32(stats.Running),
+		Capacity: int32(stats.Capacity),
+		Rejected: stats.Rejected,
+		Dropped:  stats.Dropped,
+	}, nil
+}
+
+// Streams every change of task state until the client goes away or the queue stops. Watching single task
+// starts with its current state, so changes made before the subscription are not missed.
+func (s *Server) Watch(request *WatchRequest, stream grpc.ServerStreamingServer[TaskInfo]) error {
+	// subscribe before fetching the task, otherwise it could finish in between
+	events := s.queue.Subscribe(stream.Context())
+	// lets the client know it has been subscribed
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	taskId := request.GetTaskId()
+	if taskId != "" {
+		info := s.queue.Get(taskId)
+		if info == nil {
+			return status.Errorf(codes.NotFound, "task %v not found", taskId)
+		}
+		if err := sendTaskInfo(stream, *info); err != nil || info.State.IsFinished() {
+			return err
+		}
+		return s.watchTask(stream, events, taskId)
+	}
+
+	for info := range events {
+		if err := sendTaskInfo(stream, info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) Submit(request *PushRequest, stream grpc.ServerStreamingServer[TaskInfo]) error {
+	// subscribe before pushing, the task might be forgotten as soon as it finishes
+	events := s.queue.Subscribe(stream.Context())
+
+	id, err := s.push(stream.Context(), request)
+	if err != nil {
+		return err
+	}
+
+	// lets the client know the id, unless the task is gone already - its changes are buffered then
+	if info := s.queue.Get(id); info != nil {
+		if err := sendTaskInfo(stream, *info); err != nil || info.State.IsFinished() {
+			return err
+		}
+	}
+	return s.watchTask(stream, events, id)
+}
+
+// Streams changes of the task until it finishes. If the subscription ends first (the queue has stopped,
+// or the stream has fallen behind and has been unsubscribed), the final state is fetched from the queue
+// instead. Stream ends without it if the task hasn't finished or the queue doesn't know it anymore.
+func (s *Server) watchTask(stream grpc.ServerStreamingServer[TaskInfo], events <-chan executor.TaskInfo,
+	taskId string) error {
+	for info := range events {
+		if info.Id != taskId {
+			continue
+		}
+
+		if err := sendTaskInfo(stream, info); err != nil || info.State.IsFinished() {
+			return err
+		}
+	}
+
+	if info := s.queue.Get(taskId); info != nil && info.State.IsFinished() {
+		return sendTaskInfo(stream, *info)
+	}
+	return nil
+}
+
+func sendTaskInfo(stream grpc.ServerStreamingServer[TaskInfo], info executor.TaskInfo) error {
+	message, err := protoTaskInfo(info)
+	if err != nil {
+		return err
+	}
+	return stream.Send(message)
+}
+
+func newListResponse(tasks []executor.TaskInfo) (*ListResponse, error) {
+	response := &ListResponse{Tasks: make([]*TaskInfo, 0, len(tasks))}
+	for _, info := range tasks {
+		message, err := protoTaskInfo(info)
+		if err != nil {
+			return nil, err
+		}
+		response.Tasks = append(response.Tasks, message)
+	}
+
+	return response, nil
+}
+
+func protoTaskInfo(info executor.TaskInfo) (*TaskInfo, error) {
+	message, err := newProtoTaskInfo(info)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return message, nil
+}
+
+// Maps errors of the queue to status codes, `Client` maps them back
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, executor.ErrQueueClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, executor.ErrQueueFull):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, executor.ErrNotDeadLettered):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, executor.ErrUnknownDependency):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,52 @@
+package taskservice
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// Trace context of pushed tasks travels in W3C `traceparent` and `tracestate` metadata, so the server joins
+// the trace the task was pushed in, see `executor.Task.TraceContext`
+var traceContextPropagator = propagation.TraceContext{}
+
+// metadataCarrier adapts gRPC metadata to `propagation.TextMapCarrier`
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Returns outgoing context carrying the span context, ctx as it is if the span context is not valid
+func withTraceContext(ctx context.Context, spanContext trace.SpanContext) context.Context {
+	if !spanContext.IsValid() {
+		return ctx
+	}
+
+	md := metadata.MD{}
+	traceContextPropagator.Inject(trace.ContextWithSpanContext(ctx, spanContext), metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// Returns span context sent by the client, zero value if there is none
+func traceContextOf(ctx context.Context) trace.SpanContext {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return trace.SpanContextFromContext(traceContextPropagator.Extract(ctx, metadataCarrier(md)))
+}
@@ -0,0 +1,91 @@
+// Package logging is the logger shared by the examples. Components accept a `Logger` and log through it
+// with structured fields, so the output can be routed anywhere (see `NewSlogLogger`) or silenced (see `Nop`).
+package logging
+
+import "context"
+
+// Logger writes leveled entries with structured fields. Implementations have to be safe for concurrent use.
+type Logger interface {
+	// Debug is for tracing what the component does, e.g. every request of a queue
+	Debug(msg string, fields ...Field)
+	// Info is for notable events, e.g. a worker pool being resized
+	Info(msg string, fields ...Field)
+	// Warn is for problems the component has recovered from, e.g. a task that panicked
+	Warn(msg string, fields ...Field)
+	// Error is for failures somebody should look at, e.g. a log that can't be written
+	Error(msg string, fields ...Field)
+
+	// With returns logger which adds the fields to every entry
+	With(fields ...Field) Logger
+}
+
+// Field is a key-value pair attached to a log entry
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Err attaches the error under the "error" key
+func Err(err error) Field {
+	return Field{Key: "error", Value: err}
+}
+
+// TaskId identifies the task the entry is about
+func TaskId(id string) Field {
+	return Field{Key: "taskId", Value: id}
+}
+
+// Worker identifies the executor worker the entry is about
+func Worker(id int) Field {
+	return Field{Key: "worker", Value: id}
+}
+
+// Node identifies the processing node the entry is about
+func Node(name string) Field {
+	return Field{Key: "node", Value: name}
+}
+
+// Value is the value being processed
+func Value(value interface{}) Field {
+	return Field{Key: "value", Value: value}
+}
+
+// Nop returns logger which discards everything, the default of components which weren't given one
+func Nop() Logger {
+	return nopLogger{}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, fields ...Field) {}
+func (nopLogger) Info(msg string, fields ...Field)  {}
+func (nopLogger) Warn(msg string, fields ...Field)  {}
+func (nopLogger) Error(msg string, fields ...Field) {}
+func (l nopLogger) With(fields ...Field) Logger     { return l }
+
+// OrNop returns the logger, or `Nop()` if it's nil. Constructors use it, so that nil means "no logging".
+func OrNop(logger Logger) Logger {
+	if logger == nil {
+		return Nop()
+	}
+	return logger
+}
+
+type contextKey struct{}
+
+// NewContext returns context carrying the logger, e.g. to pass it to code which only gets the context
+func NewContext(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by the context, `Nop()` if there is none
+func FromContext(ctx context.Context) Logger {
+	if logger, ok := ctx.Value(contextKey{}).(Logger); ok {
+		return logger
+	}
+	return Nop()
+}
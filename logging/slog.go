@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// slogLogger writes entries through the standard library's structured logger
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger adapts `slog.Logger`, its handler decides about the format and the minimal level, e.g.
+//
+//	logging.NewSlogLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo})))
+//
+// Nil means `slog.Default()`.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &slogLogger{logger: logger}
+}
+
+func (l *slogLogger) Debug(msg string, fields ...Field) {
+	l.log(slog.LevelDebug, msg, fields)
+}
+
+func (l *slogLogger) Info(msg string, fields ...Field) {
+	l.log(slog.LevelInfo, msg, fields)
+}
+
+func (l *slogLogger) Warn(msg string, fields ...Field) {
+	l.log(slog.LevelWarn, msg, fields)
+}
+
+func (l *slogLogger) Error(msg string, fields ...Field) {
+	l.log(slog.LevelError, msg, fields)
+}
+
+func (l *slogLogger) With(fields ...Field) Logger {
+	args := make([]interface{}, 0, len(fields))
+	for _, attr := range attrs(fields) {
+		args = append(args, attr)
+	}
+	return &slogLogger{logger: l.logger.With(args...)}
+}
+
+func (l *slogLogger) log(level slog.Level, msg string, fields []Field) {
+	ctx := context.Background()
+	// skips converting the fields of entries nobody is going to see
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	l.logger.LogAttrs(ctx, level, msg, attrs(fields)...)
+}
+
+func attrs(fields []Field) []slog.Attr {
+	result := make([]slog.Attr, 0, len(fields))
+	for _, field := range fields {
+		result = append(result, slog.Any(field.Key, field.Value))
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"gotest.tools/assert"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	tests := []struct {
+		name          string
+		level         slog.Level
+		expectedMsgs  []string
+		expectedLevel []string
+	}{
+		{
+			name:          "debug",
+			level:         slog.LevelDebug,
+			expectedMsgs:  []string{"debug", "info", "warn", "error"},
+			expectedLevel: []string{"DEBUG", "INFO", "WARN", "ERROR"},
+		},
+		{
+			name:          "warn",
+			level:         slog.LevelWarn,
+			expectedMsgs:  []string{"warn", "error"},
+			expectedLevel: []string{"WARN", "ERROR"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			logger := NewSlogLogger(slog.New(slog.NewJSONHandler(output, &slog.HandlerOptions{Level: test.level})))
+
+			logger.Debug("debug")
+			logger.Info("info")
+			logger.Warn("warn")
+			logger.Error("error")
+
+			entries := decodeEntries(t, output)
+			assert.Equal(t, len(test.expectedMsgs), len(entries))
+			for index, entry := range entries {
+				assert.Equal(t, test.expectedMsgs[index], entry["msg"])
+				assert.Equal(t, test.expectedLevel[index], entry["level"])
+			}
+		})
+	}
+}
+
+func TestSlogLoggerFields(t *testing.T) {
+	output := &bytes.Buffer{}
+	logger := NewSlogLogger(slog.New(slog.NewJSONHandler(output, nil)))
+
+	logger.With(TaskId("42"), Worker(3)).Warn("task failed", Err(errors.New("out of coffee")), Value(1.5))
+	logger.Info("unrelated", Node("divide"))
+
+	entries := decodeEntries(t, output)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "42", entries[0]["taskId"])
+	assert.Equal(t, float64(3), entries[0]["worker"])
+	assert.Equal(t, "out of coffee", entries[0]["error"])
+	assert.Equal(t, 1.5, entries[0]["value"])
+
+	// fields added by `With` stay with the derived logger
+	_, found := entries[1]["taskId"]
+	assert.Check(t, !found)
+	assert.Equal(t, "divide", entries[1]["node"])
+}
+
+func TestLoggerContext(t *testing.T) {
+	assert.Equal(t, Nop(), FromContext(context.Background()))
+	assert.Equal(t, Nop(), OrNop(nil))
+
+	output := &bytes.Buffer{}
+	logger := NewSlogLogger(slog.New(slog.NewJSONHandler(output, nil)))
+	FromContext(NewContext(context.Background(), logger)).Info("from context")
+	assert.Equal(t, 1, len(decodeEntries(t, output)))
+
+	// nop logger accepts anything
+	Nop().With(TaskId("42")).Error("discarded", Err(errors.New("ignored")))
+}
+
+func decodeEntries(t *testing.T, output *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	entries := make([]map[string]interface{}, 0)
+	decoder := json.NewDecoder(output)
+	for decoder.More() {
+		entry := make(map[string]interface{})
+		assert.NilError(t, decoder.Decode(&entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,280 @@
+// Package metrics keeps counters, gauges and histograms of the examples and serves them in Prometheus text
+// format (see `Registry.ServeHTTP`), without depending on the Prometheus client.
+//
+// Components accept a `*Registry` and register their metrics in it. Nil registry is valid and so are
+// the metrics it returns - they record nothing, which is the default of components which weren't given one.
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultBuckets are upper bounds (in seconds) of histograms created without buckets
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type metricKind string
+
+const (
+	counterKind   metricKind = "counter"
+	gaugeKind     metricKind = "gauge"
+	histogramKind metricKind = "histogram"
+)
+
+// Registry is a set of metrics, safe for concurrent use. Metrics are identified by their names - registering
+// the same name again returns the metric registered before, so components sharing the registry (e.g. lanes
+// of an executor) share their metrics as well.
+type Registry struct {
+	lock     sync.Mutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// family is a metric with all of its label combinations
+type family struct {
+	name       string
+	help       string
+	kind       metricKind
+	labelNames []string
+	buckets    []float64
+
+	lock   sync.Mutex
+	series map[string]*series
+	// Values of function metrics are summed, see `NewGaugeFunc`
+	funcs []*valueFunc
+}
+
+// valueFunc is a function registered by `NewGaugeFunc` or `NewCounterFunc`, pointer identifies it
+// when it's unregistered
+type valueFunc struct {
+	value func() float64
+}
+
+// series is a single label combination of a metric
+type series struct {
+	labelValues []string
+	value       float64
+	// Histogram observations per bucket (not cumulative), the last one is +Inf
+	bucketCounts []uint64
+	sum          float64
+	count        uint64
+}
+
+// Counter only goes up, e.g. number of finished tasks
+type Counter struct {
+	family *family
+}
+
+// Gauge goes up and down, e.g. number of running tasks
+type Gauge struct {
+	family *family
+}
+
+// Histogram counts observations in buckets, e.g. how long tasks run
+type Histogram struct {
+	family *family
+}
+
+// NewCounter registers counter, values of the labels are passed to `Counter.Add` in the same order
+func (r *Registry) NewCounter(name string, help string, labelNames ...string) *Counter {
+	family := r.register(name, help, counterKind, labelNames, nil)
+	if family == nil {
+		return nil
+	}
+	return &Counter{family: family}
+}
+
+// NewGauge registers gauge, values of the labels are passed to `Gauge.Set` in the same order
+func (r *Registry) NewGauge(name string, help string, labelNames ...string) *Gauge {
+	family := r.register(name, help, gaugeKind, labelNames, nil)
+	if family == nil {
+		return nil
+	}
+	return &Gauge{family: family}
+}
+
+// NewHistogram registers histogram with the bucket upper bounds, `DefaultBuckets` if there are none.
+// Values of the labels are passed to `Histogram.Observe` in the same order.
+func (r *Registry) NewHistogram(name string, help string, buckets []float64, labelNames ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	family := r.register(name, help, histogramKind, labelNames, buckets)
+	if family == nil {
+		return nil
+	}
+	return &Histogram{family: family}
+}
+
+// NewGaugeFunc registers gauge whose value is read when the metrics are written, e.g. length of a queue.
+// Functions registered under the same name are summed, so every queue can register its own length.
+// Function is called while the metrics are being written, it must not register metrics itself.
+//
+// Returned function unregisters it, e.g. once the queue stops - the registry doesn't keep it (and whatever
+// it refers to) anymore and its value is no longer part of the sum. Unregistering again does nothing.
+func (r *Registry) NewGaugeFunc(name string, help string, value func() float64) (unregister func()) {
+	return r.registerFunc(name, help, gaugeKind, value)
+}
+
+// NewCounterFunc registers counter whose value is read when the metrics are written, see `NewGaugeFunc`.
+// Value of unregistered function is not counted anymore, Prometheus sees the sum drop as a counter reset.
+func (r *Registry) NewCounterFunc(name string, help string, value func() float64) (unregister func()) {
+	return r.registerFunc(name, help, counterKind, value)
+}
+
+func (r *Registry) registerFunc(name string, help string, kind metricKind, value func() float64) func() {
+	family := r.register(name, help, kind, nil, nil)
+	if family == nil {
+		return func() {}
+	}
+
+	registered := &valueFunc{value: value}
+	family.lock.Lock()
+	defer family.lock.Unlock()
+	family.funcs = append(family.funcs, registered)
+
+	return func() {
+		family.lock.Lock()
+		defer family.lock.Unlock()
+		for i, f := range family.funcs {
+			if f == registered {
+				family.funcs = append(family.funcs[:i:i], family.funcs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Returns family of the name, creating it if it's not registered yet. Registering the same name as another
+// kind of metric (or with other labels) is a programming error, it panics.
+func (r *Registry) register(name string, help string, kind metricKind, labelNames []string, buckets []float64) *family {
+	if r == nil {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if registered, found := r.families[name]; found {
+		if registered.kind != kind || !equalStrings(registered.labelNames, labelNames) ||
+			!equalFloats(registered.buckets, buckets) {
+			panic(fmt.Sprintf("metric %v is already registered as %v with labels %v", name, registered.kind, registered.labelNames))
+		}
+		return registered
+	}
+
+	registered := &family{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: append([]string{}, labelNames...),
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+	r.families[name] = registered
+	return registered
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter, negative delta is ignored - counters never go down
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if c == nil || delta < 0 {
+		return
+	}
+
+	c.family.update(labelValues, func(s *series) {
+		s.value += delta
+	})
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	if g == nil {
+		return
+	}
+
+	g.family.update(labelValues, func(s *series) {
+		s.value = value
+	})
+}
+
+// Add changes the gauge by delta, which can be negative
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	if g == nil {
+		return
+	}
+
+	g.family.update(labelValues, func(s *series) {
+		s.value += delta
+	})
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	if h == nil {
+		return
+	}
+
+	h.family.update(labelValues, func(s *series) {
+		bucket := sort.SearchFloat64s(h.family.buckets, value)
+		s.bucketCounts[bucket]++
+		s.sum += value
+		s.count++
+	})
+}
+
+// Applies the change to the series of the label values, creating it if it doesn't exist yet
+func (f *family) update(labelValues []string, change func(s *series)) {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metric %v has labels %v, got values %v", f.name, f.labelNames, labelValues))
+	}
+
+	// quoted values can't be confused, whatever they contain
+	key := fmt.Sprintf("%q", labelValues)
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	updated, found := f.series[key]
+	if !found {
+		updated = &series{labelValues: append([]string{}, labelValues...)}
+		if f.kind == histogramKind {
+			updated.bucketCounts = make([]uint64, len(f.buckets)+1)
+		}
+		f.series[key] = updated
+	}
+	change(updated)
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalFloats(a []float64, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"bytes"
+	"gotest.tools/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	tests := []struct {
+		name     string
+		record   func(registry *Registry)
+		expected string
+	}{
+		{
+			name: "counter with labels",
+			record: func(registry *Registry) {
+				counter := registry.NewCounter("tasks_total", "Finished tasks.", "outcome")
+				counter.Inc("succeeded")
+				counter.Add(2, "failed")
+				counter.Add(-1, "failed")
+			},
+			expected: `# HELP tasks_total Finished tasks.
+# TYPE tasks_total counter
+tasks_total{outcome="failed"} 2
+tasks_total{outcome="succeeded"} 1
+`,
+		},
+		{
+			name: "gauge",
+			record: func(registry *Registry) {
+				gauge := registry.NewGauge("running", "Running tasks.")
+				gauge.Set(3)
+				gauge.Add(-1.5)
+			},
+			expected: `# HELP running Running tasks.
+# TYPE running gauge
+running 1.5
+`,
+		},
+		{
+			name: "histogram",
+			record: func(registry *Registry) {
+				histogram := registry.NewHistogram("duration_seconds", "How long it took.", []float64{1, 0.1}, "node")
+				histogram.Observe(0.05, "a")
+				histogram.Observe(0.1, "a")
+				histogram.Observe(0.5, "a")
+				histogram.Observe(7, "a")
+			},
+			expected: `# HELP duration_seconds How long it took.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{node="a",le="0.1"} 2
+duration_seconds_bucket{node="a",le="1"} 3
+duration_seconds_bucket{node="a",le="+Inf"} 4
+duration_seconds_sum{node="a"} 7.65
+duration_seconds_count{node="a"} 4
+`,
+		},
+		{
+			name: "functions are summed",
+			record: func(registry *Registry) {
+				registry.NewGaugeFunc("waiting", "Waiting tasks.", func() float64 { return 2 })
+				registry.NewGaugeFunc("waiting", "Waiting tasks.", func() float64 { return 3 })
+			},
+			expected: `# HELP waiting Waiting tasks.
+# TYPE waiting gauge
+waiting 5
+`,
+		},
+		{
+			name: "unregistered functions are left out",
+			record: func(registry *Registry) {
+				unregister := registry.NewGaugeFunc("waiting", "Waiting tasks.", func() float64 { return 2 })
+				registry.NewGaugeFunc("waiting", "Waiting tasks.", func() float64 { return 3 })
+				unregister()
+				unregister()
+
+				registry.NewCounterFunc("dropped_total", "Dropped tasks.", func() float64 { return 1 })()
+			},
+			expected: `# HELP waiting Waiting tasks.
+# TYPE waiting gauge
+waiting 3
+`,
+		},
+		{
+			name: "sorted by name, metrics without values are left out",
+			record: func(registry *Registry) {
+				registry.NewCounter("errors_total", "Errors.")
+				registry.NewCounter("b_total", "B.").Inc()
+				registry.NewCounter("a_total", "A.").Inc()
+			},
+			expected: `# HELP a_total A.
+# TYPE a_total counter
+a_total 1
+# HELP b_total B.
+# TYPE b_total counter
+b_total 1
+`,
+		},
+		{
+			name: "escaping",
+			record: func(registry *Registry) {
+				registry.NewCounter("escaped_total", "Back\\slash\nnew line.", "value").Inc("\"quoted\"\n\\")
+			},
+			expected: `# HELP escaped_total Back\\slash\nnew line.
+# TYPE escaped_total counter
+escaped_total{value="\"quoted\"\n\\"} 1
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			registry := NewRegistry()
+			test.record(registry)
+
+			output := &bytes.Buffer{}
+			assert.NilError(t, registry.WriteText(output))
+			assert.Equal(t, test.expected, output.String())
+		})
+	}
+}
+
+func TestRegisterSameName(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("tasks_total", "Finished tasks.", "outcome").Inc("succeeded")
+	registry.NewCounter("tasks_total", "Finished tasks.", "outcome").Inc("succeeded")
+
+	output := &bytes.Buffer{}
+	assert.NilError(t, registry.WriteText(output))
+	assert.Check(t, strings.Contains(output.String(), `tasks_total{outcome="succeeded"} 2`))
+
+	assert.Assert(t, panics(func() { registry.NewGauge("tasks_total", "Finished tasks.", "outcome") }))
+	assert.Assert(t, panics(func() { registry.NewCounter("tasks_total", "Finished tasks.") }))
+	assert.Assert(t, panics(func() { registry.NewCounter("tasks_total", "Finished tasks.", "outcome").Inc() }))
+}
+
+func TestNilRegistry(t *testing.T) {
+	var registry *Registry
+
+	// nothing is recorded, nothing fails
+	registry.NewCounter("tasks_total", "Finished tasks.").Inc()
+	registry.NewGauge("running", "Running tasks.").Set(1)
+	registry.NewHistogram("duration_seconds", "How long it took.", nil).Observe(1)
+	registry.NewGaugeFunc("waiting", "Waiting tasks.", func() float64 { return 1 })
+
+	output := &bytes.Buffer{}
+	assert.NilError(t, registry.WriteText(output))
+	assert.Equal(t, "", output.String())
+}
+
+func TestServeHTTP(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("tasks_total", "Finished tasks.").Inc()
+
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Check(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+	assert.Check(t, strings.Contains(recorder.Body.String(), "tasks_total 1\n"))
+}
+
+func panics(f func()) (panicked bool) {
+	defer func() {
+		panicked = recover() != nil
+	}()
+	f()
+	return false
+}
@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ServeHTTP writes the metrics in Prometheus text format, so the registry can be served as e.g. /metrics
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	// client has gone away when this fails, there is nobody to tell
+	_ = r.WriteText(w)
+}
+
+// WriteText writes the metrics in Prometheus text exposition format, sorted by name and labels
+func (r *Registry) WriteText(w io.Writer) error {
+	if r == nil {
+		return nil
+	}
+
+	r.lock.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, registered := range r.families {
+		families = append(families, registered)
+	}
+	r.lock.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	buffered := bufio.NewWriter(w)
+	for _, written := range families {
+		written.write(buffered)
+	}
+	return buffered.Flush()
+}
+
+func (f *family) write(w *bufio.Writer) {
+	// functions may take a while (e.g. a queue answering its length), they are called without the lock
+	f.lock.Lock()
+	funcs := append([]*valueFunc{}, f.funcs...)
+	f.lock.Unlock()
+
+	samples := make([]string, 0)
+	if len(funcs) > 0 {
+		total := 0.0
+		for _, registered := range funcs {
+			total += registered.value()
+		}
+		samples = append(samples, f.sample("", nil, nil, total))
+	}
+
+	f.lock.Lock()
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		samples = append(samples, f.seriesSamples(f.series[key])...)
+	}
+	f.lock.Unlock()
+
+	if len(samples) == 0 {
+		// metric without values yet, e.g. counter of errors which haven't happened
+		return
+	}
+
+	fmt.Fprintf(w, "# HELP %v %v\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %v %v\n", f.name, f.kind)
+	for _, sample := range samples {
+		w.WriteString(sample)
+	}
+}
+
+// Lines of the series, histograms have one per bucket plus sum and count
+func (f *family) seriesSamples(s *series) []string {
+	if f.kind != histogramKind {
+		return []string{f.sample("", nil, s.labelValues, s.value)}
+	}
+
+	samples := make([]string, 0, len(s.bucketCounts)+2)
+	cumulative := uint64(0)
+	for i, count := range s.bucketCounts {
+		cumulative += count
+		bound := math.Inf(1)
+		if i < len(f.buckets) {
+			bound = f.buckets[i]
+		}
+		samples = append(samples, f.sample("_bucket", []string{"le", formatFloat(bound)}, s.labelValues, float64(cumulative)))
+	}
+	samples = append(samples, f.sample("_sum", nil, s.labelValues, s.sum))
+	samples = append(samples, f.sample("_count", nil, s.labelValues, float64(s.count)))
+	return samples
+}
+
+// Formats line of the sample, extra is a label name and value appended after the labels of the metric
+func (f *family) sample(suffix string, extra []string, labelValues []string, value float64) string {
+	pairs := make([]string, 0, len(labelValues)+1)
+	for i, name := range f.labelNames {
+		pairs = append(pairs, name+`="`+escapeLabelValue(labelValues[i])+`"`)
+	}
+	if extra != nil {
+		pairs = append(pairs, extra[0]+`="`+escapeLabelValue(extra[1])+`"`)
+	}
+
+	labels := ""
+	if len(pairs) > 0 {
+		labels = "{" + strings.Join(pairs, ",") + "}"
+	}
+	return f.name + suffix + labels + " " + formatFloat(value) + "\n"
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(value)
+}
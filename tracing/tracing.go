@@ -0,0 +1,58 @@
+// Package tracing sets up OpenTelemetry tracing of the examples. Components accept a `trace.TracerProvider`
+// and trace what they do with it, nothing is traced when they weren't given one (see `OrNoop`). Spans are
+// exported over OTLP (see `NewOTLPProvider`), tests keep them in memory (see package tracingtest).
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// OrNoop returns the provider, or provider which traces nothing if it's nil
+func OrNoop(provider trace.TracerProvider) trace.TracerProvider {
+	if provider == nil {
+		return noop.NewTracerProvider()
+	}
+	return provider
+}
+
+// NewOTLPProvider creates provider exporting spans of the service over OTLP/gRPC to the collector
+// at the endpoint, e.g. localhost:4317. Spans are exported in batches - `Shutdown` of the provider
+// exports the remaining ones.
+func NewOTLPProvider(ctx context.Context, endpoint string, serviceName string) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpoint(endpoint), otlptracegrpc.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	), nil
+}
+
+// TaskId identifies the task the span is about
+func TaskId(id string) attribute.KeyValue {
+	return attribute.String("task.id", id)
+}
+
+// Worker identifies the executor worker the span is about
+func Worker(id int) attribute.KeyValue {
+	return attribute.Int("worker", id)
+}
+
+// Node identifies the processing node the span is about
+func Node(name string) attribute.KeyValue {
+	return attribute.String("node", name)
+}
+
+// Value is the value being processed
+func Value(value float64) attribute.KeyValue {
+	return attribute.Float64("value", value)
+}
@@ -0,0 +1,28 @@
+package tracing
+
+import (
+	"AwesomePresentation/tracing/tracingtest"
+	"context"
+	"gotest.tools/assert"
+	"testing"
+)
+
+func TestOrNoop(t *testing.T) {
+	_, span := OrNoop(nil).Tracer("test").Start(context.Background(), "nothing")
+	span.End()
+	assert.Check(t, !span.SpanContext().IsValid())
+	assert.Check(t, !span.IsRecording())
+
+	provider, exporter := tracingtest.NewInMemoryProvider()
+	assert.Equal(t, provider, OrNoop(provider))
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	_, child := provider.Tracer("test").Start(ctx, "child")
+	child.End()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 2, len(spans))
+	assert.Equal(t, "child", spans[0].Name)
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent.SpanID())
+}
@@ -0,0 +1,15 @@
+// Package tracingtest helps tests inspect spans traced by components, see `NewInMemoryProvider`.
+// It's kept apart from package tracing, so that binaries don't carry the test exporter.
+package tracingtest
+
+import (
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// NewInMemoryProvider creates provider which hands every span to the exporter as soon as it ends,
+// so tests can inspect them with `InMemoryExporter.GetSpans`
+func NewInMemoryProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), exporter
+}